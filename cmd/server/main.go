@@ -1,28 +1,59 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
-	"os"
-	"strings"
 
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
 	"my_pocketbase_app/internal/app"
 	"my_pocketbase_app/internal/bitrix"
 	"my_pocketbase_app/internal/config"
 	appCore "my_pocketbase_app/internal/core"
 	"my_pocketbase_app/internal/handlers"
+	"my_pocketbase_app/internal/migrations"
+	"my_pocketbase_app/internal/reconcile"
+	"my_pocketbase_app/internal/secrets"
 )
 
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	pbApp := pocketbase.New()
-	appContext := &app.AppContext{
-		StatusMap: make(map[string]string),
-	}
+	appContext := &app.AppContext{}
+
+	// Путь к config.json; без флага — KPI_CONFIG или поиск рядом с рабочим каталогом
+	var configPath string
+	pbApp.RootCmd.PersistentFlags().StringVar(&configPath, "config", "", "path to config.json (env "+config.EnvConfigPath+")")
+
+	// Пересборка task_entries из tasks.data для уже загруженных отчетов
+	pbApp.RootCmd.AddCommand(&cobra.Command{
+		Use:   "backfill-entries",
+		Short: "Rebuild task_entries from tasks.data",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			count, err := appCore.BackfillTaskEntries(pbApp)
+			if err != nil {
+				return err
+			}
+			log.Printf("[INFO] Rebuilt task_entries for %d files", count)
+			return nil
+		},
+	})
+
+	// Миграции схемы. serve применяет новые миграции сам; команда нужна для отката и просмотра состояния
+	pbApp.RootCmd.AddCommand(&cobra.Command{
+		Use:       "migrate [up|down [n]|status|history-sync]",
+		Short:     "Apply, revert or list schema migrations",
+		ValidArgs: []string{"up", "down", "status", "history-sync"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 && args[0] == "status" {
+				return printMigrationStatus(pbApp, cmd.OutOrStdout())
+			}
+			return core.NewMigrationsRunner(pbApp, core.AppMigrations).Run(args...)
+		},
+	})
 
 	// Регистрируем Bitrix (он сам добавит хуки в OnServe)
 	if err := bitrix.Register(pbApp); err != nil {
@@ -36,6 +67,8 @@ func main() {
 		// Регистрация хуков через e.App
 		appCore.RegisterLeaveRequestHooks(pbApp)
 		appCore.RegisterTaskSignaling(pbApp)
+		appCore.RegisterTaskEntriesSync(pbApp)
+		reconcile.RegisterStatusHooks(pbApp, appContext)
 
 		// API Routes
 		e.Router.GET("/hello", func(e *core.RequestEvent) error {
@@ -46,12 +79,22 @@ func main() {
 		e.Router.GET("/api/kpi/yearly-ranking", func(e *core.RequestEvent) error { return handlers.HandleYearlyRanking(pbApp, appContext, e) })
 		e.Router.GET("/api/kpi/actual-tasks", func(e *core.RequestEvent) error { return handlers.HandleActualTasks(pbApp, appContext, e) })
 		e.Router.GET("/api/kpi/completed-tasks-grouped", func(e *core.RequestEvent) error { return handlers.HandleCompletedTasksGrouped(pbApp, appContext, e) })
+		e.Router.GET("/api/kpi/task-history", func(e *core.RequestEvent) error { return handlers.HandleTaskHistory(pbApp, appContext, e) })
 		e.Router.GET("/api/kpi/returned-tasks", func(e *core.RequestEvent) error { return handlers.HandleReturnedTasks(pbApp, appContext, e) })
+		e.Router.GET("/api/kpi/return-analytics", func(e *core.RequestEvent) error { return handlers.HandleReturnAnalytics(pbApp, appContext, e) })
+		e.Router.GET("/api/kpi/daily-stats", func(e *core.RequestEvent) error { return handlers.HandleDailyStats(pbApp, appContext, e) })
+		e.Router.GET("/api/kpi/comparison", func(e *core.RequestEvent) error { return handlers.HandleComparison(pbApp, appContext, e) })
+		e.Router.GET("/api/kpi/time-reconciliation", func(e *core.RequestEvent) error { return handlers.HandleTimeReconciliation(pbApp, appContext, e) })
+		e.Router.GET("/api/org/tree", func(e *core.RequestEvent) error { return handlers.HandleOrgTree(pbApp, appContext, e) })
+		e.Router.GET("/api/kpi/department-ranking", func(e *core.RequestEvent) error { return handlers.HandleDepartmentRanking(pbApp, appContext, e) })
+		e.Router.GET("/api/kpi/estimate-accuracy", func(e *core.RequestEvent) error { return handlers.HandleEstimateAccuracy(pbApp, appContext, e) })
 		e.Router.POST("/api/kpi/update-task-time", func(e *core.RequestEvent) error { return handlers.HandleUpdateTaskTime(pbApp, appContext, e) })
+		e.Router.POST("/api/kpi/upload", func(e *core.RequestEvent) error { return handlers.HandleUploadReport(pbApp, appContext, e) })
+		e.Router.POST("/api/kpi/upload/validate", func(e *core.RequestEvent) error { return handlers.HandleValidateReport(pbApp, appContext, e) })
 
-		// Инициализация структуры
-		if err := bootstrapCollections(e.App, appContext); err != nil {
-			log.Printf("[ERROR] Bootstrap collections failed: %v", err)
+		// Справочники из config.json
+		if err := bootstrapCollections(e.App, appContext, configPath); err != nil {
+			return fmt.Errorf("bootstrap collections: %w", err)
 		}
 
 		log.Println("[INFO] Server is ready to serve requests")
@@ -63,109 +106,80 @@ func main() {
 	}
 }
 
-func findConfigFile() (string, error) {
-	paths := []string{"config.json", "../config.json", "../../config.json"}
-	for _, p := range paths {
-		if _, err := os.Stat(p); err == nil {
-			log.Printf("[INFO] Using config file: %s", p)
-			return p, nil
+func printMigrationStatus(pbApp core.App, out io.Writer) error {
+	list, err := migrations.List(pbApp)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, s := range list {
+		switch {
+		case s.Missing:
+			fmt.Fprintf(out, "[missing] %s (applied %s, file not found)\n", s.File, s.Applied.Format("2006-01-02 15:04:05"))
+		case s.Applied.IsZero():
+			pending++
+			fmt.Fprintf(out, "[pending] %s\n", s.File)
+		default:
+			fmt.Fprintf(out, "[applied] %s (%s)\n", s.File, s.Applied.Format("2006-01-02 15:04:05"))
 		}
 	}
-	return "", os.ErrNotExist
+	fmt.Fprintf(out, "%d migrations, %d pending\n", len(list), pending)
+	return nil
 }
 
-func bootstrapCollections(pbApp core.App, context *app.AppContext) error {
-	log.Println("[INFO] Initializing collections structure...")
+func bootstrapCollections(pbApp core.App, context *app.AppContext, configPath string) error {
+	// Схема к этому моменту уже приведена миграциями (internal/migrations): serve применяет их до OnServe
+	log.Println("[INFO] Loading statuses and fields from config...")
 
-	if err := appCore.EnsureCoreCollections(pbApp); err != nil {
-		return fmt.Errorf("core: %w", err)
-	}
-	if err := appCore.EnsureStatusCollection(pbApp); err != nil {
-		return fmt.Errorf("statuses: %w", err)
-	}
-	if err := appCore.EnsureTaskFieldsCollection(pbApp); err != nil {
-		return fmt.Errorf("fields: %w", err)
-	}
-	if err := appCore.EnsureSettingsCollection(pbApp); err != nil {
-		return fmt.Errorf("settings: %w", err)
-	}
-	if err := appCore.EnsureViews(pbApp); err != nil {
-		return fmt.Errorf("views: %w", err)
+	// Вебхук и токен событий раньше хранились открытым текстом в settings (читаемой любым сотрудником) —
+	// переносим в зашифрованные секреты
+	for _, name := range []string{secrets.BitrixWebhook, secrets.BitrixApplicationToken} {
+		moved, err := secrets.MoveFromSettings(pbApp, name)
+		if errors.Is(err, secrets.ErrNoKey) {
+			return fmt.Errorf("%s is still stored in settings: set %s to a 32-character key (e.g. `openssl rand -hex 16`) and restart to encrypt it", name, secrets.EnvKey)
+		}
+		if err != nil {
+			return fmt.Errorf("move %s from settings to secrets: %w", name, err)
+		}
+		if moved {
+			log.Printf("[INFO] %s moved from settings to encrypted secrets", name)
+		}
 	}
 
-	configPath, err := findConfigFile()
-	if err != nil {
+	appConfig, usedPath, err := config.Load(configPath)
+	if errors.Is(err, config.ErrNotFound) {
 		log.Println("[WARN] Config file not found, skipping sync")
-		return nil
+		return reconcile.ReloadStatuses(pbApp, context)
 	}
-
-	configFile, err := os.Open(configPath)
 	if err != nil {
-		return err
+		return fmt.Errorf("load config: %w", err)
 	}
-	defer configFile.Close()
+	log.Printf("[INFO] Using config file: %s", usedPath)
 
-	var appConfig config.AppConfig
-	bytes, _ := io.ReadAll(configFile)
-	if err := json.Unmarshal(bytes, &appConfig); err != nil {
+	policy, err := reconcile.ParsePolicy(appConfig.ReconcilePolicy)
+	if err != nil {
 		return err
 	}
-
-	for _, s := range appConfig.Statuses {
-		context.StatusMap[strings.ToLower(strings.TrimSpace(s.Slug))] = s.Type
-		context.StatusMap[strings.ToLower(strings.TrimSpace(s.Title))] = s.Type
-	}
-
-	statusesCol, _ := pbApp.FindCollectionByNameOrId("statuses")
-	if statusesCol != nil {
-		existingCount := 0
-		pbApp.DB().Select("count(*)").From("statuses").Row(&existingCount)
-		if existingCount == 0 {
-			log.Println("[INFO] Populating 'statuses' collection...")
-			for _, s := range appConfig.Statuses {
-				record := core.NewRecord(statusesCol)
-				record.Set("title", s.Title)
-				record.Set("slug", s.Slug)
-				record.Set("color", s.Color)
-				pbApp.Save(record)
-			}
-		}
-	}
-
-	fieldsCol, _ := pbApp.FindCollectionByNameOrId("task_fields")
-	if fieldsCol != nil {
-		existingCount := 0
-		pbApp.DB().Select("count(*)").From("task_fields").Row(&existingCount)
-		if existingCount == 0 {
-			log.Println("[INFO] Populating 'task_fields' collection...")
-			for i, f := range appConfig.TaskFields {
-				record := core.NewRecord(fieldsCol)
-				record.Set("key", f.Key)
-				record.Set("title", f.Title)
-				record.Set("type", f.Type)
-				record.Set("required", f.Required)
-				record.Set("width", f.Width)
-				record.Set("filterable", f.Filterable)
-				record.Set("order", i)
-				pbApp.Save(record)
-			}
-		}
+	report, err := reconcile.Run(pbApp, *appConfig, policy)
+	if err != nil {
+		return fmt.Errorf("reconcile config: %w", err)
 	}
+	report.Log()
 
 	if appConfig.BitrixWebhook != "" {
-		settings, _ := pbApp.FindCollectionByNameOrId("settings")
-		if settings != nil {
-			record, _ := pbApp.FindFirstRecordByFilter("settings", "key='bitrix_webhook'")
-			if record == nil {
-				record = core.NewRecord(settings)
-				record.Set("key", "bitrix_webhook")
-			}
-			record.Set("value", appConfig.BitrixWebhook)
-			if err := pbApp.Save(record); err == nil {
-				log.Println("[INFO] Bitrix webhook synced successfully")
-			}
+		// Вебхук из файла или KPI_BITRIX_WEBHOOK — только начальное значение: иначе каждый перезапуск
+		// откатывал бы замену, сделанную через POST /api/bitrix/webhook после отзыва старого вебхука
+		stored, current, err := secrets.Seed(pbApp, secrets.BitrixWebhook, appConfig.BitrixWebhook)
+		if err != nil {
+			return fmt.Errorf("store bitrix_webhook: %w", err)
+		}
+		if stored {
+			log.Println("[INFO] Bitrix webhook from config stored in encrypted secrets")
+		} else if current != appConfig.BitrixWebhook {
+			log.Println("[WARN] bitrix_webhook from config differs from the stored webhook and is ignored; change it via POST /api/bitrix/webhook and remove it from config")
 		}
 	}
 
-	return nil
+	// Статусы для расчетов берутся из БД, а не из файла, чтобы KPI и админка не расходились
+	return reconcile.ReloadStatuses(pbApp, context)
 }
@@ -1,12 +1,23 @@
 package app
 
 const (
-	CollectionTasks = "tasks"
-	FieldData       = "data"
-	FieldUser       = "user"
-	FieldFileDate   = "file_date"
-	FieldFileName   = "file_name"
-	StatusFinal     = "final"
-	StatusReturn    = "return"
-	MaxFetchLimit   = 10000
+	CollectionTasks       = "tasks"
+	CollectionTaskEntries = "task_entries"
+	FieldData             = "data"
+	FieldUser             = "user"
+	FieldFileDate         = "file_date"
+	FieldFileName         = "file_name"
+	StatusFinal           = "final"
+	StatusFinalReturn     = "final_return" // завершена после возврата
+	StatusAppeal          = "appeal"       // завершена по апелляции
+	StatusInProgress      = "in_progress"
+	StatusReturn          = "return"
+
+	// Политики сверки справочников config.json с БД (reconcile_policy)
+	ReconcilePolicyFile  = "file"
+	ReconcilePolicyMerge = "merge"
+	ReconcilePolicyDB    = "db"
 )
+
+// StatusColors — допустимые значения statuses.color (select-поле коллекции statuses)
+var StatusColors = []string{"slate", "gray", "zinc", "neutral", "stone", "red", "orange", "amber", "yellow", "lime", "green", "emerald", "teal", "cyan", "sky", "blue", "indigo", "violet", "purple", "fuchsia", "pink", "rose", "success", "warning", "danger", "info", "primary", "secondary"}
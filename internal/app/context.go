@@ -2,5 +2,11 @@ package app
 
 // AppContext contains application dependencies
 type AppContext struct {
-	StatusMap map[string]string
+	StatusRegistry StatusRegistry // статусы из коллекции statuses, обновляются хуками без перезапуска
+}
+
+// Statuses возвращает актуальный снимок статусов. Обработчик берет его один раз на запрос,
+// чтобы весь расчет шел по одной версии справочника.
+func (c *AppContext) Statuses() *StatusSet {
+	return c.StatusRegistry.Get()
 }
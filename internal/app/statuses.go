@@ -0,0 +1,30 @@
+package app
+
+import "sync/atomic"
+
+// StatusSet — снимок статусов из коллекции statuses. После публикации в реестре карты не меняются,
+// поэтому их можно читать из любого числа запросов без блокировок.
+type StatusSet struct {
+	Types map[string]string // нормализованные slug/title -> type
+	Slugs map[string]string // нормализованные slug/title -> slug
+}
+
+// StatusRegistry — потокобезопасный реестр статусов: снимок заменяется целиком при изменении statuses
+type StatusRegistry struct {
+	current atomic.Pointer[StatusSet]
+}
+
+var emptyStatusSet = &StatusSet{Types: map[string]string{}, Slugs: map[string]string{}}
+
+// Get возвращает текущий снимок (пустой, пока статусы не загружены)
+func (r *StatusRegistry) Get() *StatusSet {
+	if s := r.current.Load(); s != nil {
+		return s
+	}
+	return emptyStatusSet
+}
+
+// Replace публикует новый снимок
+func (r *StatusRegistry) Replace(s *StatusSet) {
+	r.current.Store(s)
+}
@@ -0,0 +1,271 @@
+package bitrix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Ограничения пакетного метода batch и постраничной выдачи list-методов Bitrix24
+const (
+	MaxBatchCommands = 50
+	pageSize         = 50
+)
+
+// BatchCommand — один вызов внутри batch
+type BatchCommand struct {
+	Method string
+	Params map[string]interface{}
+}
+
+// BatchResult — результат одной команды batch. Err заполнен, если Bitrix вернул ошибку именно для этой команды.
+type BatchResult struct {
+	Result json.RawMessage
+	Total  int
+	Next   int
+	Err    *APIError
+}
+
+type batchError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type batchResponse struct {
+	Result struct {
+		Result      phpMap[json.RawMessage] `json:"result"`
+		ResultError phpMap[batchError]      `json:"result_error"`
+		ResultTotal phpMap[int]             `json:"result_total"`
+		ResultNext  phpMap[int]             `json:"result_next"`
+	} `json:"result"`
+}
+
+// phpMap — ассоциативный массив из PHP: пустой Bitrix отдает как [], а не {}
+type phpMap[V any] map[string]V
+
+func (m *phpMap[V]) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var list []V
+		if err := json.Unmarshal(trimmed, &list); err != nil {
+			return err
+		}
+		*m = make(phpMap[V], len(list))
+		for i, v := range list {
+			(*m)[strconv.Itoa(i)] = v
+		}
+		return nil
+	}
+	return json.Unmarshal(data, (*map[string]V)(m))
+}
+
+// Batch выполняет до MaxBatchCommands команд одним запросом и возвращает результаты в порядке команд
+func (c *Client) Batch(ctx context.Context, cmds []BatchCommand) ([]BatchResult, error) {
+	if len(cmds) > MaxBatchCommands {
+		return nil, fmt.Errorf("bitrix batch: %d commands, max %d", len(cmds), MaxBatchCommands)
+	}
+	if len(cmds) == 0 {
+		return nil, nil
+	}
+
+	cmdMap := make(map[string]string, len(cmds))
+	for i, cmd := range cmds {
+		cmdMap[batchKey(i)] = cmd.Method + "?" + EncodeParams(cmd.Params)
+	}
+	resp, err := c.Call(ctx, "batch", map[string]interface{}{"halt": 0, "cmd": cmdMap})
+	if err != nil {
+		return nil, err
+	}
+
+	var data batchResponse
+	if err := json.Unmarshal(resp, &data); err != nil {
+		return nil, fmt.Errorf("bitrix batch: invalid response: %w", err)
+	}
+
+	results := make([]BatchResult, len(cmds))
+	for i, cmd := range cmds {
+		key := batchKey(i)
+		if e, ok := data.Result.ResultError[key]; ok {
+			results[i].Err = &APIError{Method: cmd.Method, StatusCode: 200, Code: e.Error, Description: e.ErrorDescription}
+			continue
+		}
+		raw, ok := data.Result.Result[key]
+		if !ok {
+			results[i].Err = &APIError{Method: cmd.Method, StatusCode: 200, Code: "BATCH_NO_RESULT", Description: "command result missing in batch response"}
+			continue
+		}
+		results[i].Result = raw
+		results[i].Total = data.Result.ResultTotal[key]
+		results[i].Next = data.Result.ResultNext[key]
+	}
+	return results, nil
+}
+
+func batchKey(i int) string {
+	return "c" + strconv.Itoa(i)
+}
+
+// EncodeParams кодирует параметры в query-строку в формате PHP http_build_query
+// (filter[>ID]=0&select[0]=id), как ожидают команды внутри batch. Ключи сортируются для стабильности.
+func EncodeParams(params map[string]interface{}) string {
+	var parts []string
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = encodeValue(parts, k, params[k])
+	}
+	return strings.Join(parts, "&")
+}
+
+func encodeValue(parts []string, key string, v interface{}) []string {
+	switch val := v.(type) {
+	case nil:
+		return parts
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			parts = encodeValue(parts, key+"["+k+"]", val[k])
+		}
+	case map[string]string:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			parts = encodeValue(parts, key+"["+k+"]", val[k])
+		}
+	case []string:
+		for i, item := range val {
+			parts = encodeValue(parts, key+"["+strconv.Itoa(i)+"]", item)
+		}
+	case []interface{}:
+		for i, item := range val {
+			parts = encodeValue(parts, key+"["+strconv.Itoa(i)+"]", item)
+		}
+	case []int:
+		for i, item := range val {
+			parts = encodeValue(parts, key+"["+strconv.Itoa(i)+"]", item)
+		}
+	case bool:
+		s := "N"
+		if val {
+			s = "Y"
+		}
+		parts = append(parts, url.QueryEscape(key)+"="+s)
+	default:
+		parts = append(parts, url.QueryEscape(key)+"="+url.QueryEscape(fmt.Sprint(val)))
+	}
+	return parts
+}
+
+// fetchPages обходит все страницы list-метода: первая страница — обычным запросом (из нее берется total),
+// остальные — пакетами по MaxBatchCommands страниц за один запрос. handle получает поле result каждой страницы по порядку.
+func (s *SyncManager) fetchPages(method string, params map[string]interface{}, handle func(result json.RawMessage) error) error {
+	firstParams := make(map[string]interface{}, len(params)+1)
+	for k, v := range params {
+		firstParams[k] = v
+	}
+	firstParams["start"] = 0
+
+	resp, err := s.call(method, firstParams)
+	if err != nil {
+		return err
+	}
+	var first BxResponse[json.RawMessage]
+	if err := json.Unmarshal(resp, &first); err != nil {
+		return fmt.Errorf("bitrix %s: invalid response: %w", method, err)
+	}
+	if err := handle(first.Result); err != nil {
+		return err
+	}
+	if first.Next == 0 {
+		return nil
+	}
+
+	if first.Total == 0 {
+		// Метод не сообщил total — дочитываем страницы последовательно
+		return s.fetchSequential(method, params, first.Next, handle)
+	}
+
+	var starts []int
+	for start := first.Next; start < first.Total; start += pageSize {
+		starts = append(starts, start)
+	}
+	for len(starts) > 0 {
+		n := len(starts)
+		if n > MaxBatchCommands {
+			n = MaxBatchCommands
+		}
+		cmds := make([]BatchCommand, 0, n)
+		for _, start := range starts[:n] {
+			p := make(map[string]interface{}, len(params)+1)
+			for k, v := range params {
+				p[k] = v
+			}
+			p["start"] = start
+			cmds = append(cmds, BatchCommand{Method: method, Params: p})
+		}
+		starts = starts[n:]
+
+		results, err := s.client.Batch(s.ctx, cmds)
+		if err != nil {
+			return err
+		}
+		for i, r := range results {
+			if r.Err != nil {
+				if !r.Err.Temporary() {
+					return r.Err
+				}
+				// Временная ошибка отдельной команды — повторяем страницу обычным запросом (с ретраями клиента)
+				resp, err := s.call(method, cmds[i].Params)
+				if err != nil {
+					return err
+				}
+				var page BxResponse[json.RawMessage]
+				if err := json.Unmarshal(resp, &page); err != nil {
+					return fmt.Errorf("bitrix %s: invalid response: %w", method, err)
+				}
+				r.Result = page.Result
+			}
+			if err := handle(r.Result); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *SyncManager) fetchSequential(method string, params map[string]interface{}, start int, handle func(result json.RawMessage) error) error {
+	for start > 0 {
+		p := make(map[string]interface{}, len(params)+1)
+		for k, v := range params {
+			p[k] = v
+		}
+		p["start"] = start
+		resp, err := s.call(method, p)
+		if err != nil {
+			return err
+		}
+		var page BxResponse[json.RawMessage]
+		if err := json.Unmarshal(resp, &page); err != nil {
+			return fmt.Errorf("bitrix %s: invalid response: %w", method, err)
+		}
+		if err := handle(page.Result); err != nil {
+			return err
+		}
+		start = page.Next
+	}
+	return nil
+}
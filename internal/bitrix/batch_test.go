@@ -0,0 +1,130 @@
+package bitrix
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEncodeParams(t *testing.T) {
+	got := EncodeParams(map[string]interface{}{
+		"start":  50,
+		"filter": map[string]interface{}{">ID": 0},
+		"order":  map[string]string{"ID": "DESC"},
+		"select": []string{"id", "title"},
+	})
+	want := "filter%5B%3EID%5D=0&order%5BID%5D=DESC&select%5B0%5D=id&select%5B1%5D=title&start=50"
+	if got != want {
+		t.Errorf("EncodeParams = %q, want %q", got, want)
+	}
+}
+
+func TestBatchDemultiplexesResults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/batch") {
+			t.Errorf("unexpected method %s", r.URL.Path)
+		}
+		var req struct {
+			Halt int               `json:"halt"`
+			Cmd  map[string]string `json:"cmd"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if len(req.Cmd) != 2 || req.Cmd["c0"] != "user.get?start=0" {
+			t.Errorf("unexpected commands %v", req.Cmd)
+		}
+		w.Write([]byte(`{"result":{
+			"result":{"c0":[{"ID":"1"}]},
+			"result_error":{"c1":{"error":"ACCESS_DENIED","error_description":"Access denied"}},
+			"result_total":{"c0":120},
+			"result_next":{"c0":50}
+		}}`))
+	}))
+	defer srv.Close()
+
+	results, err := testClient(srv.URL).Batch(context.Background(), []BatchCommand{
+		{Method: "user.get", Params: map[string]interface{}{"start": 0}},
+		{Method: "sonet_group.get", Params: map[string]interface{}{"start": 0}},
+	})
+	if err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Err != nil || results[0].Total != 120 || results[0].Next != 50 || string(results[0].Result) != `[{"ID":"1"}]` {
+		t.Errorf("unexpected first result %+v", results[0])
+	}
+	if results[1].Err == nil || results[1].Err.Code != "ACCESS_DENIED" || results[1].Err.Method != "sonet_group.get" {
+		t.Errorf("unexpected second result %+v", results[1])
+	}
+}
+
+func TestBatchAcceptsEmptyPHPArrays(t *testing.T) {
+	// Пустые ассоциативные массивы PHP приходят как [], а не {}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"result":{"result":{"c0":[]},"result_error":[],"result_total":[],"result_next":[]}}`))
+	}))
+	defer srv.Close()
+
+	results, err := testClient(srv.URL).Batch(context.Background(), []BatchCommand{{Method: "task.commentitem.getlist"}})
+	if err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+	if results[0].Err != nil || string(results[0].Result) != "[]" {
+		t.Errorf("unexpected result %+v", results[0])
+	}
+}
+
+func TestBatchTooManyCommands(t *testing.T) {
+	cmds := make([]BatchCommand, MaxBatchCommands+1)
+	if _, err := NewClient("http://example.invalid").Batch(context.Background(), cmds); err == nil {
+		t.Fatal("expected error for oversized batch")
+	}
+}
+
+func TestFetchPagesUsesBatchAfterFirstPage(t *testing.T) {
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		methods = append(methods, method)
+		switch method {
+		case "user.get":
+			w.Write([]byte(`{"result":[{"ID":"1"}],"total":120,"next":50}`))
+		case "batch":
+			var req struct {
+				Cmd map[string]string `json:"cmd"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Cmd["c0"] != "user.get?start=50" || req.Cmd["c1"] != "user.get?start=100" {
+				t.Errorf("unexpected batch commands %v", req.Cmd)
+			}
+			w.Write([]byte(`{"result":{"result":{"c0":[{"ID":"2"}],"c1":[{"ID":"3"}]}}}`))
+		}
+	}))
+	defer srv.Close()
+
+	s := &SyncManager{client: testClient(srv.URL), ctx: context.Background()}
+	var ids []string
+	err := s.fetchPages("user.get", nil, func(result json.RawMessage) error {
+		var users []BxUser
+		if err := json.Unmarshal(result, &users); err != nil {
+			return err
+		}
+		for _, u := range users {
+			ids = append(ids, u.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("fetchPages failed: %v", err)
+	}
+	if strings.Join(ids, ",") != "1,2,3" {
+		t.Errorf("pages handled out of order: %v", ids)
+	}
+	if strings.Join(methods, ",") != "user.get,batch" {
+		t.Errorf("expected one list call and one batch, got %v", methods)
+	}
+}
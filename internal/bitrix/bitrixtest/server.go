@@ -0,0 +1,545 @@
+// Package bitrixtest — фейковый REST API Bitrix24 на httptest для тестов синхронизации.
+// Сервер хранит данные в памяти, отдает list-методы постранично (start / next / total),
+// понимает batch и фильтры >ID / >CHANGED_DATE и умеет возвращать ошибки по заказу.
+package bitrixtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PageSize — размер страницы list-методов, как у Bitrix24
+const PageSize = 50
+
+// Failure — ошибка, которую сервер вернет на вызов метода вместо результата
+type Failure struct {
+	Status      int
+	Code        string
+	Description string
+}
+
+// Server — фейковый портал. Данные задаются напрямую через поля и методы Add*/Update* до или между синхронизациями.
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	departments []map[string]interface{}
+	groups      []map[string]interface{}
+	users       []map[string]interface{}
+	tasks       []map[string]interface{}
+	timeEntries []map[string]interface{}
+	comments    map[int][]map[string]interface{}
+	checklists  map[int][]map[string]interface{}
+	failures    map[string][]Failure
+	calls       map[string]int
+	requests    []Request
+}
+
+// Request — вызов метода, как его увидел сервер (команды batch записываются отдельно)
+type Request struct {
+	Method string
+	Params map[string]interface{}
+}
+
+// NewServer запускает сервер; URL сервера используется как URL вебхука. Закрывается через Close.
+func NewServer() *Server {
+	s := &Server{
+		comments:   make(map[int][]map[string]interface{}),
+		checklists: make(map[int][]map[string]interface{}),
+		failures:   make(map[string][]Failure),
+		calls:      make(map[string]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *Server) AddDepartment(id int, name string, parentID, headID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.departments = append(s.departments, map[string]interface{}{
+		"ID": strconv.Itoa(id), "NAME": name, "PARENT": strconv.Itoa(parentID), "UF_HEAD": strconv.Itoa(headID),
+	})
+}
+
+func (s *Server) AddGroup(id int, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.groups = append(s.groups, map[string]interface{}{"ID": strconv.Itoa(id), "NAME": name, "ACTIVE": "Y"})
+}
+
+func (s *Server) AddUser(id int, name, lastName, email string, departments ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if departments == nil {
+		departments = []int{}
+	}
+	s.users = append(s.users, map[string]interface{}{
+		"ID": strconv.Itoa(id), "ACTIVE": true, "NAME": name, "LAST_NAME": lastName, "EMAIL": email, "UF_DEPARTMENT": departments,
+	})
+}
+
+// Task — задача фейкового портала в формате tasks.task.list
+type Task struct {
+	ID            int
+	Title         string
+	Status        string
+	ResponsibleID int
+	GroupID       int
+	Changed       time.Time
+}
+
+func (t Task) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"id":            strconv.Itoa(t.ID),
+		"title":         t.Title,
+		"status":        t.Status,
+		"responsibleId": strconv.Itoa(t.ResponsibleID),
+		"createdBy":     strconv.Itoa(t.ResponsibleID),
+		"groupId":       strconv.Itoa(t.GroupID),
+		"changedDate":   t.Changed.Format(time.RFC3339),
+		"createdDate":   t.Changed.Format(time.RFC3339),
+	}
+}
+
+// AddTask добавляет задачу или заменяет существующую с тем же ID
+func (s *Server) AddTask(t Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.tasks {
+		if existing["id"] == strconv.Itoa(t.ID) {
+			s.tasks[i] = t.toMap()
+			return
+		}
+	}
+	s.tasks = append(s.tasks, t.toMap())
+}
+
+// DeleteTask удаляет задачу с портала
+func (s *Server) DeleteTask(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.tasks {
+		if existing["id"] == strconv.Itoa(id) {
+			s.tasks = append(s.tasks[:i], s.tasks[i+1:]...)
+			return
+		}
+	}
+}
+
+// Comment — комментарий задачи в формате task.commentitem.getlist
+type Comment struct {
+	ID       int
+	AuthorID int
+	Author   string
+	Text     string
+	Posted   time.Time
+}
+
+func (c Comment) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"ID":           strconv.Itoa(c.ID),
+		"AUTHOR_ID":    strconv.Itoa(c.AuthorID),
+		"AUTHOR_NAME":  c.Author,
+		"POST_MESSAGE": c.Text,
+		"POST_DATE":    c.Posted.Format(time.RFC3339),
+	}
+}
+
+// ChecklistItem — пункт чек-листа задачи в формате task.checklistitem.getlist. ParentID 0 — корень чек-листа.
+type ChecklistItem struct {
+	ID        int
+	ParentID  int
+	Title     string
+	SortIndex int
+	Complete  bool
+}
+
+func (c ChecklistItem) toMap() map[string]interface{} {
+	complete := "N"
+	if c.Complete {
+		complete = "Y"
+	}
+	return map[string]interface{}{
+		"ID":          strconv.Itoa(c.ID),
+		"PARENT_ID":   strconv.Itoa(c.ParentID),
+		"TITLE":       c.Title,
+		"SORT_INDEX":  strconv.Itoa(c.SortIndex),
+		"IS_COMPLETE": complete,
+	}
+}
+
+// AddComment добавляет комментарий к задаче или заменяет существующий с тем же ID
+func (s *Server) AddComment(taskID int, c Comment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.comments[taskID] = upsertByID(s.comments[taskID], c.toMap())
+}
+
+// DeleteComment удаляет комментарий задачи
+func (s *Server) DeleteComment(taskID, id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.comments[taskID] = removeByID(s.comments[taskID], id)
+}
+
+// AddChecklistItem добавляет пункт чек-листа задачи или заменяет существующий с тем же ID
+func (s *Server) AddChecklistItem(taskID int, item ChecklistItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checklists[taskID] = upsertByID(s.checklists[taskID], item.toMap())
+}
+
+// DeleteChecklistItem удаляет пункт чек-листа задачи
+func (s *Server) DeleteChecklistItem(taskID, id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checklists[taskID] = removeByID(s.checklists[taskID], id)
+}
+
+func upsertByID(items []map[string]interface{}, item map[string]interface{}) []map[string]interface{} {
+	for i, existing := range items {
+		if existing["ID"] == item["ID"] {
+			items[i] = item
+			return items
+		}
+	}
+	return append(items, item)
+}
+
+func removeByID(items []map[string]interface{}, id int) []map[string]interface{} {
+	for i, existing := range items {
+		if existing["ID"] == strconv.Itoa(id) {
+			return append(items[:i], items[i+1:]...)
+		}
+	}
+	return items
+}
+
+// AddTimeEntry добавляет запись учета времени (task.elapseditem.getlist) или заменяет существующую с тем же ID
+func (s *Server) AddTimeEntry(id, taskID, userID, seconds int, start time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timeEntries = upsertByID(s.timeEntries, map[string]interface{}{
+		"ID": strconv.Itoa(id), "TASK_ID": strconv.Itoa(taskID), "USER_ID": strconv.Itoa(userID),
+		"SECONDS": strconv.Itoa(seconds), "DATE_START": start.Format(time.RFC3339), "CREATED_DATE": start.Format(time.RFC3339),
+	})
+}
+
+// DeleteTimeEntry удаляет запись учета времени
+func (s *Server) DeleteTimeEntry(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timeEntries = removeByID(s.timeEntries, id)
+}
+
+// Fail ставит в очередь ошибки для метода: следующие len(failures) вызовов вернут их по порядку.
+// Внутри batch ошибка попадает в result_error соответствующей команды.
+func (s *Server) Fail(method string, failures ...Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = append(s.failures[method], failures...)
+}
+
+// Calls возвращает число вызовов метода (включая команды внутри batch)
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+// Requests возвращает все вызовы метода по порядку
+func (s *Server) Requests(method string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []Request
+	for _, r := range s.requests {
+		if r.Method == method {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+type response struct {
+	Result interface{} `json:"result"`
+	Total  int         `json:"total,omitempty"`
+	Next   int         `json:"next,omitempty"`
+	Time   struct {
+		Start float64 `json:"start"`
+	} `json:"time"`
+}
+
+type errorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	params := map[string]interface{}{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"INVALID_REQUEST", err.Error()})
+			return
+		}
+	}
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+
+	if method == "batch" {
+		s.serveBatch(w, params)
+		return
+	}
+	result, total, next, failure := s.dispatch(method, params)
+	if failure != nil {
+		status := failure.Status
+		if status == 0 {
+			status = http.StatusBadRequest
+		}
+		writeJSON(w, status, errorResponse{failure.Code, failure.Description})
+		return
+	}
+	writeJSON(w, http.StatusOK, response{Result: result, Total: total, Next: next})
+}
+
+func (s *Server) serveBatch(w http.ResponseWriter, params map[string]interface{}) {
+	s.mu.Lock()
+	s.calls["batch"]++
+	s.mu.Unlock()
+
+	cmds, _ := params["cmd"].(map[string]interface{})
+	results := map[string]interface{}{}
+	errs := map[string]interface{}{}
+	totals := map[string]interface{}{}
+	nexts := map[string]interface{}{}
+	for key, raw := range cmds {
+		cmd, _ := raw.(string)
+		method, query, _ := strings.Cut(cmd, "?")
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			errs[key] = errorResponse{"INVALID_REQUEST", err.Error()}
+			continue
+		}
+		result, total, next, failure := s.dispatch(method, decodeQuery(values))
+		if failure != nil {
+			errs[key] = errorResponse{failure.Code, failure.Description}
+			continue
+		}
+		results[key] = result
+		if total > 0 {
+			totals[key] = total
+		}
+		if next > 0 {
+			nexts[key] = next
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"result": map[string]interface{}{
+		"result":       phpArray(results),
+		"result_error": phpArray(errs),
+		"result_total": phpArray(totals),
+		"result_next":  phpArray(nexts),
+	}})
+}
+
+// phpArray воспроизводит json_encode из PHP: пустой ассоциативный массив кодируется как []
+func phpArray(m map[string]interface{}) interface{} {
+	if len(m) == 0 {
+		return []interface{}{}
+	}
+	return m
+}
+
+func (s *Server) dispatch(method string, params map[string]interface{}) (result interface{}, total, next int, failure *Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[method]++
+	s.requests = append(s.requests, Request{Method: method, Params: params})
+	if queue := s.failures[method]; len(queue) > 0 {
+		f := queue[0]
+		s.failures[method] = queue[1:]
+		return nil, 0, 0, &f
+	}
+
+	switch method {
+	case "profile":
+		return map[string]interface{}{"ID": "1", "ADMIN": true}, 0, 0, nil
+	case "department.get":
+		return s.departments, 0, 0, nil
+	case "sonet_group.get":
+		page, total, next := paginate(s.groups, params)
+		return page, total, next, nil
+	case "user.get":
+		page, total, next := paginate(s.users, params)
+		return page, total, next, nil
+	case "tasks.task.list":
+		page, total, next := paginate(s.filterTasks(params), params)
+		return map[string]interface{}{"tasks": page}, total, next, nil
+	case "tasks.task.get":
+		id := fmt.Sprint(param(params, "taskId"))
+		for _, t := range s.tasks {
+			if t["id"] == id {
+				return map[string]interface{}{"task": t}, 0, 0, nil
+			}
+		}
+		return nil, 0, 0, &Failure{Status: http.StatusBadRequest, Code: "ERROR_CORE", Description: "Task not found"}
+	case "task.elapseditem.getlist":
+		return s.filterTimeEntries(params), 0, 0, nil
+	case "task.commentitem.getlist":
+		return sortedBy(s.comments[toInt(param(params, "TASKID"))], "ID"), 0, 0, nil
+	case "task.checklistitem.getlist":
+		return sortedBy(s.checklists[toInt(param(params, "TASKID"))], "SORT_INDEX"), 0, 0, nil
+	}
+	return nil, 0, 0, &Failure{Status: http.StatusNotFound, Code: "ERROR_METHOD_NOT_FOUND", Description: "Method not found!"}
+}
+
+// filterTasks применяет filter[>ID] / filter[>CHANGED_DATE] и order[ID]
+func (s *Server) filterTasks(params map[string]interface{}) []map[string]interface{} {
+	filter, _ := param(params, "filter").(map[string]interface{})
+	minID := toInt(filter[">ID"])
+	var changedAfter time.Time
+	if v, ok := filter[">CHANGED_DATE"]; ok {
+		changedAfter, _ = time.Parse(time.RFC3339, fmt.Sprint(v))
+	}
+
+	var result []map[string]interface{}
+	for _, t := range s.tasks {
+		if toInt(t["id"]) <= minID {
+			continue
+		}
+		if !changedAfter.IsZero() {
+			changed, _ := time.Parse(time.RFC3339, t["changedDate"].(string))
+			if !changed.After(changedAfter) {
+				continue
+			}
+		}
+		result = append(result, t)
+	}
+
+	order, _ := param(params, "order").(map[string]interface{})
+	desc := strings.EqualFold(fmt.Sprint(order["ID"]), "desc")
+	sort.SliceStable(result, func(i, j int) bool {
+		if desc {
+			return toInt(result[i]["id"]) > toInt(result[j]["id"])
+		}
+		return toInt(result[i]["id"]) < toInt(result[j]["id"])
+	})
+	return result
+}
+
+// filterTimeEntries отдает записи с ID больше FILTER[>ID] и созданные не раньше FILTER[>=CREATED_DATE]
+// страницей NAV_PARAMS.nPageSize
+func (s *Server) filterTimeEntries(params map[string]interface{}) []map[string]interface{} {
+	filter, _ := param(params, "FILTER").(map[string]interface{})
+	minID := toInt(filter[">ID"])
+	since, _ := time.Parse(time.RFC3339, fmt.Sprint(filter[">=CREATED_DATE"]))
+	result := []map[string]interface{}{}
+	for _, e := range s.timeEntries {
+		created, _ := time.Parse(time.RFC3339, e["CREATED_DATE"].(string))
+		if toInt(e["ID"]) > minID && !created.Before(since) {
+			result = append(result, e)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return toInt(result[i]["ID"]) < toInt(result[j]["ID"]) })
+	if len(result) > PageSize {
+		result = result[:PageSize]
+	}
+	return result
+}
+
+// sortedBy возвращает копию списка, упорядоченную по числовому полю key (ORDER методов задачи)
+func sortedBy(items []map[string]interface{}, key string) []map[string]interface{} {
+	result := append([]map[string]interface{}{}, items...)
+	sort.SliceStable(result, func(i, j int) bool { return toInt(result[i][key]) < toInt(result[j][key]) })
+	return result
+}
+
+func paginate(items []map[string]interface{}, params map[string]interface{}) (page []map[string]interface{}, total, next int) {
+	start := toInt(param(params, "start"))
+	total = len(items)
+	if start >= total {
+		return []map[string]interface{}{}, total, 0
+	}
+	end := start + PageSize
+	if end < total {
+		next = end
+	} else {
+		end = total
+	}
+	return items[start:end], total, next
+}
+
+// param ищет параметр без учета регистра: Bitrix принимает и filter, и FILTER
+func param(params map[string]interface{}, name string) interface{} {
+	for k, v := range params {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return nil
+}
+
+func toInt(v interface{}) int {
+	switch val := v.(type) {
+	case float64:
+		return int(val)
+	case int:
+		return val
+	case string:
+		n, _ := strconv.Atoi(val)
+		return n
+	}
+	return 0
+}
+
+// decodeQuery разворачивает query-строку в формате PHP (filter[>ID]=0&select[0]=id) во вложенные map
+func decodeQuery(values url.Values) map[string]interface{} {
+	result := map[string]interface{}{}
+	for key, vals := range values {
+		if len(vals) == 0 {
+			continue
+		}
+		parts := splitKey(key)
+		node := result
+		for i, part := range parts {
+			if i == len(parts)-1 {
+				node[part] = vals[0]
+				break
+			}
+			child, ok := node[part].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				node[part] = child
+			}
+			node = child
+		}
+	}
+	return result
+}
+
+// splitKey: "filter[>ID]" -> ["filter", ">ID"]
+func splitKey(key string) []string {
+	name, rest, found := strings.Cut(key, "[")
+	if !found {
+		return []string{key}
+	}
+	parts := []string{name}
+	for _, p := range strings.Split(strings.TrimSuffix(rest, "]"), "][") {
+		parts = append(parts, p)
+	}
+	return parts
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
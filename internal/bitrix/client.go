@@ -0,0 +1,252 @@
+package bitrix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Лимиты входящего вебхука Bitrix24: 2 запроса в секунду и 480 секунд работы методов за 10 минут
+const (
+	defaultRate        = 2.0
+	defaultBurst       = 2.0
+	defaultMaxRetries  = 5
+	defaultBaseDelay   = 500 * time.Millisecond
+	defaultMaxDelay    = 30 * time.Second
+	operatingThreshold = 420.0
+)
+
+// ErrNotConfigured — в настройках не указан URL вебхука
+var ErrNotConfigured = errors.New("bitrix webhook URL not configured")
+
+// APIError — ошибка Bitrix24 из тела ответа ({"error": "...", "error_description": "..."}) или из HTTP-статуса
+type APIError struct {
+	Method      string
+	StatusCode  int
+	Code        string
+	Description string
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("bitrix %s: HTTP %d", e.Method, e.StatusCode)
+	}
+	return fmt.Sprintf("bitrix %s: %s: %s (HTTP %d)", e.Method, e.Code, e.Description, e.StatusCode)
+}
+
+// Temporary сообщает, имеет ли смысл повторить запрос
+func (e *APIError) Temporary() bool {
+	switch e.Code {
+	case "QUERY_LIMIT_EXCEEDED", "OPERATION_TIME_LIMIT", "INTERNAL_SERVER_ERROR":
+		return true
+	}
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// API — транспорт Bitrix24, через который работает SyncManager. Основная реализация — *Client;
+// в тестах тот же *Client направляется на фейковый сервер из пакета bitrixtest.
+type API interface {
+	Call(ctx context.Context, method string, payload map[string]interface{}) ([]byte, error)
+	Batch(ctx context.Context, cmds []BatchCommand) ([]BatchResult, error)
+}
+
+// Client — REST-клиент вебхука Bitrix24 с ограничением частоты запросов и повторами с экспоненциальной задержкой.
+// Лимитер и http.Client общие для всех клиентов процесса: лимит Bitrix считается на портал, а не на синхронизацию.
+type Client struct {
+	webhookURL string
+	httpClient *http.Client
+	limiter    *tokenBucket
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+var (
+	sharedHTTPClient = &http.Client{Timeout: 60 * time.Second}
+	sharedLimiter    = newTokenBucket(defaultRate, defaultBurst)
+)
+
+func NewClient(webhookURL string) *Client {
+	return &Client{
+		webhookURL: webhookURL,
+		httpClient: sharedHTTPClient,
+		limiter:    sharedLimiter,
+		maxRetries: defaultMaxRetries,
+		baseDelay:  defaultBaseDelay,
+		maxDelay:   defaultMaxDelay,
+	}
+}
+
+// responseMeta — служебная часть любого ответа Bitrix24
+type responseMeta struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	Time             struct {
+		Operating        float64 `json:"operating"`
+		OperatingResetAt int64   `json:"operating_reset_at"`
+	} `json:"time"`
+}
+
+// Call вызывает метод REST API и возвращает тело успешного ответа.
+// Временные ошибки (сеть, 5xx, QUERY_LIMIT_EXCEEDED) повторяются до maxRetries раз.
+func (c *Client) Call(ctx context.Context, method string, payload map[string]interface{}) ([]byte, error) {
+	if c.webhookURL == "" {
+		return nil, ErrNotConfigured
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		resp, retryAfter, err := c.do(ctx, method, body)
+		if err == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && !apiErr.Temporary() {
+			return nil, err
+		}
+		if attempt >= c.maxRetries {
+			return nil, fmt.Errorf("bitrix %s: giving up after %d attempts: %w", method, attempt+1, err)
+		}
+
+		delay := c.backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// do выполняет одну попытку. retryAfter — пауза, которую просит сервер (Retry-After), если есть.
+func (c *Client) do(ctx context.Context, method string, body []byte) (data []byte, retryAfter time.Duration, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/%s", c.webhookURL, method), bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if s := resp.Header.Get("Retry-After"); s != "" {
+		if sec, convErr := strconv.Atoi(s); convErr == nil {
+			retryAfter = time.Duration(sec) * time.Second
+		}
+	}
+
+	var meta responseMeta
+	jsonErr := json.Unmarshal(data, &meta)
+	if jsonErr == nil && meta.Time.Operating >= operatingThreshold && meta.Time.OperatingResetAt > 0 {
+		// Метод почти исчерпал лимит времени работы — притормаживаем до сброса счетчика
+		c.limiter.PauseUntil(time.Unix(meta.Time.OperatingResetAt, 0))
+	}
+	if meta.Error != "" || resp.StatusCode != http.StatusOK {
+		apiErr := &APIError{Method: method, StatusCode: resp.StatusCode, Code: meta.Error, Description: meta.ErrorDescription}
+		if apiErr.Code == "QUERY_LIMIT_EXCEEDED" {
+			c.limiter.PauseUntil(time.Now().Add(time.Second))
+		}
+		return nil, retryAfter, apiErr
+	}
+	if jsonErr != nil {
+		return nil, 0, fmt.Errorf("bitrix %s: invalid JSON response: %w", method, jsonErr)
+	}
+	return data, 0, nil
+}
+
+// backoff — экспоненциальная задержка с джиттером в диапазоне [d/2, d]
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.baseDelay << attempt
+	if d <= 0 || d > c.maxDelay {
+		d = c.maxDelay
+	}
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// tokenBucket — ограничитель частоты: rate токенов в секунду, не больше burst подряд
+type tokenBucket struct {
+	mu          sync.Mutex
+	rate        float64
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Wait блокируется до появления токена или отмены контекста
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+
+		var wait time.Duration
+		switch {
+		case now.Before(b.pausedUntil):
+			wait = b.pausedUntil.Sub(now)
+		case b.tokens >= 1:
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		default:
+			wait = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		}
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// PauseUntil запрещает запросы до момента t (например, после QUERY_LIMIT_EXCEEDED)
+func (b *tokenBucket) PauseUntil(t time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if t.After(b.pausedUntil) {
+		b.pausedUntil = t
+		b.tokens = 0
+	}
+}
@@ -0,0 +1,121 @@
+package bitrix
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testClient(url string) *Client {
+	c := NewClient(url)
+	c.limiter = newTokenBucket(1000, 1000)
+	c.baseDelay = time.Millisecond
+	c.maxDelay = 5 * time.Millisecond
+	return c
+}
+
+func TestClientRetriesTransientErrors(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"QUERY_LIMIT_EXCEEDED","error_description":"Too many requests"}`))
+		default:
+			w.Write([]byte(`{"result":{"ok":true}}`))
+		}
+	}))
+	defer srv.Close()
+
+	resp, err := testClient(srv.URL).Call(context.Background(), "profile", nil)
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if string(resp) != `{"result":{"ok":true}}` {
+		t.Errorf("unexpected body %s", resp)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestClientTypedError(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"NO_AUTH_FOUND","error_description":"Wrong authorization data"}`))
+	}))
+	defer srv.Close()
+
+	_, err := testClient(srv.URL).Call(context.Background(), "tasks.task.list", nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %v", err)
+	}
+	if apiErr.Code != "NO_AUTH_FOUND" || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Method != "tasks.task.list" {
+		t.Errorf("unexpected error fields: %+v", apiErr)
+	}
+	if calls != 1 {
+		t.Errorf("permanent error must not be retried, got %d attempts", calls)
+	}
+}
+
+func TestClientGivesUp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	c := testClient(srv.URL)
+	c.maxRetries = 2
+	_, err := c.Call(context.Background(), "profile", nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected wrapped 502 APIError, got %v", err)
+	}
+}
+
+func TestClientContextCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	c := testClient(srv.URL)
+	c.baseDelay = time.Hour
+	c.maxDelay = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := c.Call(ctx, "profile", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context deadline, got %v", err)
+	}
+}
+
+func TestClientNotConfigured(t *testing.T) {
+	if _, err := NewClient("").Call(context.Background(), "profile", nil); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("expected ErrNotConfigured, got %v", err)
+	}
+}
+
+func TestTokenBucketRate(t *testing.T) {
+	b := newTokenBucket(20, 1)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := b.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Первый токен есть сразу, еще два — по 50 мс
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("rate limit not applied: 3 tokens in %v", elapsed)
+	}
+}
@@ -0,0 +1,165 @@
+package bitrix
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+	"my_pocketbase_app/internal/secrets"
+)
+
+// Событие исходящего вебхука Bitrix24 (form-encoded):
+// event=ONTASKUPDATE&data[FIELDS_AFTER][ID]=123&auth[application_token]=...
+const (
+	EventTaskAdd    = "ONTASKADD"
+	EventTaskUpdate = "ONTASKUPDATE"
+	EventTaskDelete = "ONTASKDELETE"
+)
+
+// HandleEvent принимает события задач от Bitrix24. Токен сверяется с секретом bitrix_application_token,
+// сама синхронизация задачи выполняется в фоне, чтобы Bitrix сразу получил ответ.
+func HandleEvent(app core.App, e *core.RequestEvent) error {
+	if err := e.Request.ParseForm(); err != nil {
+		return e.BadRequestError("Invalid form payload", err)
+	}
+
+	expected, err := secrets.Get(app, secrets.BitrixApplicationToken)
+	if err != nil && !errors.Is(err, secrets.ErrNotFound) {
+		log.Printf("[Bitrix] Application token is not available: %v", err)
+	}
+	token := e.Request.PostForm.Get("auth[application_token]")
+	if expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		return e.ForbiddenError("Invalid application token", nil)
+	}
+
+	event := strings.ToUpper(e.Request.PostForm.Get("event"))
+	taskId := e.Request.PostForm.Get("data[FIELDS_AFTER][ID]")
+	if taskId == "" {
+		taskId = e.Request.PostForm.Get("data[FIELDS_BEFORE][ID]")
+	}
+	bxId, ok := parseBitrixID(taskId)
+	if !ok {
+		return e.BadRequestError("Task ID is required", nil)
+	}
+
+	switch event {
+	case EventTaskAdd, EventTaskUpdate, EventTaskDelete:
+		go func() {
+			if err := NewSyncManager(app).HandleTaskEvent(event, bxId); err != nil {
+				log.Printf("[Bitrix] Event %s for task %d failed: %v", event, bxId, err)
+			}
+		}()
+	default:
+		return e.BadRequestError(fmt.Sprintf("Unsupported event %q", event), nil)
+	}
+	return e.String(http.StatusOK, "OK")
+}
+
+// HandleSetApplicationToken сохраняет токен исходящего вебхука Bitrix24
+// (POST /api/bitrix/application-token, только superadmin). В ответе токен не возвращается.
+func HandleSetApplicationToken(app core.App, e *core.RequestEvent) error {
+	if e.Auth == nil || !e.Auth.GetBool("superadmin") {
+		return e.ForbiddenError("Only admins can change the Bitrix application token", nil)
+	}
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := e.BindBody(&body); err != nil {
+		return e.BadRequestError("Invalid request body", err)
+	}
+	token := strings.TrimSpace(body.Token)
+	if token == "" {
+		return e.BadRequestError("token is required", nil)
+	}
+	if err := secrets.Set(app, secrets.BitrixApplicationToken, token); err != nil {
+		return e.InternalServerError("Failed to store the application token", err)
+	}
+	return e.JSON(http.StatusOK, map[string]interface{}{"updated": true})
+}
+
+// HandleTaskEvent применяет событие задачи. Ждет завершения текущей синхронизации или сверки (syncMu),
+// чтобы не писать в те же записи параллельно с ними.
+func (s *SyncManager) HandleTaskEvent(event string, bxId int64) error {
+	syncMu.Lock()
+	defer syncMu.Unlock()
+	switch event {
+	case EventTaskAdd, EventTaskUpdate:
+		return s.SyncTask(bxId)
+	case EventTaskDelete:
+		return s.DeleteTask(bxId)
+	}
+	return fmt.Errorf("unsupported event %q", event)
+}
+
+// errTaskNotFound — tasks.task.get не нашел задачу (удалена или недоступна вебхуку)
+var errTaskNotFound = errors.New("task not found in bitrix")
+
+// fetchTask загружает одну задачу через tasks.task.get
+func (s *SyncManager) fetchTask(bxId int64) (BxTask, error) {
+	resp, err := s.call("tasks.task.get", map[string]interface{}{"taskId": bxId, "select": taskSelectFields})
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Code == "ERROR_CORE" {
+		return BxTask{}, errTaskNotFound
+	}
+	if err != nil {
+		return BxTask{}, err
+	}
+	var data BxResponse[struct {
+		Task BxTask `json:"task"`
+	}]
+	if err := json.Unmarshal(resp, &data); err != nil {
+		return BxTask{}, fmt.Errorf("bitrix tasks.task.get: invalid response: %w", err)
+	}
+	if data.Result.Task.ID == "" {
+		return BxTask{}, errTaskNotFound
+	}
+	return data.Result.Task, nil
+}
+
+// SyncTask загружает одну задачу из Bitrix24 и сохраняет ее через SaveTaskOptimized.
+// Связи и кэш активных задач загружаются только для этой задачи.
+func (s *SyncManager) SyncTask(bxId int64) error {
+	task, err := s.fetchTask(bxId)
+	if err != nil {
+		return fmt.Errorf("bitrix tasks.task.get %d: %w", bxId, err)
+	}
+
+	collection, err := s.app.FindCollectionByNameOrId("bitrix_tasks")
+	if err != nil {
+		return err
+	}
+	activeCollection, err := s.app.FindCollectionByNameOrId("bitrix_tasks_active")
+	if err != nil {
+		return err
+	}
+	userMap, groupMap := s.relationMapsFor(task)
+	s.SaveTaskOptimized(task, userMap, groupMap, collection, activeCollection, s.taskCacheFor(task.ID))
+	return s.SyncTaskDetails([]int64{bxId})
+}
+
+// DeleteTask удаляет задачу из bitrix_tasks / bitrix_tasks_active с записью в bitrix_deletion_logs.
+// Событие удаления не принимается на веру: задача удаляется, только если tasks.task.get ее не находит.
+func (s *SyncManager) DeleteTask(bxId int64) error {
+	if _, err := s.fetchTask(bxId); err == nil {
+		log.Printf("[Bitrix] Task %d still exists in Bitrix24, delete event ignored", bxId)
+		return nil
+	} else if !errors.Is(err, errTaskNotFound) {
+		return fmt.Errorf("confirm deletion of task %d: %w", bxId, err)
+	}
+
+	var collections []string
+	for _, name := range []string{"bitrix_tasks", "bitrix_tasks_active"} {
+		if rec, _ := s.app.FindFirstRecordByFilter(name, "bitrix_id = {:id}", map[string]interface{}{"id": bxId}); rec != nil {
+			collections = append(collections, name)
+		}
+	}
+	if len(collections) == 0 {
+		return nil
+	}
+	return s.removeDeletedTask(bxId, collections)
+}
@@ -0,0 +1,204 @@
+package bitrix
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+	"my_pocketbase_app/internal/bitrix/bitrixtest"
+	"my_pocketbase_app/internal/secrets"
+)
+
+const testAppToken = "app-token"
+
+// newEventApp — база после полной синхронизации с фейковым порталом; вебхук и токен событий лежат в секретах
+func newEventApp(t *testing.T) (core.App, *bitrixtest.Server) {
+	t.Helper()
+	app := newTestApp(t)
+	srv := newFakePortal(t, time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC))
+	t.Setenv(secrets.EnvKey, "0123456789abcdef0123456789abcdef")
+	if err := secrets.Set(app, secrets.BitrixWebhook, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := secrets.Set(app, secrets.BitrixApplicationToken, testAppToken); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewSyncManager(app).WithClient(testClient(srv.URL)).SyncAll(); err != nil {
+		t.Fatalf("SyncAll failed: %v", err)
+	}
+	return app, srv
+}
+
+func postEvent(t *testing.T, app core.App, form url.Values) (*httptest.ResponseRecorder, error) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/bitrix/events", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	e := &core.RequestEvent{App: app, Event: router.Event{Request: req, Response: rec}}
+	return rec, HandleEvent(app, e)
+}
+
+func eventForm(event, taskId, token string) url.Values {
+	return url.Values{"event": {event}, "data[FIELDS_AFTER][ID]": {taskId}, "auth[application_token]": {token}}
+}
+
+// waitFor ждет, пока фоновая обработка события не приведет базу в нужное состояние
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func taskTitle(app core.App, bxId int) string {
+	rec, err := app.FindFirstRecordByFilter("bitrix_tasks", "bitrix_id = {:id}", map[string]interface{}{"id": bxId})
+	if err != nil {
+		return ""
+	}
+	return rec.GetString("title")
+}
+
+func TestHandleEventRejectsInvalidToken(t *testing.T) {
+	app, _ := newEventApp(t)
+
+	for name, token := range map[string]string{"wrong": "guess", "empty": ""} {
+		_, err := postEvent(t, app, eventForm(EventTaskDelete, "7", token))
+		var apiErr *router.ApiError
+		if !errors.As(err, &apiErr) || apiErr.Status != http.StatusForbidden {
+			t.Errorf("%s token: expected 403, got %v", name, err)
+		}
+	}
+	if taskTitle(app, 7) == "" {
+		t.Error("task 7 must not be touched by a rejected event")
+	}
+}
+
+func TestHandleEventSyncsUpdatedTask(t *testing.T) {
+	app, srv := newEventApp(t)
+	srv.AddTask(bitrixtest.Task{ID: 7, Title: "Задача 7 (изменена)", Status: "2", ResponsibleID: 2, GroupID: 10, Changed: time.Now()})
+
+	rec, err := postEvent(t, app, eventForm(EventTaskUpdate, "7", testAppToken))
+	if err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("event rejected: code=%d err=%v", rec.Code, err)
+	}
+	waitFor(t, "task 7 update", func() bool { return taskTitle(app, 7) == "Задача 7 (изменена)" })
+
+	task, _ := app.FindFirstRecordByFilter("bitrix_tasks", "bitrix_id = 7")
+	ivan, _ := app.FindFirstRecordByFilter("bitrix_users", "bitrix_id = 2")
+	if task.GetString("responsible") != ivan.Id || task.GetString("group") == "" {
+		t.Errorf("relations not resolved for single task: responsible=%q group=%q", task.GetString("responsible"), task.GetString("group"))
+	}
+}
+
+// Событие удаления подтверждается через tasks.task.get: задача, которая еще есть в Bitrix, остается
+func TestDeleteEventConfirmedWithBitrix(t *testing.T) {
+	app, srv := newEventApp(t)
+	s := NewSyncManager(app).WithClient(testClient(srv.URL))
+
+	if err := s.HandleTaskEvent(EventTaskDelete, 7); err != nil {
+		t.Fatalf("forged delete: %v", err)
+	}
+	if taskTitle(app, 7) == "" {
+		t.Fatal("task 7 still exists in Bitrix and must not be deleted")
+	}
+	if n := countRecords(t, app, "bitrix_deletion_logs", nil); n != 0 {
+		t.Errorf("deletion logs = %d, want 0", n)
+	}
+
+	srv.DeleteTask(7)
+	if err := s.HandleTaskEvent(EventTaskDelete, 7); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if taskTitle(app, 7) != "" {
+		t.Error("task 7 deleted in Bitrix must be removed")
+	}
+	if n := countRecords(t, app, "bitrix_deletion_logs", nil); n != 1 {
+		t.Errorf("deletion logs = %d, want 1", n)
+	}
+}
+
+// Если Bitrix не ответил, удаление не подтверждено — локальная задача остается
+func TestDeleteEventKeepsTaskOnBitrixError(t *testing.T) {
+	app, srv := newEventApp(t)
+	srv.DeleteTask(7)
+	srv.Fail("tasks.task.get", bitrixtest.Failure{Status: http.StatusUnauthorized, Code: "insufficient_scope"})
+
+	if err := NewSyncManager(app).WithClient(testClient(srv.URL)).HandleTaskEvent(EventTaskDelete, 7); err == nil {
+		t.Error("expected an error when deletion cannot be confirmed")
+	}
+	if taskTitle(app, 7) == "" {
+		t.Error("task 7 must be kept while deletion is unconfirmed")
+	}
+}
+
+func TestTaskEventWaitsForRunningSync(t *testing.T) {
+	app, srv := newEventApp(t)
+	srv.AddTask(bitrixtest.Task{ID: 9, Title: "Задача 9 (изменена)", Status: "2", ResponsibleID: 2, Changed: time.Now()})
+
+	running := NewSyncManager(app)
+	if err := running.begin(RunFull); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- NewSyncManager(app).WithClient(testClient(srv.URL)).HandleTaskEvent(EventTaskUpdate, 9)
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("event finished while a sync was running: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	running.end(nil)
+	if err := <-done; err != nil {
+		t.Fatalf("event after sync: %v", err)
+	}
+	if taskTitle(app, 9) != "Задача 9 (изменена)" {
+		t.Error("task 9 not updated after the sync finished")
+	}
+}
+
+func TestSetApplicationToken(t *testing.T) {
+	app := newTestApp(t)
+	t.Setenv(secrets.EnvKey, "0123456789abcdef0123456789abcdef")
+	users, _ := app.FindCollectionByNameOrId("users")
+
+	call := func(auth *core.Record, body string) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(http.MethodPost, "/api/bitrix/application-token", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		e := &core.RequestEvent{App: app, Auth: auth, Event: router.Event{Request: req, Response: rec}}
+		return rec, HandleSetApplicationToken(app, e)
+	}
+
+	employee := core.NewRecord(users)
+	if _, err := call(employee, `{"token":"x"}`); err == nil {
+		t.Error("non-admin must not set the token")
+	}
+
+	admin := core.NewRecord(users)
+	admin.Set("superadmin", true)
+	if _, err := call(admin, `{"token":"  "}`); err == nil {
+		t.Error("empty token must be rejected")
+	}
+	rec, err := call(admin, `{"token":"new-token"}`)
+	if err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("set token: code=%d err=%v", rec.Code, err)
+	}
+	if strings.Contains(rec.Body.String(), "new-token") {
+		t.Error("token must not be echoed back")
+	}
+	if got, _ := secrets.Get(app, secrets.BitrixApplicationToken); got != "new-token" {
+		t.Errorf("stored token = %q", got)
+	}
+}
@@ -0,0 +1,207 @@
+package bitrix
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Способы сопоставления пользователя системы с пользователем Bitrix24
+const (
+	MatchByEmail = "email"
+	MatchByName  = "name"
+)
+
+// Account — минимальное описание учетной записи для сопоставления (users или bitrix_users)
+type Account struct {
+	ID     string `json:"id"`
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+}
+
+// AccountMatch — кандидаты Bitrix24 для одного пользователя системы
+type AccountMatch struct {
+	UserID     string   `json:"user_id"`
+	By         string   `json:"by,omitempty"`
+	Candidates []string `json:"candidates"`
+}
+
+// MatchAccounts подбирает кандидатов из Bitrix24 для каждого пользователя: сначала по email,
+// и только если по email никого нет — по имени (без учета регистра, порядка слов и ё/е).
+// Если среди кандидатов есть активные, неактивные отбрасываются.
+func MatchAccounts(users, bitrix []Account) []AccountMatch {
+	byEmail := make(map[string][]Account)
+	byName := make(map[string][]Account)
+	for _, b := range bitrix {
+		if email := normalizeEmail(b.Email); email != "" {
+			byEmail[email] = append(byEmail[email], b)
+		}
+		if name := normalizeName(b.Name); name != "" {
+			byName[name] = append(byName[name], b)
+		}
+	}
+
+	matches := make([]AccountMatch, 0, len(users))
+	for _, u := range users {
+		m := AccountMatch{UserID: u.ID}
+		candidates := byEmail[normalizeEmail(u.Email)]
+		if normalizeEmail(u.Email) != "" && len(candidates) > 0 {
+			m.By = MatchByEmail
+		} else if name := normalizeName(u.Name); name != "" && len(byName[name]) > 0 {
+			m.By = MatchByName
+			candidates = byName[name]
+		} else {
+			candidates = nil
+		}
+		m.Candidates = preferActive(candidates)
+		matches = append(matches, m)
+	}
+	return matches
+}
+
+func preferActive(candidates []Account) []string {
+	hasActive := false
+	for _, c := range candidates {
+		hasActive = hasActive || c.Active
+	}
+	ids := []string{}
+	for _, c := range candidates {
+		if c.Active || !hasActive {
+			ids = append(ids, c.ID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// normalizeName приводит "Иванов  Пётр" и "петр иванов" к одному виду
+func normalizeName(name string) string {
+	name = strings.ReplaceAll(strings.ToLower(name), "ё", "е")
+	words := strings.Fields(name)
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// AccountLinkReport — состояние привязки users.bitrix_user
+type AccountLinkReport struct {
+	Linked    int            `json:"linked"`
+	Unlinked  []AccountMatch `json:"unlinked"`  // кандидатов нет или кандидат занят
+	Conflicts []AccountMatch `json:"conflicts"` // несколько кандидатов или один кандидат у нескольких пользователей
+}
+
+// LinkAccounts привязывает непривязанных пользователей системы к пользователям Bitrix24 по MatchAccounts.
+// Неоднозначные совпадения не привязываются, а попадают в Conflicts. Существующие привязки не меняются.
+func LinkAccounts(app core.App, apply bool) (*AccountLinkReport, error) {
+	sysUsers, err := app.FindAllRecords("users")
+	if err != nil {
+		return nil, err
+	}
+	bxUsers, err := app.FindAllRecords("bitrix_users")
+	if err != nil {
+		return nil, err
+	}
+
+	taken := make(map[string]bool)
+	var unlinked []Account
+	for _, u := range sysUsers {
+		if bx := u.GetString("bitrix_user"); bx != "" {
+			taken[bx] = true
+			continue
+		}
+		unlinked = append(unlinked, Account{ID: u.Id, Email: u.Email(), Name: u.GetString("name")})
+	}
+	bitrix := make([]Account, 0, len(bxUsers))
+	for _, b := range bxUsers {
+		bitrix = append(bitrix, Account{ID: b.Id, Email: b.GetString("email"), Name: b.GetString("full_name"), Active: b.GetBool("active")})
+	}
+
+	matches := MatchAccounts(unlinked, bitrix)
+	claims := make(map[string]int) // кандидат -> сколько пользователей претендуют на него единственным кандидатом
+	for _, m := range matches {
+		if len(m.Candidates) == 1 {
+			claims[m.Candidates[0]]++
+		}
+	}
+
+	report := &AccountLinkReport{Unlinked: []AccountMatch{}, Conflicts: []AccountMatch{}}
+	for _, m := range matches {
+		switch {
+		case len(m.Candidates) > 1 || (len(m.Candidates) == 1 && claims[m.Candidates[0]] > 1):
+			report.Conflicts = append(report.Conflicts, m)
+		case len(m.Candidates) == 0 || taken[m.Candidates[0]]:
+			report.Unlinked = append(report.Unlinked, m)
+		case apply:
+			user, err := app.FindRecordById("users", m.UserID)
+			if err != nil {
+				return nil, err
+			}
+			user.Set("bitrix_user", m.Candidates[0])
+			if err := app.Save(user); err != nil {
+				log.Printf("[Bitrix] Failed to link user %s: %v", m.UserID, err)
+				report.Unlinked = append(report.Unlinked, m)
+				continue
+			}
+			log.Printf("[Bitrix] Auto-linked system user %s to bitrix user %s (by %s)", m.UserID, m.Candidates[0], m.By)
+			report.Linked++
+		default:
+			report.Unlinked = append(report.Unlinked, m)
+		}
+	}
+	for _, m := range report.Conflicts {
+		log.Printf("[Bitrix] Ambiguous bitrix account for user %s (by %s): %v", m.UserID, m.By, m.Candidates)
+	}
+	return report, nil
+}
+
+// HandleAccountLinks показывает непривязанных пользователей и конфликты (GET /api/bitrix/accounts)
+func HandleAccountLinks(app core.App, e *core.RequestEvent) error {
+	if e.Auth == nil || !e.Auth.GetBool("superadmin") {
+		return e.ForbiddenError("Only admins can manage account links", nil)
+	}
+	report, err := LinkAccounts(app, false)
+	if err != nil {
+		return e.InternalServerError("Failed to match accounts", err)
+	}
+	return e.JSON(http.StatusOK, report)
+}
+
+// HandleLinkAccount вручную привязывает пользователя к пользователю Bitrix24 (POST /api/bitrix/accounts/link).
+// Пустой bitrix_user снимает привязку.
+func HandleLinkAccount(app core.App, e *core.RequestEvent) error {
+	if e.Auth == nil || !e.Auth.GetBool("superadmin") {
+		return e.ForbiddenError("Only admins can manage account links", nil)
+	}
+	var body struct {
+		User       string `json:"user"`
+		BitrixUser string `json:"bitrix_user"`
+	}
+	if err := e.BindBody(&body); err != nil || body.User == "" {
+		return e.BadRequestError("user is required", err)
+	}
+	user, err := app.FindRecordById("users", body.User)
+	if err != nil {
+		return e.NotFoundError("User not found", err)
+	}
+	if body.BitrixUser != "" {
+		if _, err := app.FindRecordById("bitrix_users", body.BitrixUser); err != nil {
+			return e.NotFoundError("Bitrix user not found", err)
+		}
+		other, _ := app.FindFirstRecordByFilter("users", "bitrix_user = {:bx} && id != {:id}", map[string]interface{}{"bx": body.BitrixUser, "id": user.Id})
+		if other != nil {
+			return e.JSON(http.StatusConflict, map[string]string{"message": "Bitrix user is already linked to " + other.GetString("name")})
+		}
+	}
+	user.Set("bitrix_user", body.BitrixUser)
+	if err := app.Save(user); err != nil {
+		return e.InternalServerError("Failed to link account", err)
+	}
+	return e.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,54 @@
+package bitrix
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Иван Петров", "иван петров"},
+		{"Петров  Иван", "иван петров"},
+		{"  Пётр Сидоров ", "петр сидоров"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeName(tt.in); got != tt.want {
+			t.Errorf("normalizeName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMatchAccounts(t *testing.T) {
+	bitrix := []Account{
+		{ID: "b1", Email: "ivan@corp.ru", Name: "Иван Петров", Active: true},
+		{ID: "b2", Email: "", Name: "Анна Смирнова", Active: true},
+		{ID: "b3", Email: "anna.s@corp.ru", Name: "Анна Смирнова", Active: true},
+		{ID: "b4", Email: "", Name: "Олег Козлов", Active: false},
+		{ID: "b5", Email: "", Name: "Олег Козлов", Active: true},
+		{ID: "b6", Email: "old@corp.ru", Name: "Мария Иванова", Active: false},
+	}
+
+	tests := []struct {
+		name string
+		user Account
+		want AccountMatch
+	}{
+		{"email wins over name", Account{ID: "u1", Email: "IVAN@corp.ru", Name: "Другое Имя"}, AccountMatch{UserID: "u1", By: MatchByEmail, Candidates: []string{"b1"}}},
+		{"name fallback", Account{ID: "u2", Email: "ivan.p@gmail.com", Name: "Петров Иван"}, AccountMatch{UserID: "u2", By: MatchByName, Candidates: []string{"b1"}}},
+		{"homonyms are ambiguous", Account{ID: "u3", Name: "Анна Смирнова"}, AccountMatch{UserID: "u3", By: MatchByName, Candidates: []string{"b2", "b3"}}},
+		{"active preferred", Account{ID: "u4", Name: "Олег Козлов"}, AccountMatch{UserID: "u4", By: MatchByName, Candidates: []string{"b5"}}},
+		{"inactive only", Account{ID: "u5", Email: "old@corp.ru"}, AccountMatch{UserID: "u5", By: MatchByEmail, Candidates: []string{"b6"}}},
+		{"no match", Account{ID: "u6", Name: "Никто"}, AccountMatch{UserID: "u6", Candidates: []string{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MatchAccounts([]Account{tt.user}, bitrix)
+			if len(got) != 1 || !reflect.DeepEqual(got[0], tt.want) {
+				t.Errorf("MatchAccounts() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,9 +6,11 @@ type BxResponse[T any] struct {
 	Total  int `json:"total"`
 	Next   int `json:"next"`
 	Time   struct {
-		Start    float64 `json:"start"`
-		Finish   float64 `json:"finish"`
-		Duration float64 `json:"duration"`
+		Start            float64 `json:"start"`
+		Finish           float64 `json:"finish"`
+		Duration         float64 `json:"duration"`
+		Operating        float64 `json:"operating"`
+		OperatingResetAt int64   `json:"operating_reset_at"`
 	} `json:"time"`
 }
 
@@ -69,3 +71,32 @@ type BxTask struct {
 	Tags          interface{} `json:"tags"`
 	UfCrmTask     interface{} `json:"ufCrmTask"`
 }
+
+// BxElapsedItem запись учета времени по задаче (task.elapseditem.getlist)
+type BxElapsedItem struct {
+	ID          string `json:"ID"`
+	TaskID      string `json:"TASK_ID"`
+	UserID      string `json:"USER_ID"`
+	CommentText string `json:"COMMENT_TEXT"`
+	Seconds     string `json:"SECONDS"`
+	CreatedDate string `json:"CREATED_DATE"`
+	DateStart   string `json:"DATE_START"`
+}
+
+// BxComment комментарий к задаче (task.commentitem.getlist)
+type BxComment struct {
+	ID          string `json:"ID"`
+	AuthorID    string `json:"AUTHOR_ID"`
+	AuthorName  string `json:"AUTHOR_NAME"`
+	PostMessage string `json:"POST_MESSAGE"`
+	PostDate    string `json:"POST_DATE"`
+}
+
+// BxChecklistItem пункт чек-листа задачи (task.checklistitem.getlist)
+type BxChecklistItem struct {
+	ID         string `json:"ID"`
+	ParentID   string `json:"PARENT_ID"`
+	Title      string `json:"TITLE"`
+	SortIndex  string `json:"SORT_INDEX"`
+	IsComplete string `json:"IS_COMPLETE"` // Y/N
+}
@@ -0,0 +1,130 @@
+package bitrix
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// maxOrphanShare — если "пропало" больше этой доли локальных задач, сверка прерывается:
+// скорее всего, у вебхука отобрали права, а не удалили задачи
+const maxOrphanShare = 0.2
+
+// ReconcileDeleted сравнивает ID задач в Bitrix24 с локальными bitrix_tasks / bitrix_tasks_active
+// и удаляет задачи, которых больше нет в Bitrix, записывая каждую в bitrix_deletion_logs.
+// Возвращает число удаленных задач.
+func (s *SyncManager) ReconcileDeleted() (int, error) {
+	// Локальный снимок берется ДО выгрузки из Bitrix: задача, созданная во время сверки,
+	// попадет в Bitrix-набор, но не в локальный, и не будет ошибочно удалена
+	local := make(map[int64][]string) // bitrix_id -> коллекции, где он есть
+	for _, name := range []string{"bitrix_tasks", "bitrix_tasks_active"} {
+		var ids []string
+		if err := s.app.DB().Select("bitrix_id").From(name).Column(&ids); err != nil {
+			return 0, fmt.Errorf("load %s ids: %w", name, err)
+		}
+		for _, id := range ids {
+			if bxId, ok := parseBitrixID(id); ok {
+				local[bxId] = append(local[bxId], name)
+			}
+		}
+	}
+	if len(local) == 0 {
+		return 0, nil
+	}
+
+	remote := make(map[int64]bool, len(local))
+	err := s.fetchPages("tasks.task.list", map[string]interface{}{
+		"filter": map[string]interface{}{">ID": 0},
+		"order":  map[string]string{"ID": "ASC"},
+		"select": []string{"id"},
+	}, func(result json.RawMessage) error {
+		var page struct {
+			Tasks []struct {
+				ID string `json:"id"`
+			} `json:"tasks"`
+		}
+		if err := json.Unmarshal(result, &page); err != nil {
+			return err
+		}
+		for _, t := range page.Tasks {
+			if bxId, ok := parseBitrixID(t.ID); ok {
+				remote[bxId] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("fetch bitrix task ids: %w", err)
+	}
+	if len(remote) == 0 {
+		return 0, fmt.Errorf("bitrix returned no tasks, reconciliation skipped")
+	}
+
+	var orphans []int64
+	for bxId := range local {
+		if !remote[bxId] {
+			orphans = append(orphans, bxId)
+		}
+	}
+	if float64(len(orphans)) > float64(len(local))*maxOrphanShare {
+		return 0, fmt.Errorf("%d of %d local tasks are missing in bitrix, reconciliation skipped", len(orphans), len(local))
+	}
+
+	removed := 0
+	for _, bxId := range orphans {
+		if err := s.removeDeletedTask(bxId, local[bxId]); err != nil {
+			log.Printf("[Bitrix] Failed to remove deleted task %d: %v", bxId, err)
+			continue
+		}
+		removed++
+		s.countDeleted()
+	}
+	if removed > 0 {
+		log.Printf("[Bitrix] Reconciliation removed %d tasks deleted in Bitrix24", removed)
+	}
+	return removed, nil
+}
+
+// removeDeletedTask удаляет задачу из локальных коллекций и пишет запись в журнал — в одной транзакции
+func (s *SyncManager) removeDeletedTask(bxId int64, collections []string) error {
+	return s.app.RunInTransaction(func(txApp core.App) error {
+		logColl, err := txApp.FindCollectionByNameOrId("bitrix_deletion_logs")
+		if err != nil {
+			return err
+		}
+		entry := core.NewRecord(logColl)
+		entry.Set("bitrix_id", bxId)
+		entry.Set("removed_from", collections)
+
+		for _, name := range collections {
+			records, err := txApp.FindAllRecords(name, dbx.HashExp{"bitrix_id": bxId})
+			if err != nil {
+				return err
+			}
+			for _, rec := range records {
+				if name == "bitrix_tasks" || entry.GetString("title") == "" {
+					entry.Set("title", rec.GetString("title"))
+					entry.Set("snapshot", rec.PublicExport())
+				}
+				if err := txApp.Delete(rec); err != nil {
+					return err
+				}
+			}
+		}
+		return txApp.Save(entry)
+	})
+}
+
+// parseBitrixID приводит ID из Bitrix ("123") и из БД ("123" или "123.0") к числу
+func parseBitrixID(s string) (int64, bool) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || f <= 0 {
+		return 0, false
+	}
+	return int64(f), true
+}
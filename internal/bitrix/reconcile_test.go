@@ -0,0 +1,85 @@
+package bitrix
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+func TestReconcileDeletedRemovesTasks(t *testing.T) {
+	app := newTestApp(t)
+	srv := newFakePortal(t, time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC))
+	s := NewSyncManager(app).WithClient(testClient(srv.URL))
+	if err := s.SyncAll(); err != nil {
+		t.Fatalf("SyncAll failed: %v", err)
+	}
+
+	// 7 — активная задача, 8 — завершенная (есть только в bitrix_tasks)
+	srv.DeleteTask(7)
+	srv.DeleteTask(8)
+
+	removed, err := s.ReconcileDeleted()
+	if err != nil {
+		t.Fatalf("ReconcileDeleted failed: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("removed = %d, want 2", removed)
+	}
+	for _, name := range []string{"bitrix_tasks", "bitrix_tasks_active"} {
+		if n := countRecords(t, app, name, dbx.In("bitrix_id", 7, 8)); n != 0 {
+			t.Errorf("%s still has %d deleted tasks", name, n)
+		}
+	}
+	if n := countRecords(t, app, "bitrix_tasks", nil); n != 118 {
+		t.Errorf("tasks = %d, want 118", n)
+	}
+
+	entry, err := app.FindFirstRecordByFilter("bitrix_deletion_logs", "bitrix_id = 7")
+	if err != nil {
+		t.Fatalf("deletion of task 7 not logged: %v", err)
+	}
+	if entry.GetString("title") != "Задача 7" {
+		t.Errorf("logged title = %q", entry.GetString("title"))
+	}
+	if removedFrom := entry.GetStringSlice("removed_from"); len(removedFrom) != 2 {
+		t.Errorf("removed_from = %v, want both collections", removedFrom)
+	}
+	if snapshot := entry.GetString("snapshot"); !strings.Contains(snapshot, "Задача 7") {
+		t.Errorf("snapshot does not contain the deleted task: %s", snapshot)
+	}
+
+	closed, err := app.FindFirstRecordByFilter("bitrix_deletion_logs", "bitrix_id = 8")
+	if err != nil {
+		t.Fatalf("deletion of task 8 not logged: %v", err)
+	}
+	if removedFrom := closed.GetStringSlice("removed_from"); len(removedFrom) != 1 || removedFrom[0] != "bitrix_tasks" {
+		t.Errorf("removed_from = %v, want [bitrix_tasks]", removedFrom)
+	}
+}
+
+// Если пропало больше maxOrphanShare задач, это похоже на потерю прав вебхука — ничего не удаляется
+func TestReconcileDeletedAbortsAboveThreshold(t *testing.T) {
+	app := newTestApp(t)
+	srv := newFakePortal(t, time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC))
+	s := NewSyncManager(app).WithClient(testClient(srv.URL))
+	if err := s.SyncAll(); err != nil {
+		t.Fatalf("SyncAll failed: %v", err)
+	}
+
+	for id := 1; id <= 30; id++ {
+		srv.DeleteTask(id)
+	}
+
+	removed, err := s.ReconcileDeleted()
+	if err == nil || !strings.Contains(err.Error(), "reconciliation skipped") {
+		t.Fatalf("expected threshold abort, got removed=%d err=%v", removed, err)
+	}
+	if n := countRecords(t, app, "bitrix_tasks", nil); n != 120 {
+		t.Errorf("tasks = %d, want all 120 kept", n)
+	}
+	if n := countRecords(t, app, "bitrix_deletion_logs", nil); n != 0 {
+		t.Errorf("deletion logs = %d, want 0", n)
+	}
+}
@@ -1,20 +1,23 @@
 package bitrix
 
 import (
+	"errors"
 	"log"
+	"net/http"
 	"time"
 
 	"github.com/pocketbase/pocketbase/core"
 )
 
+// reconcileInterval — период сверки удаленных задач (ReconcileDeleted)
+const reconcileInterval = 6 * time.Hour
+
 // Register инициализирует модуль Bitrix: коллекции, роуты, хуки
 func Register(app core.App) error {
-	// 1. Убеждаемся, что коллекции созданы (внутри хука OnServe, когда БД готова)
+	// 1. Коллекции создаются миграциями (internal/migrations) до OnServe;
+	// здесь только закрываем запуски синхронизации, прерванные перезапуском сервера
 	app.OnServe().BindFunc(func(e *core.ServeEvent) error {
-		if err := EnsureCollections(app); err != nil {
-			log.Printf("[Bitrix] Failed to ensure collections: %v", err)
-			return err
-		}
+		failInterruptedRuns(app)
 		return e.Next()
 	})
 
@@ -22,19 +25,56 @@ func Register(app core.App) error {
 	app.OnServe().BindFunc(func(e *core.ServeEvent) error {
 		e.Router.POST("/api/bitrix/sync", func(e *core.RequestEvent) error {
 			sync := NewSyncManager(app)
-			go sync.SyncAll()
+			if err := sync.begin(RunFull); err != nil {
+				return e.JSON(http.StatusConflict, map[string]string{"message": err.Error()})
+			}
+			go func() {
+				sync.end(sync.SyncAll())
+			}()
 			return e.String(200, "Background sync started")
 		})
 
 		e.Router.POST("/api/bitrix/sync-incremental", func(e *core.RequestEvent) error {
-			sync := NewSyncManager(app)
+			sync := NewSyncManager(app).WithContext(e.Request.Context())
 			log.Println("[Bitrix] Manual incremental sync requested from UI...")
-			if err := sync.SyncUpdates(); err != nil {
+			if err := sync.Run(RunIncremental); err != nil {
+				if errors.Is(err, ErrSyncInProgress) {
+					return e.JSON(http.StatusConflict, map[string]string{"message": err.Error()})
+				}
 				return e.InternalServerError("Sync failed", err)
 			}
 			return e.String(200, "Sync finished")
 		})
 
+		e.Router.GET("/api/bitrix/sync/status", func(e *core.RequestEvent) error {
+			return HandleSyncStatus(app, e)
+		})
+
+		e.Router.GET("/api/bitrix/tasks/{id}", func(e *core.RequestEvent) error {
+			return HandleTaskDetails(app, e)
+		})
+
+		e.Router.GET("/api/bitrix/accounts", func(e *core.RequestEvent) error {
+			return HandleAccountLinks(app, e)
+		})
+
+		e.Router.POST("/api/bitrix/accounts/link", func(e *core.RequestEvent) error {
+			return HandleLinkAccount(app, e)
+		})
+
+		e.Router.POST("/api/bitrix/webhook", func(e *core.RequestEvent) error {
+			return HandleRotateWebhook(app, e)
+		})
+
+		e.Router.POST("/api/bitrix/application-token", func(e *core.RequestEvent) error {
+			return HandleSetApplicationToken(app, e)
+		})
+
+		// Исходящий вебхук Bitrix24 (OnTaskAdd / OnTaskUpdate / OnTaskDelete). Опрос по таймеру остается резервом.
+		e.Router.POST("/api/bitrix/events", func(e *core.RequestEvent) error {
+			return HandleEvent(app, e)
+		})
+
 		return e.Next()
 	})
 
@@ -43,21 +83,22 @@ func Register(app core.App) error {
 		go func() {
 			time.Sleep(10 * time.Second) // Даем серверу время прогреться
 
-			sync := NewSyncManager(app)
 			count := 0
 			// Используем сырой SQL запрос для скорости проверки наличия записей
 			app.DB().Select("count(*)").From("bitrix_tasks").Row(&count)
 
 			if count == 0 {
 				log.Println("[Bitrix] bitrix_tasks table is empty. Starting initial sync...")
-				sync.SyncAll()
+				if err := NewSyncManager(app).Run(RunFull); err != nil {
+					log.Printf("[Bitrix] Initial sync error: %v", err)
+				}
 			}
 
 			// Запускаем периодическую синхронизацию (каждые 5 минут)
 			ticker := time.NewTicker(5 * time.Minute)
 			for range ticker.C {
 				log.Println("[Bitrix] Running scheduled incremental sync...")
-				if err := NewSyncManager(app).SyncUpdates(); err != nil {
+				if err := NewSyncManager(app).Run(RunIncremental); err != nil {
 					log.Printf("[Bitrix] Sync error: %v", err)
 				}
 			}
@@ -65,5 +106,20 @@ func Register(app core.App) error {
 		return e.Next()
 	})
 
+	// 4. Сверка удаленных в Bitrix24 задач — реже инкрементальной синхронизации,
+	// т.к. требует выгрузки всех ID задач
+	app.OnServe().BindFunc(func(e *core.ServeEvent) error {
+		go func() {
+			ticker := time.NewTicker(reconcileInterval)
+			for range ticker.C {
+				log.Println("[Bitrix] Running scheduled deleted-task reconciliation...")
+				if err := NewSyncManager(app).Run(RunReconcile); err != nil {
+					log.Printf("[Bitrix] Reconciliation error: %v", err)
+				}
+			}
+		}()
+		return e.Next()
+	})
+
 	return nil
 }
@@ -0,0 +1,210 @@
+package bitrix
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Виды запусков синхронизации (bitrix_sync_runs.kind)
+const (
+	RunFull        = "full"
+	RunIncremental = "incremental"
+	RunReconcile   = "reconcile"
+)
+
+// Статусы запуска (bitrix_sync_runs.status)
+const (
+	RunStatusRunning = "running"
+	RunStatusSuccess = "success"
+	RunStatusFailed  = "failed"
+)
+
+// runFlushInterval — как часто промежуточные счетчики пишутся в bitrix_sync_runs
+const runFlushInterval = 2 * time.Second
+
+// ErrSyncInProgress — другая полная/инкрементальная синхронизация или сверка еще не завершилась
+var ErrSyncInProgress = errors.New("bitrix sync already running")
+
+// syncMu не дает полным, инкрементальным синхронизациям и сверкам пересекаться
+var syncMu sync.Mutex
+
+// syncRun — текущий запуск и его счетчики
+type syncRun struct {
+	record    *core.Record
+	created   int
+	updated   int
+	deleted   int
+	lastFlush time.Time
+}
+
+// Run выполняет синхронизацию указанного вида с записью в bitrix_sync_runs.
+// Возвращает ErrSyncInProgress, если другой запуск еще идет.
+func (s *SyncManager) Run(kind string) error {
+	if err := s.begin(kind); err != nil {
+		return err
+	}
+	err := s.runKind(kind)
+	s.end(err)
+	return err
+}
+
+func (s *SyncManager) runKind(kind string) error {
+	switch kind {
+	case RunFull:
+		return s.SyncAll()
+	case RunIncremental:
+		if err := s.SyncUpdates(); err != nil {
+			return err
+		}
+		return s.SyncTimeEntries()
+	case RunReconcile:
+		_, err := s.ReconcileDeleted()
+		return err
+	}
+	return errors.New("unknown sync kind: " + kind)
+}
+
+// begin захватывает мьютекс синхронизации и создает запись запуска
+func (s *SyncManager) begin(kind string) error {
+	if !syncMu.TryLock() {
+		return ErrSyncInProgress
+	}
+	s.run = &syncRun{lastFlush: time.Now()}
+
+	collection, err := s.app.FindCollectionByNameOrId("bitrix_sync_runs")
+	if err != nil {
+		log.Printf("[Bitrix] Sync run history unavailable: %v", err)
+		return nil
+	}
+	rec := core.NewRecord(collection)
+	rec.Set("kind", kind)
+	rec.Set("status", RunStatusRunning)
+	rec.Set("started", types.NowDateTime())
+	if err := s.app.Save(rec); err != nil {
+		log.Printf("[Bitrix] Failed to save sync run: %v", err)
+		return nil
+	}
+	s.run.record = rec
+	return nil
+}
+
+// end фиксирует результат запуска и освобождает мьютекс
+func (s *SyncManager) end(runErr error) {
+	defer syncMu.Unlock()
+	run := s.run
+	s.run = nil
+	if run == nil || run.record == nil {
+		return
+	}
+
+	rec := run.record
+	rec.Set("finished", types.NowDateTime())
+	rec.Set("created_count", run.created)
+	rec.Set("updated_count", run.updated)
+	rec.Set("deleted_count", run.deleted)
+	if mark := s.GetMaxModifiedDate(); mark != "" {
+		rec.Set("high_water_mark", mark)
+	}
+	if runErr != nil {
+		rec.Set("status", RunStatusFailed)
+		rec.Set("error", runErr.Error())
+	} else {
+		rec.Set("status", RunStatusSuccess)
+	}
+	if err := s.app.Save(rec); err != nil {
+		log.Printf("[Bitrix] Failed to save sync run: %v", err)
+	}
+}
+
+// countSaved учитывает сохраненную задачу в счетчиках текущего запуска
+func (s *SyncManager) countSaved(created bool) {
+	if s.run == nil {
+		return
+	}
+	if created {
+		s.run.created++
+	} else {
+		s.run.updated++
+	}
+	s.flushRun()
+}
+
+func (s *SyncManager) countDeleted() {
+	if s.run == nil {
+		return
+	}
+	s.run.deleted++
+	s.flushRun()
+}
+
+// flushRun периодически сохраняет промежуточные счетчики, чтобы UI видел прогресс
+func (s *SyncManager) flushRun() {
+	if s.run.record == nil || time.Since(s.run.lastFlush) < runFlushInterval {
+		return
+	}
+	s.run.lastFlush = time.Now()
+	s.run.record.Set("created_count", s.run.created)
+	s.run.record.Set("updated_count", s.run.updated)
+	s.run.record.Set("deleted_count", s.run.deleted)
+	if err := s.app.Save(s.run.record); err != nil {
+		log.Printf("[Bitrix] Failed to save sync progress: %v", err)
+	}
+}
+
+// failInterruptedRuns помечает запуски, оставшиеся в статусе running после перезапуска сервера
+func failInterruptedRuns(app core.App) {
+	records, err := app.FindRecordsByFilter("bitrix_sync_runs", "status = {:status}", "", 0, 0, map[string]interface{}{"status": RunStatusRunning})
+	if err != nil {
+		return
+	}
+	for _, rec := range records {
+		rec.Set("status", RunStatusFailed)
+		rec.Set("error", "interrupted by server restart")
+		rec.Set("finished", types.NowDateTime())
+		app.Save(rec)
+	}
+}
+
+// HandleSyncStatus отдает состояние синхронизации: идет ли запуск сейчас и историю последних запусков
+func HandleSyncStatus(app core.App, e *core.RequestEvent) error {
+	if e.Auth == nil {
+		return e.UnauthorizedError("Login required", nil)
+	}
+	runs, err := app.FindRecordsByFilter("bitrix_sync_runs", "", "-started", 20, 0, nil)
+	if err != nil {
+		return e.InternalServerError("Failed to load sync runs", err)
+	}
+
+	var current, lastFinished, lastSuccess *core.Record
+	for _, r := range runs {
+		switch r.GetString("status") {
+		case RunStatusRunning:
+			if current == nil {
+				current = r
+			}
+		case RunStatusSuccess:
+			if lastSuccess == nil {
+				lastSuccess = r
+			}
+			fallthrough
+		default:
+			if lastFinished == nil {
+				lastFinished = r
+			}
+		}
+	}
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"running":       current != nil,
+		"current":       current,
+		"last_finished": lastFinished,
+		"last_success":  lastSuccess,
+		"history":       runs,
+	})
+}
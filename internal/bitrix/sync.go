@@ -1,53 +1,46 @@
 package bitrix
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
-	"time"
+	"strings"
 
 	"github.com/pocketbase/pocketbase/core"
+	"my_pocketbase_app/internal/secrets"
 )
 
 // SyncManager управляет процессом синхронизации с Bitrix24
 type SyncManager struct {
-	app        core.App
-	webhookURL string
+	app    core.App
+	client API
+	ctx    context.Context
+	run    *syncRun // текущий запуск из bitrix_sync_runs (nil для одиночных событий)
 }
 
 func NewSyncManager(app core.App) *SyncManager {
-	url := ""
-	record, err := app.FindFirstRecordByFilter("settings", "key='bitrix_webhook'", nil)
-	if err == nil && record != nil {
-		url = record.GetString("value")
+	url, err := secrets.Get(app, secrets.BitrixWebhook)
+	if err != nil {
+		log.Printf("[Bitrix] Webhook is not available: %v", err)
 	}
-	return &SyncManager{app: app, webhookURL: url}
+	return &SyncManager{app: app, client: NewClient(url), ctx: context.Background()}
 }
 
-func (s *SyncManager) call(method string, payload map[string]interface{}) ([]byte, error) {
-	if s.webhookURL == "" {
-		return nil, fmt.Errorf("bitrix webhook URL not configured")
-	}
-
-	url := fmt.Sprintf("%s/%s", s.webhookURL, method)
-	b, _ := json.Marshal(payload)
-	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(b))
-	req.Header.Set("Content-Type", "application/json")
+// WithContext привязывает синхронизацию к контексту: при его отмене текущий запрос к Bitrix прерывается
+func (s *SyncManager) WithContext(ctx context.Context) *SyncManager {
+	s.ctx = ctx
+	return s
+}
 
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+// WithClient подменяет транспорт Bitrix24 (по умолчанию — клиент вебхука из секрета bitrix_webhook)
+func (s *SyncManager) WithClient(client API) *SyncManager {
+	s.client = client
+	return s
+}
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("bitrix API status %d", resp.StatusCode)
-	}
-	return io.ReadAll(resp.Body)
+func (s *SyncManager) call(method string, payload map[string]interface{}) ([]byte, error) {
+	return s.client.Call(s.ctx, method, payload)
 }
 
 func (s *SyncManager) SyncAll() error {
@@ -61,9 +54,15 @@ func (s *SyncManager) SyncAll() error {
 	if err := s.SyncUsers(); err != nil {
 		return err
 	}
+	if err := s.SyncDepartmentRelations(); err != nil {
+		return err
+	}
 	if err := s.SyncTasks(); err != nil {
 		return err
 	}
+	if err := s.SyncTimeEntries(); err != nil {
+		return err
+	}
 	log.Println("[Bitrix] Full sync completed.")
 	return nil
 }
@@ -86,26 +85,58 @@ func (s *SyncManager) SyncDepartments() error {
 		rec.Set("bitrix_id", dept.ID)
 		rec.Set("name", dept.Name)
 		rec.Set("parent_bitrix_id", dept.ParentID)
+		rec.Set("head_bitrix_id", dept.HeadID)
 		s.app.Save(rec)
 	}
 	return nil
 }
 
+// SyncDepartmentRelations проставляет связи parent и head по parent_bitrix_id / head_bitrix_id.
+// Выполняется после SyncUsers: руководитель может оказаться в отделе, загруженном позже.
+func (s *SyncManager) SyncDepartmentRelations() error {
+	depts, err := s.app.FindAllRecords("bitrix_departments")
+	if err != nil {
+		return err
+	}
+	deptMap := make(map[int]string, len(depts))
+	for _, d := range depts {
+		deptMap[d.GetInt("bitrix_id")] = d.Id
+	}
+	users, err := s.app.FindAllRecords("bitrix_users")
+	if err != nil {
+		return err
+	}
+	userMap := make(map[int]string, len(users))
+	for _, u := range users {
+		userMap[u.GetInt("bitrix_id")] = u.Id
+	}
+
+	for _, d := range depts {
+		parent := ""
+		if d.GetInt("parent_bitrix_id") != d.GetInt("bitrix_id") {
+			parent = deptMap[d.GetInt("parent_bitrix_id")]
+		}
+		head := userMap[d.GetInt("head_bitrix_id")]
+		if d.GetString("parent") == parent && d.GetString("head") == head {
+			continue
+		}
+		d.Set("parent", parent)
+		d.Set("head", head)
+		if err := s.app.Save(d); err != nil {
+			log.Printf("[Bitrix] Error saving department %d relations: %v", d.GetInt("bitrix_id"), err)
+		}
+	}
+	return nil
+}
+
 func (s *SyncManager) SyncGroups() error {
-	start := 0
 	collection, _ := s.app.FindCollectionByNameOrId("bitrix_groups")
-	for {
-		resp, err := s.call("sonet_group.get", map[string]interface{}{"start": start})
-		if err != nil {
+	return s.fetchPages("sonet_group.get", nil, func(result json.RawMessage) error {
+		var groups []BxGroup
+		if err := json.Unmarshal(result, &groups); err != nil {
 			return err
 		}
-		var data BxResponse[[]BxGroup]
-		json.Unmarshal(resp, &data)
-		if len(data.Result) == 0 {
-			break
-		}
-
-		for _, g := range data.Result {
+		for _, g := range groups {
 			rec, _ := s.app.FindFirstRecordByFilter("bitrix_groups", "bitrix_id={:id}", map[string]interface{}{"id": g.ID})
 			if rec == nil {
 				rec = core.NewRecord(collection)
@@ -116,40 +147,35 @@ func (s *SyncManager) SyncGroups() error {
 			rec.Set("active", g.Active == "Y")
 			s.app.Save(rec)
 		}
-		if data.Next == 0 {
-			break
-		}
-		start = data.Next
-	}
-	return nil
+		return nil
+	})
 }
 
 func (s *SyncManager) SyncUsers() error {
-	start := 0
 	collection, _ := s.app.FindCollectionByNameOrId("bitrix_users")
 	deptColl, _ := s.app.FindCollectionByNameOrId("bitrix_departments")
-	for {
-		// Use empty filter to get ALL users, including inactive ones
-		resp, err := s.call("user.get", map[string]interface{}{
-			"start": start,
-		})
-		if err != nil {
+	// Use empty filter to get ALL users, including inactive ones
+	err := s.fetchPages("user.get", nil, func(result json.RawMessage) error {
+		var users []BxUser
+		if err := json.Unmarshal(result, &users); err != nil {
 			return err
 		}
-		var data BxResponse[[]BxUser]
-		json.Unmarshal(resp, &data)
-		if len(data.Result) == 0 {
-			break
-		}
 
-		for _, u := range data.Result {
+		for _, u := range users {
 			rec, _ := s.app.FindFirstRecordByFilter("bitrix_users", "bitrix_id={:id}", map[string]interface{}{"id": u.ID})
 			if rec == nil {
 				rec = core.NewRecord(collection)
 			}
-			fullName := fmt.Sprintf("%s %s", u.Name, u.LastName)
+			fullName := strings.TrimSpace(fmt.Sprintf("%s %s", u.Name, u.LastName))
 			rec.Set("bitrix_id", u.ID)
 			rec.Set("full_name", fullName)
+			rec.Set("first_name", u.Name)
+			rec.Set("last_name", u.LastName)
+			rec.Set("second_name", u.SecondName)
+			rec.Set("email", u.Email)
+			rec.Set("active", u.Active)
+			rec.Set("work_position", u.WorkPosition)
+			rec.Set("photo", u.PersonalPhoto)
 
 			// Resolve departments
 			var deptIds []string
@@ -160,96 +186,64 @@ func (s *SyncManager) SyncUsers() error {
 				}
 			}
 			rec.Set("departments", deptIds)
-			if err := s.app.Save(rec); err == nil {
-				// Try to auto-link with system user (users collection)
-				// Search for a user with the same name
-				sysUser, _ := s.app.FindFirstRecordByFilter("users", "name = {:name}", map[string]interface{}{"name": fullName})
-				if sysUser != nil {
-					// Check if already linked
-					if sysUser.Get("bitrix_user") == "" {
-						sysUser.Set("bitrix_user", rec.Id)
-						if err := s.app.Save(sysUser); err == nil {
-							log.Printf("[Bitrix] Auto-linked system user '%s' to bitrix_id %s", fullName, u.ID)
-						}
-					}
-				}
+			if err := s.app.Save(rec); err != nil {
+				log.Printf("[Bitrix] Error saving user %s: %v", u.ID, err)
 			}
 		}
-		if data.Next == 0 {
-			break
-		}
-		start = data.Next
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Привязка users.bitrix_user — после загрузки всех профилей, чтобы видеть все совпадения сразу
+	report, err := LinkAccounts(s.app, true)
+	if err != nil {
+		return err
+	}
+	if len(report.Conflicts) > 0 {
+		log.Printf("[Bitrix] %d accounts need manual linking (GET /api/bitrix/accounts)", len(report.Conflicts))
 	}
 	return nil
 }
 
+// taskSelectFields — поля tasks.task.list, которые сохраняются в bitrix_tasks
+var taskSelectFields = []string{"id", "parentId", "title", "description", "status", "responsibleId", "createdBy", "groupId", "deadline", "changedDate", "statusChangedDate", "priority", "createdDate", "commentsCount", "timeEstimate", "timeSpentInLogs", "startDatePlan", "endDatePlan", "closedDate", "accomplices", "auditors", "tags", "ufCrmTask"}
+
 func (s *SyncManager) SyncTasks() error {
-	start := 0
 	collection, _ := s.app.FindCollectionByNameOrId("bitrix_tasks")
 	activeCollection, _ := s.app.FindCollectionByNameOrId("bitrix_tasks_active") // New cache collection
 
-	userMap := make(map[string]string)
-	// Fetch ALL users (limit 2000) to ensure map is complete
-	users, _ := s.app.FindRecordsByFilter("bitrix_users", "id != ''", "", 2000, 0, nil)
-	for _, u := range users {
-		// Handle bitrix_id as generic value to support both number and string types in DB
-		bxID := u.Get("bitrix_id")
-		userMap[fmt.Sprint(bxID)] = u.Id
-	}
-
-	groupMap := make(map[string]string)
-	// Fetch ALL groups
-	groups, _ := s.app.FindRecordsByFilter("bitrix_groups", "id != ''", "", 2000, 0, nil)
-	for _, g := range groups {
-		bxID := g.Get("bitrix_id")
-		groupMap[fmt.Sprint(bxID)] = g.Id
-	}
+	userMap, groupMap := s.loadRelationMaps()
 
 	// 3. Load Cache for speed
 	cache := s.LoadTaskCache()
 
-	for {
-		        resp, err := s.call("tasks.task.list", map[string]interface{}{
-		            "start":  start,
-		            "filter": map[string]interface{}{">ID": 0},
-		            "order":  map[string]string{"ID": "DESC"}, 
-		            "select": []string{"id", "parentId", "title", "description", "status", "responsibleId", "createdBy", "groupId", "deadline", "changedDate", "statusChangedDate", "priority", "createdDate", "commentsCount", "timeEstimate", "timeSpentInLogs", "startDatePlan", "endDatePlan", "closedDate", "accomplices", "auditors", "tags", "ufCrmTask"},
-		        		})
-		        		if err != nil {
-			return err
-		}
-		var data BxResponse[struct {
+	processed := 0
+	err := s.fetchPages("tasks.task.list", map[string]interface{}{
+		"filter": map[string]interface{}{">ID": 0},
+		"order":  map[string]string{"ID": "DESC"},
+		"select": taskSelectFields,
+	}, func(result json.RawMessage) error {
+		var page struct {
 			Tasks []BxTask `json:"tasks"`
-		}]
-		json.Unmarshal(resp, &data)
-		if start == 0 {
-			log.Printf("[Bitrix] Total tasks reported by API: %d", data.Total)
 		}
-
-		if len(data.Result.Tasks) == 0 {
-			break
+		if err := json.Unmarshal(result, &page); err != nil {
+			return err
 		}
-
-		for _, t := range data.Result.Tasks {
+		for _, t := range page.Tasks {
 			s.SaveTaskOptimized(t, userMap, groupMap, collection, activeCollection, cache)
 		}
-		
-		processed := start + len(data.Result.Tasks)
-		if processed % 1000 == 0 || data.Next == 0 {
-			firstID := "unknown"
-			lastID := "unknown"
-			if len(data.Result.Tasks) > 0 {
-				firstID = data.Result.Tasks[0].ID
-				lastID = data.Result.Tasks[len(data.Result.Tasks)-1].ID
-			}
-			log.Printf("[Bitrix] Sync progress: %d / %d tasks (Batch IDs: %s to %s)", processed, data.Total, firstID, lastID)
-		}
 
-		if data.Next == 0 {
-			break
+		processed += len(page.Tasks)
+		if len(page.Tasks) > 0 && processed%1000 < len(page.Tasks) {
+			log.Printf("[Bitrix] Sync progress: %d tasks (Batch IDs: %s to %s)", processed, page.Tasks[0].ID, page.Tasks[len(page.Tasks)-1].ID)
 		}
-		start = data.Next
-		time.Sleep(100 * time.Millisecond) // Reduced sleep
+		return nil
+	})
+	if err != nil {
+		return err
 	}
+	log.Printf("[Bitrix] Task sync finished: %d tasks", processed)
 	return nil
 }
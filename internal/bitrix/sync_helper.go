@@ -1,6 +1,7 @@
 package bitrix
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/pocketbase/pocketbase/core"
@@ -21,6 +22,47 @@ func (s *SyncManager) GetMaxModifiedDate() string {
 	return ""
 }
 
+// loadRelationMaps возвращает маппинги BitrixID -> PocketBase Record ID для пользователей и групп
+func (s *SyncManager) loadRelationMaps() (userMap map[string]string, groupMap map[string]string) {
+	userMap = make(map[string]string)
+	// Fetch ALL users (limit 2000) to ensure map is complete
+	users, _ := s.app.FindRecordsByFilter("bitrix_users", "id != ''", "", 2000, 0, nil)
+	for _, u := range users {
+		// Handle bitrix_id as generic value to support both number and string types in DB
+		bxID := u.Get("bitrix_id")
+		userMap[fmt.Sprint(bxID)] = u.Id
+	}
+
+	groupMap = make(map[string]string)
+	groups, _ := s.app.FindRecordsByFilter("bitrix_groups", "id != ''", "", 2000, 0, nil)
+	for _, g := range groups {
+		bxID := g.Get("bitrix_id")
+		groupMap[fmt.Sprint(bxID)] = g.Id
+	}
+	return userMap, groupMap
+}
+
+// relationMapsFor — то же, что loadRelationMaps, но только для исполнителя, постановщика и группы одной задачи
+func (s *SyncManager) relationMapsFor(task BxTask) (userMap map[string]string, groupMap map[string]string) {
+	userMap = make(map[string]string)
+	for _, bxID := range []string{task.ResponsibleId, task.CreatedBy} {
+		if bxID == "" || bxID == "0" {
+			continue
+		}
+		if u, _ := s.app.FindFirstRecordByFilter("bitrix_users", "bitrix_id = {:id}", map[string]interface{}{"id": bxID}); u != nil {
+			userMap[bxID] = u.Id
+		}
+	}
+
+	groupMap = make(map[string]string)
+	if task.GroupId != "" && task.GroupId != "0" {
+		if g, _ := s.app.FindFirstRecordByFilter("bitrix_groups", "bitrix_id = {:id}", map[string]interface{}{"id": task.GroupId}); g != nil {
+			groupMap[task.GroupId] = g.Id
+		}
+	}
+	return userMap, groupMap
+}
+
 // TaskCache хранит маппинг BitrixID -> PocketBase Record ID только для активных задач
 type TaskCache struct {
 	Active  map[string]string
@@ -49,18 +91,30 @@ func (s *SyncManager) LoadTaskCache() *TaskCache {
 	return cache
 }
 
+// taskCacheFor — кэш активных задач из одной записи (для сохранения одиночной задачи по событию)
+func (s *SyncManager) taskCacheFor(bxID string) *TaskCache {
+	cache := &TaskCache{Active: make(map[string]string)}
+	if rec, _ := s.app.FindFirstRecordByFilter("bitrix_tasks_active", "bitrix_id = {:id}", map[string]interface{}{"id": bxID}); rec != nil {
+		cache.Active[bxID] = rec.Id
+	}
+	return cache
+}
+
 // SaveTaskOptimized использует кэш для активных и точечный поиск для архива
 func (s *SyncManager) SaveTaskOptimized(task BxTask, userMap map[string]string, groupMap map[string]string, archiveColl *core.Collection, activeColl *core.Collection, cache *TaskCache) {
 	// 1. Save to Archive (точечный поиск по индексу вместо кэша 28к записей)
 	rec, _ := s.app.FindFirstRecordByFilter(archiveColl.Id, "bitrix_id = {:bid}", map[string]interface{}{"bid": task.ID})
 	
-	if rec == nil {
+	created := rec == nil
+	if created {
 		rec = core.NewRecord(archiveColl)
 	}
 	
 	s.mapTaskToRecord(task, rec, userMap, groupMap)
 	if err := s.app.Save(rec); err != nil {
 		log.Printf("[Bitrix] Error saving task %s to archive: %v", task.ID, err)
+	} else {
+		s.countSaved(created)
 	}
 
 	// 2. Handle Active Tasks Cache
@@ -2,7 +2,6 @@ package bitrix
 
 import (
 	"encoding/json"
-	"fmt"
 	"log"
 	"time"
 )
@@ -34,62 +33,46 @@ func (s *SyncManager) SyncUpdates() error {
 	activeCollection, _ := s.app.FindCollectionByNameOrId("bitrix_tasks_active")
 	
 	// Кэшируем пользователей и группы для связей
-	userMap := make(map[string]string)
-	users, _ := s.app.FindRecordsByFilter("bitrix_users", "id != ''", "", 2000, 0, nil)
-	for _, u := range users {
-		bxID := u.Get("bitrix_id")
-		userMap[fmt.Sprint(bxID)] = u.Id
-	}
-
-	groupMap := make(map[string]string)
-	groups, _ := s.app.FindRecordsByFilter("bitrix_groups", "id != ''", "", 2000, 0, nil)
-	for _, g := range groups {
-		bxID := g.Get("bitrix_id")
-		groupMap[fmt.Sprint(bxID)] = g.Id
-	}
+	userMap, groupMap := s.loadRelationMaps()
 
 	cache := s.LoadTaskCache()
-	start := 0
 	totalUpdated := 0
 	updatedIDs := []string{}
 
-	for {
-		resp, err := s.call("tasks.task.list", map[string]interface{}{
-			"start":  start,
-			"filter": map[string]interface{}{">CHANGED_DATE": filterDate},
-			"select": []string{"id", "parentId", "title", "description", "status", "responsibleId", "createdBy", "groupId", "deadline", "changedDate", "statusChangedDate", "priority", "createdDate", "commentsCount", "timeEstimate", "timeSpentInLogs", "startDatePlan", "endDatePlan", "closedDate", "accomplices", "auditors", "tags", "ufCrmTask"},
-		})
-		if err != nil {
-			return err
-		}
-
-		var data BxResponse[struct {
+	err = s.fetchPages("tasks.task.list", map[string]interface{}{
+		"filter": map[string]interface{}{">CHANGED_DATE": filterDate},
+		"select": taskSelectFields,
+	}, func(result json.RawMessage) error {
+		var page struct {
 			Tasks []BxTask `json:"tasks"`
-		}]
-		if err := json.Unmarshal(resp, &data); err != nil {
+		}
+		if err := json.Unmarshal(result, &page); err != nil {
 			log.Printf("[Bitrix] JSON parse error: %v", err)
 			return err
 		}
-
-		if len(data.Result.Tasks) == 0 {
-			break
-		}
-
-		for _, task := range data.Result.Tasks {
+		for _, task := range page.Tasks {
 			s.SaveTaskOptimized(task, userMap, groupMap, collection, activeCollection, cache)
 			totalUpdated++
 			updatedIDs = append(updatedIDs, task.ID)
 		}
-
-		if data.Next == 0 {
-			break
-		}
-		start = data.Next
-		time.Sleep(100 * time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	if totalUpdated > 0 {
 		log.Printf("[Bitrix] Incremental sync finished. Updated: %d tasks (%v).", totalUpdated, updatedIDs)
+		// Комментарии и чек-листы подтягиваем только для затронутых задач
+		var taskIDs []int64
+		for _, id := range updatedIDs {
+			if bxId, ok := parseBitrixID(id); ok {
+				taskIDs = append(taskIDs, bxId)
+			}
+		}
+		if err := s.SyncTaskDetails(taskIDs); err != nil {
+			log.Printf("[Bitrix] Failed to sync comments and checklists: %v", err)
+		}
 	} else {
 		log.Println("[Bitrix] No new updates found.")
 	}
@@ -0,0 +1,213 @@
+package bitrix
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+	"my_pocketbase_app/internal/bitrix/bitrixtest"
+	_ "my_pocketbase_app/internal/migrations"
+	"my_pocketbase_app/internal/secrets"
+)
+
+// newTestApp поднимает PocketBase на временном каталоге; схема создается миграциями приложения
+func newTestApp(t *testing.T) core.App {
+	t.Helper()
+	app, err := tests.NewTestAppWithConfig(core.BaseAppConfig{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create test app: %v", err)
+	}
+	t.Cleanup(app.Cleanup)
+	return app
+}
+
+// newFakePortal — портал с двумя отделами, группой, 60 сотрудниками (две страницы user.get) и 120 задачами
+func newFakePortal(t *testing.T, base time.Time) *bitrixtest.Server {
+	t.Helper()
+	srv := bitrixtest.NewServer()
+	t.Cleanup(srv.Close)
+
+	srv.AddDepartment(1, "Компания", 0, 1)
+	srv.AddDepartment(2, "Разработка", 1, 2)
+	srv.AddGroup(10, "Проект")
+	srv.AddUser(1, "Анна", "Смирнова", "anna@corp.ru", 1)
+	srv.AddUser(2, "Иван", "Петров", "ivan@corp.ru", 2)
+	for id := 3; id <= 60; id++ {
+		srv.AddUser(id, "Сотрудник", fmt.Sprint(id), fmt.Sprintf("user%d@corp.ru", id), 2)
+	}
+	for id := 1; id <= 120; id++ {
+		status := "2"
+		if id%4 == 0 {
+			status = "5"
+		}
+		srv.AddTask(bitrixtest.Task{ID: id, Title: fmt.Sprintf("Задача %d", id), Status: status, ResponsibleID: 2, GroupID: 10, Changed: base.Add(time.Duration(id) * time.Minute)})
+	}
+	return srv
+}
+
+func countRecords(t *testing.T, app core.App, collection string, exp dbx.Expression) int {
+	t.Helper()
+	n, err := app.CountRecords(collection, exp)
+	if err != nil {
+		t.Fatalf("count %s: %v", collection, err)
+	}
+	return int(n)
+}
+
+func TestSyncAllAgainstFakePortal(t *testing.T) {
+	app := newTestApp(t)
+	base := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	srv := newFakePortal(t, base)
+
+	users, _ := app.FindCollectionByNameOrId("users")
+	sysUser := core.NewRecord(users)
+	sysUser.SetEmail("ivan@corp.ru")
+	sysUser.SetPassword("1234567890")
+	sysUser.Set("name", "Другое Имя")
+	if err := app.Save(sysUser); err != nil {
+		t.Fatalf("create system user: %v", err)
+	}
+
+	s := NewSyncManager(app).WithClient(testClient(srv.URL))
+	if err := s.SyncAll(); err != nil {
+		t.Fatalf("SyncAll failed: %v", err)
+	}
+
+	if n := countRecords(t, app, "bitrix_departments", nil); n != 2 {
+		t.Errorf("departments = %d, want 2", n)
+	}
+	if n := countRecords(t, app, "bitrix_users", nil); n != 60 {
+		t.Errorf("users = %d, want 60", n)
+	}
+	if n := countRecords(t, app, "bitrix_tasks", nil); n != 120 {
+		t.Errorf("tasks = %d, want 120", n)
+	}
+	if n := countRecords(t, app, "bitrix_tasks_active", nil); n != 90 {
+		t.Errorf("active tasks = %d, want 90 (completed ones excluded)", n)
+	}
+	if srv.Calls("batch") == 0 {
+		t.Error("expected pages after the first one to go through batch")
+	}
+
+	ivan, err := app.FindFirstRecordByFilter("bitrix_users", "bitrix_id = 2")
+	if err != nil {
+		t.Fatalf("bitrix user 2 not synced: %v", err)
+	}
+	task, err := app.FindFirstRecordByFilter("bitrix_tasks", "bitrix_id = 7")
+	if err != nil {
+		t.Fatalf("task 7 not synced: %v", err)
+	}
+	if task.GetString("responsible") != ivan.Id {
+		t.Errorf("task responsible = %q, want %q", task.GetString("responsible"), ivan.Id)
+	}
+
+	dev, _ := app.FindFirstRecordByFilter("bitrix_departments", "bitrix_id = 2")
+	root, _ := app.FindFirstRecordByFilter("bitrix_departments", "bitrix_id = 1")
+	if dev == nil || root == nil || dev.GetString("parent") != root.Id || dev.GetString("head") != ivan.Id {
+		t.Errorf("department relations not set: %+v", dev)
+	}
+
+	sysUser, _ = app.FindRecordById("users", sysUser.Id)
+	if sysUser.GetString("bitrix_user") != ivan.Id {
+		t.Errorf("system user linked to %q, want %q (by email)", sysUser.GetString("bitrix_user"), ivan.Id)
+	}
+}
+
+func TestSyncUpdatesAgainstFakePortal(t *testing.T) {
+	app := newTestApp(t)
+	base := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	srv := newFakePortal(t, base)
+
+	if err := NewSyncManager(app).WithClient(testClient(srv.URL)).SyncAll(); err != nil {
+		t.Fatalf("SyncAll failed: %v", err)
+	}
+
+	later := base.Add(10 * time.Hour)
+	srv.AddTask(bitrixtest.Task{ID: 5, Title: "Задача 5 (закрыта)", Status: "5", ResponsibleID: 2, Changed: later})
+	srv.AddTask(bitrixtest.Task{ID: 121, Title: "Новая задача", Status: "2", ResponsibleID: 1, Changed: later})
+
+	s := NewSyncManager(app).WithClient(testClient(srv.URL))
+	if err := s.Run(RunIncremental); err != nil {
+		t.Fatalf("incremental run failed: %v", err)
+	}
+
+	reqs := srv.Requests("tasks.task.list")
+	last := reqs[len(reqs)-1].Params["filter"].(map[string]interface{})
+	if _, ok := last[">CHANGED_DATE"]; !ok {
+		t.Fatalf("incremental sync must filter by >CHANGED_DATE, got %v", last)
+	}
+
+	task, _ := app.FindFirstRecordByFilter("bitrix_tasks", "bitrix_id = 5")
+	if task == nil || task.GetInt("status") != 5 || task.GetString("title") != "Задача 5 (закрыта)" {
+		t.Errorf("task 5 not updated: %+v", task)
+	}
+	if n := countRecords(t, app, "bitrix_tasks_active", dbx.HashExp{"bitrix_id": 5}); n != 0 {
+		t.Error("completed task 5 must leave bitrix_tasks_active")
+	}
+	if n := countRecords(t, app, "bitrix_tasks", dbx.HashExp{"bitrix_id": 121}); n != 1 {
+		t.Error("new task 121 not synced")
+	}
+
+	run, err := app.FindFirstRecordByFilter("bitrix_sync_runs", "kind = 'incremental'")
+	if err != nil {
+		t.Fatalf("sync run not recorded: %v", err)
+	}
+	// Окно безопасности в 5 минут захватывает и последние задачи полной синхронизации
+	if run.GetString("status") != RunStatusSuccess || run.GetInt("created_count") != 1 || run.GetInt("updated_count") < 1 {
+		t.Errorf("unexpected run record: status=%s created=%d updated=%d", run.GetString("status"), run.GetInt("created_count"), run.GetInt("updated_count"))
+	}
+}
+
+func TestSyncRecoversFromTransientErrors(t *testing.T) {
+	app := newTestApp(t)
+	srv := newFakePortal(t, time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC))
+	srv.Fail("user.get", bitrixtest.Failure{Status: http.StatusServiceUnavailable, Code: "QUERY_LIMIT_EXCEEDED", Description: "Too many requests"})
+	srv.Fail("tasks.task.list",
+		bitrixtest.Failure{Status: http.StatusInternalServerError},
+		bitrixtest.Failure{Code: "QUERY_LIMIT_EXCEEDED"}, // попадет в команду batch и будет повторена отдельно
+	)
+
+	if err := NewSyncManager(app).WithClient(testClient(srv.URL)).SyncAll(); err != nil {
+		t.Fatalf("SyncAll must survive transient errors: %v", err)
+	}
+	if n := countRecords(t, app, "bitrix_tasks", nil); n != 120 {
+		t.Errorf("tasks = %d, want 120", n)
+	}
+}
+
+func TestSyncFailsOnPermanentError(t *testing.T) {
+	app := newTestApp(t)
+	srv := newFakePortal(t, time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC))
+	srv.Fail("tasks.task.list", bitrixtest.Failure{Status: http.StatusUnauthorized, Code: "insufficient_scope", Description: "The request requires higher privileges"})
+
+	s := NewSyncManager(app).WithClient(testClient(srv.URL))
+	err := s.Run(RunFull)
+	if err == nil {
+		t.Fatal("expected SyncAll to fail")
+	}
+	run, _ := app.FindFirstRecordByFilter("bitrix_sync_runs", "kind = 'full'")
+	if run == nil || run.GetString("status") != RunStatusFailed || run.GetString("error") == "" {
+		t.Errorf("failed run not recorded: %+v", run)
+	}
+}
+
+// Вебхук берется из зашифрованного секрета, а не из settings
+func TestSyncUsesWebhookSecret(t *testing.T) {
+	app := newTestApp(t)
+	srv := newFakePortal(t, time.Now())
+	t.Setenv(secrets.EnvKey, "0123456789abcdef0123456789abcdef")
+	if err := secrets.Set(app, secrets.BitrixWebhook, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewSyncManager(app).SyncDepartments(); err != nil {
+		t.Fatalf("sync with stored webhook: %v", err)
+	}
+	if n, _ := app.CountRecords("bitrix_departments"); n != 2 {
+		t.Errorf("departments = %d, want 2", n)
+	}
+}
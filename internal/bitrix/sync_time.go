@@ -0,0 +1,155 @@
+package bitrix
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// timeEntriesRecheckWindow — за сколько последних дней записи учета времени перечитываются целиком:
+// сотрудники правят и удаляют списанное время задним числом, а новые записи догружаются только по ID
+const timeEntriesRecheckWindow = 14 * 24 * time.Hour
+
+// SyncTimeEntries догружает новые записи учета времени (task.elapseditem.getlist) и перечитывает
+// записи, созданные за последние timeEntriesRecheckWindow: обновляет измененные и удаляет те, которых в Bitrix24 больше нет.
+func (s *SyncManager) SyncTimeEntries() error {
+	collection, err := s.app.FindCollectionByNameOrId("bitrix_time_entries")
+	if err != nil {
+		return err
+	}
+
+	var lastId int64
+	if err := s.app.DB().Select("COALESCE(MAX(bitrix_id), 0)").From("bitrix_time_entries").Row(&lastId); err != nil {
+		return fmt.Errorf("load last time entry id: %w", err)
+	}
+	userMap, _ := s.loadRelationMaps()
+
+	// Новые записи. Метод не поддерживает start, поэтому страницы идут по возрастанию ID от максимального локального.
+	// При ошибке сохранения останавливаемся: следующий запуск начнет с последней сохраненной записи и не пропустит эту.
+	imported := 0
+	err = s.eachTimeEntry(map[string]interface{}{}, lastId, func(item BxElapsedItem) error {
+		if err := s.saveTimeEntry(collection, item, userMap); err != nil {
+			return fmt.Errorf("save time entry %s: %w", item.ID, err)
+		}
+		imported++
+		return nil
+	})
+	if imported > 0 {
+		log.Printf("[Bitrix] Imported %d time entries", imported)
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.recheckTimeEntries(collection, userMap, time.Now().Add(-timeEntriesRecheckWindow))
+}
+
+// recheckTimeEntries перечитывает записи, созданные начиная с since, и удаляет локальные записи того же периода,
+// которых Bitrix24 больше не отдает
+func (s *SyncManager) recheckTimeEntries(collection *core.Collection, userMap map[string]string, since time.Time) error {
+	seen := make(map[int64]bool)
+	err := s.eachTimeEntry(map[string]interface{}{">=CREATED_DATE": since.Format(time.RFC3339)}, 0, func(item BxElapsedItem) error {
+		if err := s.saveTimeEntry(collection, item, userMap); err != nil {
+			return fmt.Errorf("save time entry %s: %w", item.ID, err)
+		}
+		id, _ := parseBitrixID(item.ID)
+		seen[id] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	local, err := s.app.FindAllRecords(collection.Id, dbx.NewExp("bitrix_created >= {:since}", dbx.Params{"since": since.UTC().Format(types.DefaultDateLayout)}))
+	if err != nil {
+		return err
+	}
+	removed := 0
+	for _, rec := range local {
+		if seen[int64(rec.GetInt("bitrix_id"))] {
+			continue
+		}
+		if err := s.app.Delete(rec); err != nil {
+			return fmt.Errorf("delete time entry %d: %w", rec.GetInt("bitrix_id"), err)
+		}
+		removed++
+	}
+	if removed > 0 {
+		log.Printf("[Bitrix] Removed %d time entries deleted in Bitrix24", removed)
+	}
+	return nil
+}
+
+// eachTimeEntry обходит записи task.elapseditem.getlist по фильтру filter с ID больше afterId по возрастанию ID
+func (s *SyncManager) eachTimeEntry(filter map[string]interface{}, afterId int64, fn func(BxElapsedItem) error) error {
+	for {
+		filter[">ID"] = afterId
+		resp, err := s.call("task.elapseditem.getlist", map[string]interface{}{
+			"ORDER":  map[string]string{"ID": "asc"},
+			"FILTER": filter,
+			"SELECT": []string{"ID", "TASK_ID", "USER_ID", "COMMENT_TEXT", "SECONDS", "CREATED_DATE", "DATE_START"},
+			"PARAMS": map[string]interface{}{"NAV_PARAMS": map[string]interface{}{"nPageSize": pageSize, "iNumPage": 1}},
+		})
+		if err != nil {
+			return err
+		}
+		var data BxResponse[[]BxElapsedItem]
+		if err := json.Unmarshal(resp, &data); err != nil {
+			return fmt.Errorf("bitrix task.elapseditem.getlist: invalid response: %w", err)
+		}
+
+		for _, item := range data.Result {
+			id, ok := parseBitrixID(item.ID)
+			if !ok {
+				continue
+			}
+			if err := fn(item); err != nil {
+				return err
+			}
+			if id > afterId {
+				afterId = id
+			}
+		}
+		if len(data.Result) < pageSize {
+			return nil
+		}
+	}
+}
+
+func (s *SyncManager) saveTimeEntry(collection *core.Collection, item BxElapsedItem, userMap map[string]string) error {
+	rec, _ := s.app.FindFirstRecordByFilter(collection.Id, "bitrix_id = {:id}", map[string]interface{}{"id": item.ID})
+	if rec == nil {
+		rec = core.NewRecord(collection)
+	}
+	rec.Set("bitrix_id", item.ID)
+	rec.Set("task_bitrix_id", item.TaskID)
+	rec.Set("user_bitrix_id", item.UserID)
+	if pbId, ok := userMap[item.UserID]; ok {
+		rec.Set("user", pbId)
+	}
+	seconds, _ := strconv.Atoi(item.Seconds)
+	rec.Set("seconds", seconds)
+	rec.Set("comment", item.CommentText)
+	if dt, err := types.ParseDateTime(item.CreatedDate); err == nil {
+		rec.Set("bitrix_created", dt)
+	}
+
+	date := item.DateStart
+	if date == "" {
+		date = item.CreatedDate
+	}
+	if dt, err := types.ParseDateTime(date); err == nil {
+		rec.Set("date", dt)
+	}
+	// Дата приходит с часовым поясом портала (2025-12-01T18:30:00+03:00): день берем до перевода в UTC
+	if len(date) >= 10 {
+		rec.Set("day", date[:10])
+	}
+	return s.app.Save(rec)
+}
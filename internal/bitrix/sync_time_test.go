@@ -0,0 +1,181 @@
+package bitrix
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"my_pocketbase_app/internal/bitrix/bitrixtest"
+)
+
+// timeEntryFilters — значения FILTER[>ID] в запросах новых записей task.elapseditem.getlist по порядку
+// (запросы перепроверки с FILTER[>=CREATED_DATE] пропускаются)
+func timeEntryFilters(reqs []bitrixtest.Request) string {
+	var ids []int
+	for _, r := range reqs {
+		filter, _ := r.Params["FILTER"].(map[string]interface{})
+		if _, recheck := filter[">=CREATED_DATE"]; recheck {
+			continue
+		}
+		id, _ := filter[">ID"].(float64)
+		ids = append(ids, int(id))
+	}
+	return fmt.Sprint(ids)
+}
+
+func TestSyncTimeEntriesPagesByID(t *testing.T) {
+	app := newTestApp(t)
+	srv := newFakePortal(t, time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC))
+
+	// 120 записей — три страницы по 50; ID добавляются не по порядку, сервер отдает их по возрастанию
+	msk := time.FixedZone("MSK", 3*3600)
+	for id := 120; id >= 1; id-- {
+		srv.AddTimeEntry(id, id%10+1, 2, 1800, time.Date(2025, 12, 1, 10, 0, 0, 0, msk))
+	}
+
+	if err := NewSyncManager(app).WithClient(testClient(srv.URL)).SyncAll(); err != nil {
+		t.Fatalf("SyncAll failed: %v", err)
+	}
+	if n := countRecords(t, app, "bitrix_time_entries", nil); n != 120 {
+		t.Fatalf("expected 120 time entries, got %d", n)
+	}
+	if got := timeEntryFilters(srv.Requests("task.elapseditem.getlist")); got != "[0 50 100]" {
+		t.Errorf("expected pages after >ID 0, 50, 100, got %v", got)
+	}
+
+	srv.AddTimeEntry(121, 3, 2, 600, time.Date(2025, 12, 2, 9, 0, 0, 0, msk))
+	before := srv.Calls("task.elapseditem.getlist")
+	if err := NewSyncManager(app).WithClient(testClient(srv.URL)).SyncTimeEntries(); err != nil {
+		t.Fatalf("SyncTimeEntries failed: %v", err)
+	}
+	reqs := srv.Requests("task.elapseditem.getlist")[before:]
+	if got := timeEntryFilters(reqs); got != "[120]" {
+		t.Errorf("second sync must continue after the local max ID, got filters %v", got)
+	}
+	if n := countRecords(t, app, "bitrix_time_entries", nil); n != 121 {
+		t.Errorf("expected 121 time entries after the second sync, got %d", n)
+	}
+}
+
+func TestSyncTimeEntriesBucketsDayInPortalZone(t *testing.T) {
+	app := newTestApp(t)
+	srv := newFakePortal(t, time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC))
+
+	msk := time.FixedZone("MSK", 3*3600)
+	// 01:30 по Москве — еще 30 ноября по UTC, но в отчете это 1 декабря
+	srv.AddTimeEntry(1, 5, 2, 3600, time.Date(2025, 12, 1, 1, 30, 0, 0, msk))
+	srv.AddTimeEntry(2, 5, 2, 1800, time.Date(2025, 12, 1, 23, 30, 0, 0, msk))
+	// Сотрудника 99 нет на портале — запись сохраняется без связи
+	srv.AddTimeEntry(3, 5, 99, 900, time.Date(2025, 12, 2, 12, 0, 0, 0, msk))
+
+	if err := NewSyncManager(app).WithClient(testClient(srv.URL)).SyncAll(); err != nil {
+		t.Fatalf("SyncAll failed: %v", err)
+	}
+
+	bxUser, err := app.FindFirstRecordByFilter("bitrix_users", "bitrix_id = 2")
+	if err != nil {
+		t.Fatalf("bitrix user 2 not synced: %v", err)
+	}
+	cases := []struct {
+		id      int
+		day     string
+		seconds int
+		user    string
+	}{
+		{1, "2025-12-01", 3600, bxUser.Id},
+		{2, "2025-12-01", 1800, bxUser.Id},
+		{3, "2025-12-02", 900, ""},
+	}
+	for _, c := range cases {
+		rec, err := app.FindFirstRecordByFilter("bitrix_time_entries", "bitrix_id = {:id}", dbx.Params{"id": c.id})
+		if err != nil {
+			t.Errorf("entry %d not imported: %v", c.id, err)
+			continue
+		}
+		if got := rec.GetString("day"); got != c.day {
+			t.Errorf("entry %d: day = %q, want %q", c.id, got, c.day)
+		}
+		if got := rec.GetInt("seconds"); got != c.seconds {
+			t.Errorf("entry %d: seconds = %d, want %d", c.id, got, c.seconds)
+		}
+		if got := rec.GetString("user"); got != c.user {
+			t.Errorf("entry %d: user = %q, want %q", c.id, got, c.user)
+		}
+		if rec.GetInt("task_bitrix_id") != 5 {
+			t.Errorf("entry %d: task_bitrix_id = %d, want 5", c.id, rec.GetInt("task_bitrix_id"))
+		}
+	}
+}
+
+func TestSyncTimeEntriesRechecksRecentEntries(t *testing.T) {
+	app := newTestApp(t)
+	srv := newFakePortal(t, time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC))
+
+	recent := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	old := time.Now().Add(-timeEntriesRecheckWindow - 48*time.Hour).Truncate(time.Second)
+	srv.AddTimeEntry(1, 5, 2, 3600, recent)
+	srv.AddTimeEntry(2, 5, 2, 1800, recent)
+	srv.AddTimeEntry(3, 6, 2, 900, old)
+
+	s := NewSyncManager(app).WithClient(testClient(srv.URL))
+	if err := s.SyncTimeEntries(); err != nil {
+		t.Fatalf("first sync failed: %v", err)
+	}
+	if n := countRecords(t, app, "bitrix_time_entries", nil); n != 3 {
+		t.Fatalf("expected 3 time entries, got %d", n)
+	}
+
+	// В Bitrix запись 1 исправлена, 2 и 3 удалены; новых записей нет
+	srv.AddTimeEntry(1, 5, 2, 7200, recent)
+	srv.DeleteTimeEntry(2)
+	srv.DeleteTimeEntry(3)
+	if err := s.SyncTimeEntries(); err != nil {
+		t.Fatalf("second sync failed: %v", err)
+	}
+
+	if got := findByBitrixID(t, app, "bitrix_time_entries", 1).GetInt("seconds"); got != 7200 {
+		t.Errorf("edited entry 1 must be updated, seconds = %d", got)
+	}
+	if n := countRecords(t, app, "bitrix_time_entries", dbx.HashExp{"bitrix_id": 2}); n != 0 {
+		t.Error("entry 2 deleted in Bitrix must be removed")
+	}
+	// Запись старше окна перепроверки больше не перечитывается и остается как есть
+	if n := countRecords(t, app, "bitrix_time_entries", dbx.HashExp{"bitrix_id": 3}); n != 1 {
+		t.Error("entry 3 outside the recheck window must be kept")
+	}
+}
+
+func TestSyncTimeEntriesStopsAtFailedSave(t *testing.T) {
+	app := newTestApp(t)
+	srv := newFakePortal(t, time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC))
+	for id := 1; id <= 3; id++ {
+		srv.AddTimeEntry(id, 5, 2, 600, time.Date(2025, 12, 1, 10, 0, 0, 0, time.UTC))
+	}
+
+	failing := true
+	app.OnRecordCreate("bitrix_time_entries").BindFunc(func(e *core.RecordEvent) error {
+		if failing && e.Record.GetInt("bitrix_id") == 2 {
+			return errors.New("disk full")
+		}
+		return e.Next()
+	})
+
+	s := NewSyncManager(app).WithClient(testClient(srv.URL))
+	if err := s.SyncTimeEntries(); err == nil {
+		t.Fatal("failed save must be reported")
+	}
+	if n := countRecords(t, app, "bitrix_time_entries", nil); n != 1 {
+		t.Fatalf("sync must stop before the failed entry, got %d entries", n)
+	}
+
+	failing = false
+	if err := s.SyncTimeEntries(); err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+	if n := countRecords(t, app, "bitrix_time_entries", nil); n != 3 {
+		t.Errorf("retry must import the entry that failed and the rest, got %d", n)
+	}
+}
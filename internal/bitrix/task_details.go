@@ -0,0 +1,226 @@
+package bitrix
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Методы комментариев и чек-листов не умеют выбирать данные сразу по нескольким задачам,
+// поэтому на каждую задачу уходит две команды batch
+const detailTasksPerBatch = MaxBatchCommands / 2
+
+// SyncTaskDetails загружает комментарии и чек-листы указанных задач и заменяет ими локальные копии
+func (s *SyncManager) SyncTaskDetails(taskIDs []int64) error {
+	if len(taskIDs) == 0 {
+		return nil
+	}
+	commentsColl, err := s.app.FindCollectionByNameOrId("bitrix_task_comments")
+	if err != nil {
+		return err
+	}
+	checklistColl, err := s.app.FindCollectionByNameOrId("bitrix_task_checklist")
+	if err != nil {
+		return err
+	}
+	userMap, _ := s.loadRelationMaps()
+
+	for len(taskIDs) > 0 {
+		n := len(taskIDs)
+		if n > detailTasksPerBatch {
+			n = detailTasksPerBatch
+		}
+		chunk := taskIDs[:n]
+		taskIDs = taskIDs[n:]
+
+		cmds := make([]BatchCommand, 0, 2*n)
+		for _, id := range chunk {
+			cmds = append(cmds,
+				BatchCommand{Method: "task.commentitem.getlist", Params: map[string]interface{}{"TASKID": id, "ORDER": map[string]string{"ID": "asc"}}},
+				BatchCommand{Method: "task.checklistitem.getlist", Params: map[string]interface{}{"TASKID": id, "ORDER": map[string]string{"SORT_INDEX": "asc"}}},
+			)
+		}
+		results, err := s.client.Batch(s.ctx, cmds)
+		if err != nil {
+			return err
+		}
+
+		for i, id := range chunk {
+			commentsRaw, err := s.batchResult(cmds[2*i], results[2*i])
+			if err != nil {
+				log.Printf("[Bitrix] Failed to load comments of task %d: %v", id, err)
+				continue
+			}
+			checklistRaw, err := s.batchResult(cmds[2*i+1], results[2*i+1])
+			if err != nil {
+				log.Printf("[Bitrix] Failed to load checklist of task %d: %v", id, err)
+				continue
+			}
+			var comments []BxComment
+			if err := json.Unmarshal(commentsRaw, &comments); err != nil {
+				log.Printf("[Bitrix] Invalid comments of task %d: %v", id, err)
+				continue
+			}
+			var checklist []BxChecklistItem
+			if err := json.Unmarshal(checklistRaw, &checklist); err != nil {
+				log.Printf("[Bitrix] Invalid checklist of task %d: %v", id, err)
+				continue
+			}
+			if err := s.saveTaskDetails(id, comments, checklist, commentsColl, checklistColl, userMap); err != nil {
+				log.Printf("[Bitrix] Failed to save details of task %d: %v", id, err)
+			}
+		}
+	}
+	return nil
+}
+
+// batchResult возвращает результат команды; временную ошибку команды повторяет обычным запросом
+func (s *SyncManager) batchResult(cmd BatchCommand, r BatchResult) (json.RawMessage, error) {
+	if r.Err == nil {
+		return r.Result, nil
+	}
+	if !r.Err.Temporary() {
+		return nil, r.Err
+	}
+	resp, err := s.call(cmd.Method, cmd.Params)
+	if err != nil {
+		return nil, err
+	}
+	var data BxResponse[json.RawMessage]
+	if err := json.Unmarshal(resp, &data); err != nil {
+		return nil, fmt.Errorf("bitrix %s: invalid response: %w", cmd.Method, err)
+	}
+	return data.Result, nil
+}
+
+// saveTaskDetails синхронизирует комментарии и чек-лист одной задачи в одной транзакции:
+// пункты, которых больше нет в Bitrix, удаляются
+func (s *SyncManager) saveTaskDetails(taskID int64, comments []BxComment, checklist []BxChecklistItem, commentsColl, checklistColl *core.Collection, userMap map[string]string) error {
+	return s.app.RunInTransaction(func(txApp core.App) error {
+		existing, err := recordsByBitrixID(txApp, commentsColl.Name, taskID)
+		if err != nil {
+			return err
+		}
+		for _, c := range comments {
+			rec := existing[c.ID]
+			delete(existing, c.ID)
+			if rec == nil {
+				rec = core.NewRecord(commentsColl)
+			}
+			rec.Set("bitrix_id", c.ID)
+			rec.Set("task_bitrix_id", taskID)
+			if pbId, ok := userMap[c.AuthorID]; ok {
+				rec.Set("author", pbId)
+			}
+			rec.Set("author_name", c.AuthorName)
+			rec.Set("text", c.PostMessage)
+			if dt, err := types.ParseDateTime(c.PostDate); err == nil {
+				rec.Set("posted", dt)
+			}
+			if err := txApp.Save(rec); err != nil {
+				return err
+			}
+		}
+		for _, rec := range existing {
+			if err := txApp.Delete(rec); err != nil {
+				return err
+			}
+		}
+
+		existing, err = recordsByBitrixID(txApp, checklistColl.Name, taskID)
+		if err != nil {
+			return err
+		}
+		for _, item := range checklist {
+			rec := existing[item.ID]
+			delete(existing, item.ID)
+			if rec == nil {
+				rec = core.NewRecord(checklistColl)
+			}
+			rec.Set("bitrix_id", item.ID)
+			rec.Set("task_bitrix_id", taskID)
+			rec.Set("parent_bitrix_id", item.ParentID)
+			rec.Set("title", item.Title)
+			rec.Set("sort_index", item.SortIndex)
+			rec.Set("is_complete", item.IsComplete == "Y")
+			if err := txApp.Save(rec); err != nil {
+				return err
+			}
+		}
+		for _, rec := range existing {
+			if err := txApp.Delete(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// recordsByBitrixID возвращает записи задачи из коллекции, проиндексированные по bitrix_id (строкой, как приходит из Bitrix)
+func recordsByBitrixID(app core.App, collection string, taskID int64) (map[string]*core.Record, error) {
+	records, err := app.FindAllRecords(collection, dbx.HashExp{"task_bitrix_id": taskID})
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*core.Record, len(records))
+	for _, rec := range records {
+		result[strconv.Itoa(rec.GetInt("bitrix_id"))] = rec
+	}
+	return result, nil
+}
+
+// HandleTaskDetails отдает карточку задачи из локальной реплики: описание, комментарии и прогресс чек-листа.
+// GET /api/bitrix/tasks/{id}, где id — ID задачи в Bitrix24
+func HandleTaskDetails(app core.App, e *core.RequestEvent) error {
+	if e.Auth == nil {
+		return e.UnauthorizedError("Login required", nil)
+	}
+	bxId, ok := parseBitrixID(e.Request.PathValue("id"))
+	if !ok {
+		return e.BadRequestError("Invalid task ID", nil)
+	}
+	task, err := app.FindFirstRecordByFilter("bitrix_tasks", "bitrix_id = {:id}", dbx.Params{"id": bxId})
+	if err != nil {
+		return e.NotFoundError("Task not found", err)
+	}
+	if errs := app.ExpandRecord(task, []string{"responsible", "group"}, nil); len(errs) > 0 {
+		log.Printf("[Bitrix] Failed to expand task %d: %v", bxId, errs)
+	}
+
+	comments, err := app.FindRecordsByFilter("bitrix_task_comments", "task_bitrix_id = {:id}", "posted,bitrix_id", 0, 0, dbx.Params{"id": bxId})
+	if err != nil {
+		return e.InternalServerError("Failed to load comments", err)
+	}
+	checklist, err := app.FindRecordsByFilter("bitrix_task_checklist", "task_bitrix_id = {:id}", "sort_index,bitrix_id", 0, 0, dbx.Params{"id": bxId})
+	if err != nil {
+		return e.InternalServerError("Failed to load checklist", err)
+	}
+
+	// Корневые пункты (parent_bitrix_id = 0) — заголовки чек-листов, в прогрессе не учитываются
+	total, completed := 0, 0
+	for _, item := range checklist {
+		if item.GetInt("parent_bitrix_id") == 0 {
+			continue
+		}
+		total++
+		if item.GetBool("is_complete") {
+			completed++
+		}
+	}
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"task":     task,
+		"comments": comments,
+		"checklist": map[string]interface{}{
+			"total":     total,
+			"completed": completed,
+			"items":     checklist,
+		},
+	})
+}
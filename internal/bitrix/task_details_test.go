@@ -0,0 +1,190 @@
+package bitrix
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+	"my_pocketbase_app/internal/bitrix/bitrixtest"
+)
+
+// newDetailsApp — база после полной синхронизации; у задачи 7 два комментария и чек-лист из корня и двух пунктов
+func newDetailsApp(t *testing.T) (core.App, *bitrixtest.Server, *SyncManager) {
+	t.Helper()
+	app := newTestApp(t)
+	srv := newFakePortal(t, time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC))
+	posted := time.Date(2025, 3, 2, 10, 0, 0, 0, time.UTC)
+	srv.AddComment(7, bitrixtest.Comment{ID: 1, AuthorID: 2, Author: "Иван Петров", Text: "Начал работу", Posted: posted})
+	srv.AddComment(7, bitrixtest.Comment{ID: 2, AuthorID: 1, Author: "Анна Смирнова", Text: "Нужны тесты", Posted: posted.Add(time.Hour)})
+	srv.AddChecklistItem(7, bitrixtest.ChecklistItem{ID: 10, Title: "Чек-лист", SortIndex: 0})
+	srv.AddChecklistItem(7, bitrixtest.ChecklistItem{ID: 12, ParentID: 10, Title: "Тесты", SortIndex: 2})
+	srv.AddChecklistItem(7, bitrixtest.ChecklistItem{ID: 11, ParentID: 10, Title: "Код", SortIndex: 1, Complete: true})
+
+	s := NewSyncManager(app).WithClient(testClient(srv.URL))
+	if err := s.SyncAll(); err != nil {
+		t.Fatalf("SyncAll failed: %v", err)
+	}
+	if err := s.SyncTaskDetails([]int64{7}); err != nil {
+		t.Fatalf("SyncTaskDetails failed: %v", err)
+	}
+	return app, srv, s
+}
+
+func findByBitrixID(t *testing.T, app core.App, collection string, bxId int) *core.Record {
+	t.Helper()
+	rec, err := app.FindFirstRecordByFilter(collection, "bitrix_id = {:id}", dbx.Params{"id": bxId})
+	if err != nil {
+		t.Fatalf("%s %d not found: %v", collection, bxId, err)
+	}
+	return rec
+}
+
+func TestSyncTaskDetailsUpsertsAndRemovesStale(t *testing.T) {
+	app, srv, s := newDetailsApp(t)
+
+	if n := countRecords(t, app, "bitrix_task_comments", dbx.HashExp{"task_bitrix_id": 7}); n != 2 {
+		t.Fatalf("expected 2 comments, got %d", n)
+	}
+	if n := countRecords(t, app, "bitrix_task_checklist", dbx.HashExp{"task_bitrix_id": 7}); n != 3 {
+		t.Fatalf("expected 3 checklist items, got %d", n)
+	}
+	ivan := findByBitrixID(t, app, "bitrix_users", 2)
+	first := findByBitrixID(t, app, "bitrix_task_comments", 1)
+	if first.GetString("author") != ivan.Id || first.GetString("text") != "Начал работу" || first.GetString("author_name") != "Иван Петров" {
+		t.Errorf("comment 1 not stored correctly: %+v", first)
+	}
+	item := findByBitrixID(t, app, "bitrix_task_checklist", 11)
+	if !item.GetBool("is_complete") || item.GetInt("parent_bitrix_id") != 10 || item.GetInt("sort_index") != 1 {
+		t.Errorf("checklist item 11 not stored correctly: %+v", item)
+	}
+
+	// В Bitrix комментарий 1 отредактирован, 2 удален, появился 3; пункт 12 удален, 11 снова открыт
+	srv.AddComment(7, bitrixtest.Comment{ID: 1, AuthorID: 2, Author: "Иван Петров", Text: "Начал работу, оценка 4ч", Posted: time.Date(2025, 3, 2, 10, 0, 0, 0, time.UTC)})
+	srv.DeleteComment(7, 2)
+	srv.AddComment(7, bitrixtest.Comment{ID: 3, AuthorID: 1, Author: "Анна Смирнова", Text: "Принято", Posted: time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)})
+	srv.DeleteChecklistItem(7, 12)
+	srv.AddChecklistItem(7, bitrixtest.ChecklistItem{ID: 11, ParentID: 10, Title: "Код", SortIndex: 1})
+
+	if err := s.SyncTaskDetails([]int64{7}); err != nil {
+		t.Fatalf("second SyncTaskDetails failed: %v", err)
+	}
+
+	updated := findByBitrixID(t, app, "bitrix_task_comments", 1)
+	if updated.Id != first.Id || updated.GetString("text") != "Начал работу, оценка 4ч" {
+		t.Errorf("comment 1 must be updated in place, got %+v", updated)
+	}
+	if n := countRecords(t, app, "bitrix_task_comments", dbx.HashExp{"bitrix_id": 2}); n != 0 {
+		t.Error("comment 2 deleted in Bitrix must be removed")
+	}
+	if n := countRecords(t, app, "bitrix_task_comments", dbx.HashExp{"task_bitrix_id": 7}); n != 2 {
+		t.Errorf("expected comments 1 and 3, got %d", n)
+	}
+	if n := countRecords(t, app, "bitrix_task_checklist", dbx.HashExp{"bitrix_id": 12}); n != 0 {
+		t.Error("checklist item 12 deleted in Bitrix must be removed")
+	}
+	if findByBitrixID(t, app, "bitrix_task_checklist", 11).GetBool("is_complete") {
+		t.Error("checklist item 11 must be reopened")
+	}
+}
+
+func TestSyncTaskDetailsSplitsBatches(t *testing.T) {
+	app := newTestApp(t)
+	srv := newFakePortal(t, time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC))
+	var ids []int64
+	for id := 1; id <= detailTasksPerBatch+5; id++ {
+		srv.AddComment(id, bitrixtest.Comment{ID: 1000 + id, AuthorID: 1, Text: "Комментарий", Posted: time.Date(2025, 3, 2, 10, 0, 0, 0, time.UTC)})
+		ids = append(ids, int64(id))
+	}
+
+	if err := NewSyncManager(app).WithClient(testClient(srv.URL)).SyncTaskDetails(ids); err != nil {
+		t.Fatalf("SyncTaskDetails failed: %v", err)
+	}
+	if n := srv.Calls("batch"); n != 2 {
+		t.Errorf("expected 2 batch calls for %d tasks, got %d", len(ids), n)
+	}
+	if n := countRecords(t, app, "bitrix_task_comments", nil); n != len(ids) {
+		t.Errorf("expected a comment for each of %d tasks, got %d", len(ids), n)
+	}
+}
+
+func getTaskDetails(t *testing.T, app core.App, auth *core.Record, id string) (map[string]json.RawMessage, error) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/bitrix/tasks/"+id, nil)
+	req.SetPathValue("id", id)
+	rec := httptest.NewRecorder()
+	e := &core.RequestEvent{App: app, Auth: auth, Event: router.Event{Request: req, Response: rec}}
+	if err := HandleTaskDetails(app, e); err != nil {
+		return nil, err
+	}
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+	}
+	return body, nil
+}
+
+func TestHandleTaskDetails(t *testing.T) {
+	app, _, _ := newDetailsApp(t)
+	users, _ := app.FindCollectionByNameOrId("users")
+	auth := core.NewRecord(users)
+
+	body, err := getTaskDetails(t, app, auth, "7")
+	if err != nil {
+		t.Fatalf("task details failed: %v", err)
+	}
+	var task struct {
+		BitrixID int    `json:"bitrix_id"`
+		Title    string `json:"title"`
+	}
+	var comments []struct {
+		BitrixID int    `json:"bitrix_id"`
+		Text     string `json:"text"`
+	}
+	var checklist struct {
+		Total     int `json:"total"`
+		Completed int `json:"completed"`
+		Items     []struct {
+			BitrixID int `json:"bitrix_id"`
+		} `json:"items"`
+	}
+	json.Unmarshal(body["task"], &task)
+	json.Unmarshal(body["comments"], &comments)
+	json.Unmarshal(body["checklist"], &checklist)
+
+	if task.BitrixID != 7 || task.Title != "Задача 7" {
+		t.Errorf("unexpected task: %+v", task)
+	}
+	if len(comments) != 2 || comments[0].BitrixID != 1 || comments[1].BitrixID != 2 {
+		t.Errorf("comments must be ordered by post date: %+v", comments)
+	}
+	// Корень чек-листа в прогресс не входит
+	if checklist.Total != 2 || checklist.Completed != 1 {
+		t.Errorf("expected progress 1/2, got %d/%d", checklist.Completed, checklist.Total)
+	}
+	if len(checklist.Items) != 3 || checklist.Items[0].BitrixID != 10 || checklist.Items[1].BitrixID != 11 || checklist.Items[2].BitrixID != 12 {
+		t.Errorf("checklist must be ordered by sort index: %+v", checklist.Items)
+	}
+
+	for _, c := range []struct {
+		name   string
+		auth   *core.Record
+		id     string
+		status int
+	}{
+		{"anonymous", nil, "7", http.StatusUnauthorized},
+		{"invalid id", auth, "abc", http.StatusBadRequest},
+		{"unknown task", auth, "999", http.StatusNotFound},
+	} {
+		_, err := getTaskDetails(t, app, c.auth, c.id)
+		var apiErr *router.ApiError
+		if !errors.As(err, &apiErr) || apiErr.Status != c.status {
+			t.Errorf("%s: expected %d, got %v", c.name, c.status, err)
+		}
+	}
+}
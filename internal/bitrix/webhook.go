@@ -0,0 +1,51 @@
+package bitrix
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"my_pocketbase_app/internal/secrets"
+)
+
+// webhookCheckTimeout — сколько ждать ответа Bitrix24 при проверке нового вебхука
+const webhookCheckTimeout = 15 * time.Second
+
+// HandleRotateWebhook заменяет вебхук Bitrix24 (POST /api/bitrix/webhook, только superadmin).
+// Новый вебхук сначала проверяется вызовом profile, чтобы неверный URL не остановил синхронизацию.
+// В ответе сам вебхук не возвращается.
+func HandleRotateWebhook(app core.App, e *core.RequestEvent) error {
+	if e.Auth == nil || !e.Auth.GetBool("superadmin") {
+		return e.ForbiddenError("Only admins can change the Bitrix webhook", nil)
+	}
+	var body struct {
+		Webhook string `json:"webhook"`
+	}
+	if err := e.BindBody(&body); err != nil {
+		return e.BadRequestError("Invalid request body", err)
+	}
+	webhook := strings.TrimRight(strings.TrimSpace(body.Webhook), "/")
+	if u, err := url.Parse(webhook); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return e.BadRequestError("webhook must be an absolute http(s) URL", err)
+	}
+
+	ctx, cancel := context.WithTimeout(e.Request.Context(), webhookCheckTimeout)
+	defer cancel()
+	if _, err := NewClient(webhook).Call(ctx, "profile", map[string]interface{}{}); err != nil {
+		// Сетевые ошибки содержат полный URL запроса, то есть сам вебхук, — наружу отдаем только ответ Bitrix24
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			return e.BadRequestError("Bitrix24 rejected the new webhook: "+apiErr.Error(), nil)
+		}
+		return e.BadRequestError("Bitrix24 is not reachable with the new webhook", nil)
+	}
+
+	if err := secrets.Set(app, secrets.BitrixWebhook, webhook); err != nil {
+		return e.InternalServerError("Failed to store the webhook", err)
+	}
+	return e.JSON(http.StatusOK, map[string]interface{}{"updated": true})
+}
@@ -0,0 +1,128 @@
+package bitrix
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+	"my_pocketbase_app/internal/bitrix/bitrixtest"
+	"my_pocketbase_app/internal/secrets"
+)
+
+const storedWebhook = "https://corp.bitrix24.ru/rest/1/old-token"
+
+func newWebhookApp(t *testing.T) core.App {
+	t.Helper()
+	app := newTestApp(t)
+	t.Setenv(secrets.EnvKey, "0123456789abcdef0123456789abcdef")
+	if err := secrets.Set(app, secrets.BitrixWebhook, storedWebhook); err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+func rotateWebhook(t *testing.T, app core.App, auth *core.Record, body string) (*httptest.ResponseRecorder, error) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/bitrix/webhook", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	e := &core.RequestEvent{App: app, Auth: auth, Event: router.Event{Request: req, Response: rec}}
+	return rec, HandleRotateWebhook(app, e)
+}
+
+func authUser(t *testing.T, app core.App, superadmin bool) *core.Record {
+	t.Helper()
+	users, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := core.NewRecord(users)
+	auth.Set("superadmin", superadmin)
+	return auth
+}
+
+func expectStatus(t *testing.T, what string, err error, status int) {
+	t.Helper()
+	var apiErr *router.ApiError
+	if !errors.As(err, &apiErr) || apiErr.Status != status {
+		t.Errorf("%s: expected %d, got %v", what, status, err)
+	}
+}
+
+func expectStoredWebhook(t *testing.T, app core.App, want string) {
+	t.Helper()
+	if got, err := secrets.Get(app, secrets.BitrixWebhook); err != nil || got != want {
+		t.Errorf("stored webhook = %q, %v; want %q", got, err, want)
+	}
+}
+
+func TestRotateWebhookRequiresSuperadmin(t *testing.T) {
+	app := newWebhookApp(t)
+	srv := bitrixtest.NewServer()
+	t.Cleanup(srv.Close)
+	body := `{"webhook": "` + srv.URL + `"}`
+
+	_, err := rotateWebhook(t, app, nil, body)
+	expectStatus(t, "anonymous", err, http.StatusForbidden)
+	_, err = rotateWebhook(t, app, authUser(t, app, false), body)
+	expectStatus(t, "regular user", err, http.StatusForbidden)
+
+	if n := srv.Calls("profile"); n != 0 {
+		t.Errorf("webhook must not be checked for non-admins, got %d profile calls", n)
+	}
+	expectStoredWebhook(t, app, storedWebhook)
+}
+
+func TestRotateWebhookValidatesURL(t *testing.T) {
+	app := newWebhookApp(t)
+	admin := authUser(t, app, true)
+
+	for name, body := range map[string]string{
+		"invalid json": `{"webhook":`,
+		"empty":        `{"webhook": "  "}`,
+		"relative":     `{"webhook": "rest/1/token"}`,
+		"ftp":          `{"webhook": "ftp://corp.bitrix24.ru/rest/1/token"}`,
+		"no host":      `{"webhook": "https:///rest/1/token"}`,
+	} {
+		_, err := rotateWebhook(t, app, admin, body)
+		expectStatus(t, name, err, http.StatusBadRequest)
+	}
+	expectStoredWebhook(t, app, storedWebhook)
+}
+
+func TestRotateWebhookRejectedByBitrix(t *testing.T) {
+	app := newWebhookApp(t)
+	srv := bitrixtest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.Fail("profile", bitrixtest.Failure{Status: http.StatusUnauthorized, Code: "INVALID_CREDENTIALS", Description: "Invalid request credentials"})
+
+	_, err := rotateWebhook(t, app, authUser(t, app, true), `{"webhook": "`+srv.URL+`/"}`)
+	expectStatus(t, "rejected webhook", err, http.StatusBadRequest)
+	if err != nil && strings.Contains(err.Error(), srv.URL) {
+		t.Errorf("error must not echo the webhook: %v", err)
+	}
+	expectStoredWebhook(t, app, storedWebhook)
+}
+
+func TestRotateWebhookStoresWithoutEcho(t *testing.T) {
+	app := newWebhookApp(t)
+	srv := bitrixtest.NewServer()
+	t.Cleanup(srv.Close)
+
+	rec, err := rotateWebhook(t, app, authUser(t, app, true), `{"webhook": " `+srv.URL+`/ "}`)
+	if err != nil {
+		t.Fatalf("rotate failed: %v", err)
+	}
+	if srv.Calls("profile") != 1 {
+		t.Error("new webhook must be checked with profile")
+	}
+	if body := rec.Body.String(); strings.Contains(body, srv.URL) || !strings.Contains(body, `"updated":true`) {
+		t.Errorf("unexpected response %s", body)
+	}
+	// Пробелы и завершающий слэш отрезаются, иначе методы вызывались бы по адресу с двойным слэшем
+	expectStoredWebhook(t, app, srv.URL)
+}
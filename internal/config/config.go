@@ -4,7 +4,7 @@ type StatusConfig struct {
 	Title string `json:"title"`
 	Slug  string `json:"slug"`
 	Color string `json:"color"`
-	Type  string `json:"type"` // "final", "in_progress", "return"
+	Type  string `json:"type"` // "final", "final_return", "appeal", "in_progress", "return"
 }
 
 type TaskFieldConfig struct {
@@ -20,4 +20,6 @@ type AppConfig struct {
 	BitrixWebhook string            `json:"bitrix_webhook"`
 	Statuses      []StatusConfig    `json:"statuses"`
 	TaskFields    []TaskFieldConfig `json:"task_fields"`
+	// ReconcilePolicy — как сверять statuses/task_fields с файлом: "file", "merge" (по умолчанию) или "db"
+	ReconcilePolicy string `json:"reconcile_policy"`
 }
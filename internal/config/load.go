@@ -0,0 +1,159 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"my_pocketbase_app/internal/app"
+)
+
+// Переменные окружения, которые перекрывают значения из файла.
+// Вебхук Bitrix24 — секрет, его лучше задавать только через окружение, а не коммитить в config.json.
+const (
+	EnvConfigPath      = "KPI_CONFIG"
+	EnvBitrixWebhook   = "KPI_BITRIX_WEBHOOK"
+	EnvReconcilePolicy = "KPI_RECONCILE_POLICY"
+)
+
+// ErrNotFound — путь не задан ни флагом, ни окружением, и config.json не найден в стандартных местах
+var ErrNotFound = errors.New("config file not found")
+
+// defaultPaths — где искать config.json, если путь не задан (запуск из корня, cmd/ или cmd/server/)
+var defaultPaths = []string{"config.json", "../config.json", "../../config.json"}
+
+var (
+	statusTypes    = []string{app.StatusFinal, app.StatusFinalReturn, app.StatusAppeal, app.StatusInProgress, app.StatusReturn}
+	fieldTypes     = []string{"text", "number", "date", "select", "boolean"}
+	requiredFields = []string{"task_number", "time_spent", "status", "date"}
+	policies       = []string{app.ReconcilePolicyFile, app.ReconcilePolicyMerge, app.ReconcilePolicyDB}
+)
+
+// Load читает и проверяет конфиг. Путь: аргумент (флаг --config), затем KPI_CONFIG, затем defaultPaths.
+// Возвращает путь фактически прочитанного файла.
+func Load(path string) (*AppConfig, string, error) {
+	if path == "" {
+		path = os.Getenv(EnvConfigPath)
+	}
+	if path == "" {
+		for _, p := range defaultPaths {
+			if _, err := os.Stat(p); err == nil {
+				path = p
+				break
+			}
+		}
+		if path == "" {
+			return nil, "", ErrNotFound
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, path, err
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, path, fmt.Errorf("invalid config %s:\n%w", path, err)
+	}
+	return cfg, path, nil
+}
+
+// Parse разбирает JSON конфига (неизвестные ключи — ошибка), применяет переменные окружения и проверяет результат
+func Parse(data []byte) (*AppConfig, error) {
+	var cfg AppConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	if v, ok := os.LookupEnv(EnvBitrixWebhook); ok {
+		cfg.BitrixWebhook = v
+	}
+	if v, ok := os.LookupEnv(EnvReconcilePolicy); ok {
+		cfg.ReconcilePolicy = v
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate проверяет справочники и возвращает все найденные ошибки сразу, по одной на строку
+func (c *AppConfig) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) { errs = append(errs, fmt.Errorf(format, args...)) }
+
+	// Статус ищется и по slug, и по названию, поэтому оба должны быть уникальны в одном пространстве имен
+	names := make(map[string]string)
+	slugs := make(map[string]bool)
+	hasFinal := false
+	for i, s := range c.Statuses {
+		slug := strings.TrimSpace(s.Slug)
+		switch {
+		case slug == "":
+			fail("statuses[%d]: slug is empty", i)
+		case slugs[slug]:
+			fail("statuses[%d]: duplicate slug %q", i, slug)
+		}
+		slugs[slug] = true
+		if strings.TrimSpace(s.Title) == "" {
+			fail("statuses[%d] (%s): title is empty", i, s.Slug)
+		}
+		if !slices.Contains(statusTypes, s.Type) {
+			fail("statuses[%d] (%s): unknown type %q, allowed: %s", i, s.Slug, s.Type, strings.Join(statusTypes, ", "))
+		}
+		// Цвет — select-поле statuses.color: неизвестное значение сорвало бы сохранение статуса при сверке
+		if !slices.Contains(app.StatusColors, s.Color) {
+			fail("statuses[%d] (%s): unknown color %q, allowed: %s", i, s.Slug, s.Color, strings.Join(app.StatusColors, ", "))
+		}
+		hasFinal = hasFinal || s.Type == app.StatusFinal
+		for _, name := range []string{s.Slug, s.Title} {
+			norm := strings.ToLower(strings.TrimSpace(name))
+			if norm == "" {
+				continue
+			}
+			if prev, dup := names[norm]; dup && prev != s.Slug {
+				fail("statuses[%d] (%s): %q is already used by status %s", i, s.Slug, name, prev)
+			}
+			names[norm] = s.Slug
+		}
+	}
+	if len(c.Statuses) > 0 && !hasFinal {
+		fail("statuses: no status with type %q, completed tasks would never be counted", app.StatusFinal)
+	}
+
+	keys := make(map[string]bool)
+	for i, f := range c.TaskFields {
+		key := strings.TrimSpace(f.Key)
+		switch {
+		case key == "":
+			fail("task_fields[%d]: key is empty", i)
+		case keys[key]:
+			fail("task_fields[%d]: duplicate key %q", i, key)
+		}
+		keys[key] = true
+		if strings.TrimSpace(f.Title) == "" {
+			fail("task_fields[%d] (%s): title is empty", i, key)
+		}
+		if !slices.Contains(fieldTypes, f.Type) {
+			fail("task_fields[%d] (%s): unknown type %q, allowed: %s", i, key, f.Type, strings.Join(fieldTypes, ", "))
+		}
+	}
+	for _, key := range requiredFields {
+		if !keys[key] {
+			fail("task_fields: required key %q is missing", key)
+		}
+	}
+
+	if p := strings.ToLower(strings.TrimSpace(c.ReconcilePolicy)); p != "" && !slices.Contains(policies, p) {
+		fail("reconcile_policy: unknown policy %q, allowed: %s", c.ReconcilePolicy, strings.Join(policies, ", "))
+	}
+
+	return errors.Join(errs...)
+}
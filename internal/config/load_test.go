@@ -0,0 +1,123 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const validConfig = `{
+	"bitrix_webhook": "https://example.bitrix24.ru/rest/1/file",
+	"statuses": [
+		{"title": "Завершена", "slug": "completed", "color": "success", "type": "final"},
+		{"title": "Выполняется", "slug": "in_progress", "color": "info", "type": "in_progress"}
+	],
+	"task_fields": [
+		{"key": "task_number", "title": "№", "type": "text", "required": true},
+		{"key": "time_spent", "title": "Часы", "type": "number"},
+		{"key": "status", "title": "Статус", "type": "select"},
+		{"key": "date", "title": "Дата", "type": "date"}
+	]
+}`
+
+func TestLoadRepositoryConfig(t *testing.T) {
+	if _, err := os.Stat("../../config.json"); err != nil {
+		t.Skip("config.json not found")
+	}
+	if _, _, err := Load("../../config.json"); err != nil {
+		t.Fatalf("config.json in the repository is invalid: %v", err)
+	}
+}
+
+func TestLoadExplicitPathAndEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "custom.json")
+	if err := os.WriteFile(path, []byte(validConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvBitrixWebhook, "https://example.bitrix24.ru/rest/1/env")
+
+	cfg, used, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used != path {
+		t.Errorf("used path = %q, want %q", used, path)
+	}
+	if cfg.BitrixWebhook != "https://example.bitrix24.ru/rest/1/env" {
+		t.Errorf("webhook = %q, env override not applied", cfg.BitrixWebhook)
+	}
+
+	t.Setenv(EnvConfigPath, path)
+	if _, used, err := Load(""); err != nil || used != path {
+		t.Errorf("Load via %s: used=%q err=%v", EnvConfigPath, used, err)
+	}
+}
+
+func TestLoadNotFound(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if _, _, err := Load(""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+	if _, _, err := Load("missing.json"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("explicit missing path should be a read error, got %v", err)
+	}
+}
+
+func TestParseReportsAllProblems(t *testing.T) {
+	bad := `{
+		"statuses": [
+			{"title": "Завершена", "slug": "completed", "color": "success", "type": "finall"},
+			{"title": "Completed", "slug": "done", "color": "info", "type": "in_progress"},
+			{"title": "Повтор", "slug": "done", "color": "magenta", "type": "in_progress"}
+		],
+		"task_fields": [
+			{"key": "task_number", "title": "№", "type": "text"},
+			{"key": "task_number", "title": "Дубль", "type": "string"}
+		],
+		"reconcile_policy": "mirror"
+	}`
+	_, err := Parse([]byte(bad))
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{
+		`unknown type "finall"`,
+		`duplicate slug "done"`,
+		`"Completed" is already used by status completed`,
+		`no status with type "final"`,
+		`duplicate key "task_number"`,
+		`unknown type "string"`,
+		`required key "time_spent" is missing`,
+		`required key "date" is missing`,
+		`unknown color "magenta"`,
+		`unknown policy "mirror"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s:\n%v", want, err)
+		}
+	}
+}
+
+func TestParseReconcilePolicyFromEnv(t *testing.T) {
+	t.Setenv(EnvReconcilePolicy, " File ")
+	cfg, err := Parse([]byte(validConfig))
+	if err != nil {
+		t.Fatalf("policy is case-insensitive: %v", err)
+	}
+	if cfg.ReconcilePolicy != " File " {
+		t.Errorf("policy = %q, env override not applied", cfg.ReconcilePolicy)
+	}
+
+	t.Setenv(EnvReconcilePolicy, "mirror")
+	if _, err := Parse([]byte(validConfig)); err == nil || !strings.Contains(err.Error(), `unknown policy "mirror"`) {
+		t.Errorf("invalid policy from env should be reported, got %v", err)
+	}
+}
+
+func TestParseRejectsUnknownKeys(t *testing.T) {
+	if _, err := Parse([]byte(`{"statuses": [], "task_feilds": []}`)); err == nil || !strings.Contains(err.Error(), "task_feilds") {
+		t.Errorf("unknown key should be reported, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	pbCore "github.com/pocketbase/pocketbase/core"
+	"my_pocketbase_app/internal/app"
+	"my_pocketbase_app/internal/replay"
+	"my_pocketbase_app/internal/utils"
+)
+
+// entryColumns — ключи из tasks.data, которые вынесены в отдельные колонки task_entries.
+// Все остальные поля строки попадают в extra.
+var entryColumns = map[string]bool{
+	"task_number":         true,
+	"status":              true,
+	"time_spent":          true,
+	"programmer_estimate": true,
+	"project":             true,
+}
+
+// SyncTaskEntries пересобирает строки task_entries для одного файла tasks.
+// Вызывается внутри транзакции сохранения файла, поэтому данные всегда согласованы.
+func SyncTaskEntries(txApp pbCore.App, record *pbCore.Record) error {
+	if err := deleteTaskEntries(txApp, record.Id); err != nil {
+		return err
+	}
+
+	entriesCol, err := txApp.FindCachedCollectionByNameOrId(app.CollectionTaskEntries)
+	if err != nil {
+		return err
+	}
+
+	taskList, err := utils.ParseTaskData(record.GetString(app.FieldData))
+	if err != nil {
+		return fmt.Errorf("task %s: invalid data: %w", record.Id, err)
+	}
+
+	for i, t := range taskList {
+		// Строка без номера задачи тоже сохраняется: ее часы входят в суммы, а в подсчете задач она не участвует
+		taskNum := replay.NormalizeTaskNumber(t["task_number"])
+
+		extra := make(map[string]interface{})
+		for k, v := range t {
+			if !entryColumns[k] {
+				extra[k] = v
+			}
+		}
+
+		entry := pbCore.NewRecord(entriesCol)
+		entry.Set("task", record.Id)
+		entry.Set(app.FieldUser, record.GetString(app.FieldUser))
+		entry.Set(app.FieldFileDate, record.GetDateTime(app.FieldFileDate))
+		entry.Set("line", i)
+		entry.Set("task_number", taskNum)
+		if t["status"] != nil {
+			entry.Set("status", strings.TrimSpace(fmt.Sprintf("%v", t["status"])))
+		}
+		entry.Set("time_spent", utils.GetTimeSpent(t["time_spent"]))
+		entry.Set("programmer_estimate", utils.GetTimeSpent(t["programmer_estimate"]))
+		if t["project"] != nil {
+			entry.Set("project", fmt.Sprintf("%v", t["project"]))
+		}
+		entry.Set("extra", extra)
+		if err := txApp.Save(entry); err != nil {
+			return fmt.Errorf("task %s line %d: %w", record.Id, i, err)
+		}
+	}
+	return nil
+}
+
+func deleteTaskEntries(txApp pbCore.App, taskId string) error {
+	_, err := txApp.DB().Delete(app.CollectionTaskEntries, dbx.HashExp{"task": taskId}).Execute()
+	return err
+}
+
+// BackfillTaskEntries пересобирает task_entries для всех существующих файлов
+func BackfillTaskEntries(pbApp pbCore.App) (int, error) {
+	if _, err := pbApp.FindCollectionByNameOrId(app.CollectionTaskEntries); err != nil {
+		return 0, fmt.Errorf("collection %q not found, start the server once to create it", app.CollectionTaskEntries)
+	}
+
+	var ids []string
+	if err := pbApp.DB().Select("id").From(app.CollectionTasks).OrderBy(app.FieldFileDate+" ASC", "rowid ASC").Column(&ids); err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for _, id := range ids {
+		err := pbApp.RunInTransaction(func(txApp pbCore.App) error {
+			record, err := txApp.FindRecordById(app.CollectionTasks, id)
+			if err != nil {
+				return err
+			}
+			return SyncTaskEntries(txApp, record)
+		})
+		if err != nil {
+			log.Printf("[Backfill] Failed to rebuild entries for task %s: %v", id, err)
+			continue
+		}
+		processed++
+		if processed%500 == 0 {
+			log.Printf("[Backfill] Progress: %d / %d files", processed, len(ids))
+		}
+	}
+	return processed, nil
+}
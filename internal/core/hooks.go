@@ -135,3 +135,26 @@ func RegisterTaskSignaling(app *pocketbase.PocketBase) {
 	app.OnRecordAfterUpdateSuccess("tasks").BindFunc(func(e *pbCore.RecordEvent) error { triggerSignal(app); return e.Next() })
 	app.OnRecordAfterDeleteSuccess("tasks").BindFunc(func(e *pbCore.RecordEvent) error { triggerSignal(app); return e.Next() })
 }
+
+// RegisterTaskEntriesSync поддерживает task_entries в соответствии с tasks.data
+func RegisterTaskEntriesSync(app *pocketbase.PocketBase) {
+	app.OnRecordCreate("tasks").BindFunc(func(e *pbCore.RecordEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		return SyncTaskEntries(e.App, e.Record)
+	})
+	app.OnRecordUpdate("tasks").BindFunc(func(e *pbCore.RecordEvent) error {
+		if err := e.Next(); err != nil {
+			return err
+		}
+		return SyncTaskEntries(e.App, e.Record)
+	})
+	app.OnRecordDelete("tasks").BindFunc(func(e *pbCore.RecordEvent) error {
+		// Удаляем строки до самой записи, иначе сработает защита обязательной связи task
+		if err := deleteTaskEntries(e.App, e.Record.Id); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+}
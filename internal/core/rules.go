@@ -10,6 +10,9 @@ const (
 	RuleTaskView   = "@request.auth.id != '' && (@request.auth.id = user || @request.auth.id = uploaded_by || @request.auth.superadmin = true || @request.auth.is_coordinator = true)"
 	RuleTaskDelete = "@request.auth.id != '' && (@request.auth.id = user || @request.auth.id = uploaded_by || @request.auth.superadmin = true)"
 
+	// Правило для строк отчетов (task_entries)
+	RuleTaskEntryView = "@request.auth.id != '' && (@request.auth.id = user || @request.auth.superadmin = true || @request.auth.is_coordinator = true)"
+
 	// Правило для ОТГУЛОВ
 	RuleLeaveView   = "@request.auth.id != '' && (user = @request.auth.id || @request.auth.superadmin = true || @request.auth.is_coordinator = true)"
 	RuleLeaveDelete = "@request.auth.id != '' && (user = @request.auth.id || @request.auth.superadmin = true || @request.auth.is_coordinator = true)"
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"my_pocketbase_app/internal/app"
+	"my_pocketbase_app/internal/org"
+	"my_pocketbase_app/internal/utils"
+)
+
+// HandleDepartmentRanking сворачивает рейтинг сотрудников по дереву отделов Bitrix24 за месяц (month) или год (year).
+// Родительский отдел включает показатели всех дочерних; department ограничивает ответ поддеревом.
+func HandleDepartmentRanking(pbApp *pocketbase.PocketBase, context *app.AppContext, e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+	start, end, ok := utils.PeriodFromQuery(query.Get("month"), query.Get("year"))
+	if !ok {
+		return e.BadRequestError("Valid month (YYYY-MM) or year (YYYY) parameter is required", nil)
+	}
+
+	tree, err := utils.LoadDepartmentTree(pbApp)
+	if err != nil {
+		return e.InternalServerError("Failed to load departments", err)
+	}
+	var subtree map[int]bool
+	if dept := query.Get("department"); dept != "" {
+		id, err := strconv.Atoi(dept)
+		if err != nil || !tree.Has(id) {
+			return e.BadRequestError("Unknown department", nil)
+		}
+		subtree = tree.Subtree(id)
+	}
+
+	members, err := utils.LoadUserDepartments(pbApp)
+	if err != nil {
+		return e.InternalServerError("Failed to load department members", err)
+	}
+	ranking, err := utils.StreamRanking(pbApp, start, end, context.Statuses().Types)
+	if err != nil {
+		return e.InternalServerError("Failed to calculate ranking", err)
+	}
+	stats := make(map[string]org.MemberStats, len(ranking))
+	for _, item := range ranking {
+		stats[item.UserId] = org.MemberStats{Hours: item.TotalHours, CompletedTasks: item.CompletedTasks}
+	}
+
+	response := []org.Totals{}
+	for _, tot := range tree.Rollup(members, stats) {
+		if subtree == nil || subtree[tot.DepartmentID] {
+			response = append(response, tot)
+		}
+	}
+	return e.JSON(http.StatusOK, response)
+}
+
+// departmentMembers возвращает пользователей отдела department (bitrix_id) и всех его дочерних отделов.
+// ok=false — отдел не найден.
+func departmentMembers(pbApp *pocketbase.PocketBase, department string) (users map[string]bool, ok bool, err error) {
+	tree, err := utils.LoadDepartmentTree(pbApp)
+	if err != nil {
+		return nil, false, err
+	}
+	id, convErr := strconv.Atoi(department)
+	if convErr != nil || !tree.Has(id) {
+		return nil, false, nil
+	}
+	subtree := tree.Subtree(id)
+
+	members, err := utils.LoadUserDepartments(pbApp)
+	if err != nil {
+		return nil, false, err
+	}
+	users = make(map[string]bool)
+	for userId, deptIds := range members {
+		for _, deptId := range deptIds {
+			if subtree[deptId] {
+				users[userId] = true
+				break
+			}
+		}
+	}
+	return users, true, nil
+}
+
+// filterRankingByDepartment оставляет в рейтинге только сотрудников отдела department и его дочерних отделов.
+// Пустой department — без фильтра; ok=false — отдел не найден.
+func filterRankingByDepartment(pbApp *pocketbase.PocketBase, ranking []utils.RankingItem, department string) (filtered []utils.RankingItem, ok bool, err error) {
+	if department == "" {
+		return ranking, true, nil
+	}
+	users, ok, err := departmentMembers(pbApp, department)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	filtered = []utils.RankingItem{}
+	for _, item := range ranking {
+		if users[item.UserId] {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered, true, nil
+}
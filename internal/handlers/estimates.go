@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"my_pocketbase_app/internal/app"
+	"my_pocketbase_app/internal/replay"
+	"my_pocketbase_app/internal/utils"
+)
+
+const worstOverrunsLimit = 5
+
+// Overrun — завершенная задача, по которой факт превысил оценку
+type Overrun struct {
+	TaskNumber  string  `json:"task_number"`
+	Project     string  `json:"project"`
+	Estimate    float64 `json:"estimate"`
+	Spent       float64 `json:"spent"`
+	Overrun     float64 `json:"overrun"`
+	OverrunRate float64 `json:"overrun_rate"`
+}
+
+// EstimateStats — точность оценок по набору завершенных задач.
+// MAPE и доля превышений считаются только по задачам с ненулевой оценкой.
+type EstimateStats struct {
+	CompletedTasks    int       `json:"completed_tasks"`
+	EstimatedTasks    int       `json:"estimated_tasks"`
+	TotalEstimate     float64   `json:"total_estimate"`
+	TotalSpent        float64   `json:"total_spent"`
+	MAPE              float64   `json:"mape"`
+	OverEstimateShare float64   `json:"over_estimate_share"`
+	WorstOverruns     []Overrun `json:"worst_overruns"`
+
+	apeSum    float64
+	overCount int
+}
+
+// ProjectEstimate — точность оценок сотрудника в разрезе проекта
+type ProjectEstimate struct {
+	Project string `json:"project"`
+	EstimateStats
+}
+
+// UserEstimate — точность оценок сотрудника за период
+type UserEstimate struct {
+	UserId   string `json:"user_id"`
+	UserName string `json:"user_name"`
+	EstimateStats
+	Projects []ProjectEstimate `json:"projects"`
+}
+
+func (s *EstimateStats) add(tl *replay.Timeline, project string) {
+	s.CompletedTasks++
+	s.TotalEstimate += tl.TotalEstimate
+	s.TotalSpent += tl.TotalSpent
+	if tl.TotalEstimate <= 0 {
+		return
+	}
+	s.EstimatedTasks++
+	s.apeSum += math.Abs(tl.TotalSpent-tl.TotalEstimate) / tl.TotalEstimate
+	if tl.TotalSpent > tl.TotalEstimate {
+		s.overCount++
+		s.WorstOverruns = append(s.WorstOverruns, Overrun{
+			TaskNumber:  tl.TaskNumber,
+			Project:     project,
+			Estimate:    tl.TotalEstimate,
+			Spent:       tl.TotalSpent,
+			Overrun:     tl.TotalSpent - tl.TotalEstimate,
+			OverrunRate: (tl.TotalSpent - tl.TotalEstimate) / tl.TotalEstimate,
+		})
+	}
+}
+
+func (s *EstimateStats) finish() {
+	if s.EstimatedTasks > 0 {
+		s.MAPE = s.apeSum / float64(s.EstimatedTasks)
+		s.OverEstimateShare = float64(s.overCount) / float64(s.EstimatedTasks)
+	}
+	sort.Slice(s.WorstOverruns, func(i, j int) bool { return s.WorstOverruns[i].Overrun > s.WorstOverruns[j].Overrun })
+	if len(s.WorstOverruns) > worstOverrunsLimit {
+		s.WorstOverruns = s.WorstOverruns[:worstOverrunsLimit]
+	}
+	if s.WorstOverruns == nil {
+		s.WorstOverruns = []Overrun{}
+	}
+}
+
+// computeEstimateAccuracy сравнивает накопленные по задаче оценку и факт (как в HandleCompletedTasksGrouped)
+// для задач, последний статус которых завершенный.
+func computeEstimateAccuracy(histories map[string]*replay.Replay, statusMap map[string]string) map[string]*UserEstimate {
+	result := make(map[string]*UserEstimate)
+	for userId, history := range histories {
+		user := &UserEstimate{UserId: userId}
+		projects := make(map[string]*ProjectEstimate)
+		for _, tl := range history.Timelines() {
+			if !utils.IsStatusCompleted(tl.Status(), statusMap) {
+				continue
+			}
+			project := ""
+			if tl.Latest["project"] != nil {
+				project = fmt.Sprintf("%v", tl.Latest["project"])
+			}
+			if projects[project] == nil {
+				projects[project] = &ProjectEstimate{Project: project}
+			}
+			user.add(tl, project)
+			projects[project].add(tl, project)
+		}
+
+		user.finish()
+		user.Projects = []ProjectEstimate{}
+		for _, p := range projects {
+			p.finish()
+			user.Projects = append(user.Projects, *p)
+		}
+		sort.Slice(user.Projects, func(i, j int) bool { return user.Projects[i].TotalSpent > user.Projects[j].TotalSpent })
+		result[userId] = user
+	}
+	return result
+}
+
+// sortByAccuracy упорядочивает сотрудников от самых точных оценок к наименее точным.
+// Без оцененных задач MAPE равен 0, поэтому такие сотрудники идут в конец, а не в начало.
+func sortByAccuracy(users []UserEstimate) {
+	sort.SliceStable(users, func(i, j int) bool {
+		iEstimated, jEstimated := users[i].EstimatedTasks > 0, users[j].EstimatedTasks > 0
+		if iEstimated != jEstimated {
+			return iEstimated
+		}
+		return users[i].MAPE < users[j].MAPE
+	})
+}
+
+// HandleEstimateAccuracy отдает точность оценок по сотрудникам и проектам за месяц (month) или год (year).
+// Сотрудник видит только свои показатели, superadmin и координатор — любых (фильтр user).
+func HandleEstimateAccuracy(pbApp *pocketbase.PocketBase, context *app.AppContext, e *core.RequestEvent) error {
+	if e.Auth == nil {
+		return e.UnauthorizedError("Login required", nil)
+	}
+	query := e.Request.URL.Query()
+	start, end, ok := utils.PeriodFromQuery(query.Get("month"), query.Get("year"))
+	if !ok {
+		return e.BadRequestError("Valid month (YYYY-MM) or year (YYYY) parameter is required", nil)
+	}
+
+	targetUser := query.Get("user")
+	if !canViewOthers(e.Auth) {
+		if targetUser != "" && targetUser != e.Auth.Id {
+			return e.ForbiddenError("Insufficient permissions", nil)
+		}
+		targetUser = e.Auth.Id
+	}
+
+	histories, err := utils.ReplayTasksByUser(pbApp, start, end, targetUser)
+	if err != nil {
+		return e.InternalServerError("Failed to load tasks", err)
+	}
+	names, err := utils.LoadUserNames(pbApp)
+	if err != nil {
+		return e.InternalServerError("Failed to load users", err)
+	}
+
+	response := []UserEstimate{}
+	for userId, stats := range computeEstimateAccuracy(histories, context.Statuses().Types) {
+		stats.UserName = names[userId]
+		if stats.UserName == "" {
+			stats.UserName = "Unknown"
+		}
+		response = append(response, *stats)
+	}
+	sortByAccuracy(response)
+	return e.JSON(http.StatusOK, response)
+}
+
+// withEstimateAccuracy дополняет рейтинг колонкой estimate_mape (если запрошено ?estimate_accuracy=1).
+// Как и в HandleEstimateAccuracy, сотрудник получает MAPE только в своей строке.
+func withEstimateAccuracy(pbApp *pocketbase.PocketBase, context *app.AppContext, auth *core.Record, ranking []utils.RankingItem, start, end string) error {
+	targetUser := ""
+	if !canViewOthers(auth) {
+		targetUser = auth.Id
+	}
+	histories, err := utils.ReplayTasksByUser(pbApp, start, end, targetUser)
+	if err != nil {
+		return err
+	}
+	accuracy := computeEstimateAccuracy(histories, context.Statuses().Types)
+	for i := range ranking {
+		if stats, ok := accuracy[ranking[i].UserId]; ok && stats.EstimatedTasks > 0 {
+			mape := stats.MAPE
+			ranking[i].EstimateMAPE = &mape
+		}
+	}
+	return nil
+}
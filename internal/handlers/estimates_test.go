@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+	"my_pocketbase_app/internal/app"
+	"my_pocketbase_app/internal/replay"
+)
+
+var estimateStatuses = map[string]string{"завершена": "final", "выполняется": "in_progress"}
+
+func replayOf(tasks ...app.TaskEntry) *replay.Replay {
+	r := replay.New()
+	r.Add(replay.File{ID: "f1", FileDate: "2025-12-01", Tasks: tasks})
+	return r
+}
+
+func task(number, status, project string, spent, estimate float64) app.TaskEntry {
+	return app.TaskEntry{"task_number": number, "status": status, "project": project, "time_spent": spent, "programmer_estimate": estimate}
+}
+
+func TestComputeEstimateAccuracy(t *testing.T) {
+	cases := []struct {
+		name          string
+		tasks         []app.TaskEntry
+		wantCompleted int
+		wantEstimated int
+		wantMAPE      float64
+		wantOverShare float64
+		wantOverruns  []string
+		wantProjects  map[string]int
+	}{
+		{
+			name:          "no estimates",
+			tasks:         []app.TaskEntry{task("1", "Завершена", "A", 4, 0), task("2", "Завершена", "A", 2, 0)},
+			wantCompleted: 2, wantEstimated: 0, wantMAPE: 0, wantOverShare: 0,
+			wantOverruns: []string{}, wantProjects: map[string]int{"A": 2},
+		},
+		{
+			name:          "overruns sorted by hours",
+			tasks:         []app.TaskEntry{task("1", "Завершена", "A", 6, 4), task("2", "Завершена", "A", 10, 5), task("3", "Завершена", "A", 3, 4)},
+			wantCompleted: 3, wantEstimated: 3, wantMAPE: (0.5 + 1 + 0.25) / 3, wantOverShare: 2.0 / 3,
+			wantOverruns: []string{"2", "1"}, wantProjects: map[string]int{"A": 3},
+		},
+		{
+			name:          "grouped by project, unfinished skipped",
+			tasks:         []app.TaskEntry{task("1", "Завершена", "A", 2, 2), task("2", "Завершена", "B", 4, 2), task("3", "Выполняется", "B", 9, 1)},
+			wantCompleted: 2, wantEstimated: 2, wantMAPE: 0.5, wantOverShare: 0.5,
+			wantOverruns: []string{"2"}, wantProjects: map[string]int{"A": 1, "B": 1},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			user := computeEstimateAccuracy(map[string]*replay.Replay{"u1": replayOf(c.tasks...)}, estimateStatuses)["u1"]
+			if user.CompletedTasks != c.wantCompleted || user.EstimatedTasks != c.wantEstimated {
+				t.Errorf("completed/estimated = %d/%d, want %d/%d", user.CompletedTasks, user.EstimatedTasks, c.wantCompleted, c.wantEstimated)
+			}
+			if math.Abs(user.MAPE-c.wantMAPE) > 1e-9 || math.Abs(user.OverEstimateShare-c.wantOverShare) > 1e-9 {
+				t.Errorf("MAPE/over share = %v/%v, want %v/%v", user.MAPE, user.OverEstimateShare, c.wantMAPE, c.wantOverShare)
+			}
+			if len(user.WorstOverruns) != len(c.wantOverruns) {
+				t.Fatalf("overruns = %+v, want tasks %v", user.WorstOverruns, c.wantOverruns)
+			}
+			for i, num := range c.wantOverruns {
+				if user.WorstOverruns[i].TaskNumber != num {
+					t.Errorf("overrun[%d] = %s, want %s", i, user.WorstOverruns[i].TaskNumber, num)
+				}
+			}
+			projects := map[string]int{}
+			for _, p := range user.Projects {
+				projects[p.Project] = p.CompletedTasks
+			}
+			if len(projects) != len(c.wantProjects) {
+				t.Errorf("projects = %v, want %v", projects, c.wantProjects)
+			}
+			for name, n := range c.wantProjects {
+				if projects[name] != n {
+					t.Errorf("project %s: %d completed, want %d", name, projects[name], n)
+				}
+			}
+		})
+	}
+}
+
+func TestSortByAccuracyPutsUnestimatedLast(t *testing.T) {
+	users := []UserEstimate{
+		{UserId: "none", EstimateStats: EstimateStats{CompletedTasks: 3}},
+		{UserId: "rough", EstimateStats: EstimateStats{EstimatedTasks: 2, MAPE: 0.8}},
+		{UserId: "exact", EstimateStats: EstimateStats{EstimatedTasks: 5, MAPE: 0.1}},
+	}
+	sortByAccuracy(users)
+	for i, want := range []string{"exact", "rough", "none"} {
+		if users[i].UserId != want {
+			t.Errorf("position %d: %s, want %s", i, users[i].UserId, want)
+		}
+	}
+}
+
+// callHandler вызывает обработчик KPI с авторизацией auth и разбирает JSON-ответ в out
+func callHandler(t *testing.T, pbApp *pocketbase.PocketBase, auth *core.Record, url string, out interface{}, handler func(*pocketbase.PocketBase, *app.AppContext, *core.RequestEvent) error) error {
+	t.Helper()
+	context := &app.AppContext{}
+	context.StatusRegistry.Replace(&app.StatusSet{Types: estimateStatuses})
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	rec := httptest.NewRecorder()
+	e := &core.RequestEvent{App: pbApp, Auth: auth, Event: router.Event{Request: req, Response: rec}}
+	if err := handler(pbApp, context, e); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+		t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+	}
+	return nil
+}
+
+func TestEstimateAccuracyScopedToOwnData(t *testing.T) {
+	pbApp := newTimesheetApp(t)
+	anna := saveRecord(t, pbApp, "users", map[string]interface{}{"email": "anna@corp.ru", "name": "Анна"})
+	ivan := saveRecord(t, pbApp, "users", map[string]interface{}{"email": "ivan@corp.ru", "name": "Иван"})
+	coordinator := saveRecord(t, pbApp, "users", map[string]interface{}{"email": "boss@corp.ru", "name": "Босс", "is_coordinator": true})
+	addReport(t, pbApp, anna.Id, "2025-12-01", task("101", "Завершена", "CRM", 4, 2))
+	addReport(t, pbApp, ivan.Id, "2025-12-01", task("102", "Завершена", "ERP", 3, 3))
+
+	var users []UserEstimate
+	err := callHandler(t, pbApp, nil, "/api/kpi/estimate-accuracy?month=2025-12", &users, HandleEstimateAccuracy)
+	var apiErr *router.ApiError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnauthorized {
+		t.Errorf("anonymous: expected 401, got %v", err)
+	}
+	err = callHandler(t, pbApp, anna, "/api/kpi/estimate-accuracy?month=2025-12&user="+ivan.Id, &users, HandleEstimateAccuracy)
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusForbidden {
+		t.Errorf("employee asking for another user: expected 403, got %v", err)
+	}
+
+	if err := callHandler(t, pbApp, anna, "/api/kpi/estimate-accuracy?month=2025-12", &users, HandleEstimateAccuracy); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].UserId != anna.Id {
+		t.Errorf("employee must see only own accuracy, got %+v", users)
+	}
+	if err := callHandler(t, pbApp, coordinator, "/api/kpi/estimate-accuracy?month=2025-12", &users, HandleEstimateAccuracy); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 {
+		t.Errorf("coordinator must see everyone, got %+v", users)
+	}
+
+	// Колонка estimate_mape в рейтинге подчиняется тем же правилам
+	var ranking []struct {
+		UserId       string   `json:"user_id"`
+		EstimateMAPE *float64 `json:"estimate_mape"`
+	}
+	err = callHandler(t, pbApp, nil, "/api/kpi/ranking?month=2025-12&estimate_accuracy=1", &ranking, HandleRanking)
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnauthorized {
+		t.Errorf("anonymous ranking with estimate_accuracy: expected 401, got %v", err)
+	}
+	if err := callHandler(t, pbApp, anna, "/api/kpi/ranking?month=2025-12&estimate_accuracy=1", &ranking, HandleRanking); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range ranking {
+		if (row.EstimateMAPE != nil) != (row.UserId == anna.Id) {
+			t.Errorf("employee must get estimate_mape only in own row, got %s: %v", row.UserId, row.EstimateMAPE)
+		}
+	}
+	if len(ranking) != 2 {
+		t.Errorf("ranking itself stays public, got %d rows", len(ranking))
+	}
+}
@@ -14,6 +14,11 @@ import (
 	"my_pocketbase_app/internal/utils"
 )
 
+// canViewOthers — superadmin и координатор видят отчеты и показатели любых сотрудников
+func canViewOthers(auth *core.Record) bool {
+	return auth.GetBool("superadmin") || auth.GetBool("is_coordinator")
+}
+
 func HandleRanking(pbApp *pocketbase.PocketBase, context *app.AppContext, e *core.RequestEvent) error {
 	month := e.Request.URL.Query().Get("month")
 	if month == "" || !utils.IsValidMonth(month) {
@@ -21,10 +26,25 @@ func HandleRanking(pbApp *pocketbase.PocketBase, context *app.AppContext, e *cor
 	}
 	start := month + "-01 00:00:00"
 	end := month + "-31 23:59:59"
-	response, err := utils.StreamRanking(pbApp, start, end, context.StatusMap)
+	response, err := utils.StreamRanking(pbApp, start, end, context.Statuses().Types)
 	if err != nil {
 		return e.InternalServerError("Failed to calculate ranking", err)
 	}
+	response, ok, err := filterRankingByDepartment(pbApp, response, e.Request.URL.Query().Get("department"))
+	if err != nil {
+		return e.InternalServerError("Failed to load departments", err)
+	}
+	if !ok {
+		return e.BadRequestError("Unknown department", nil)
+	}
+	if e.Request.URL.Query().Get("estimate_accuracy") == "1" {
+		if e.Auth == nil {
+			return e.UnauthorizedError("Login required", nil)
+		}
+		if err := withEstimateAccuracy(pbApp, context, e.Auth, response, start, end); err != nil {
+			return e.InternalServerError("Failed to calculate estimate accuracy", err)
+		}
+	}
 	return e.JSON(http.StatusOK, response)
 }
 
@@ -35,10 +55,25 @@ func HandleYearlyRanking(pbApp *pocketbase.PocketBase, context *app.AppContext,
 	}
 	start := year + "-01-01 00:00:00"
 	end := year + "-12-31 23:59:59"
-	response, err := utils.StreamRanking(pbApp, start, end, context.StatusMap)
+	response, err := utils.StreamRanking(pbApp, start, end, context.Statuses().Types)
 	if err != nil {
 		return e.InternalServerError("Failed to calculate yearly stats", err)
 	}
+	response, ok, err := filterRankingByDepartment(pbApp, response, e.Request.URL.Query().Get("department"))
+	if err != nil {
+		return e.InternalServerError("Failed to load departments", err)
+	}
+	if !ok {
+		return e.BadRequestError("Unknown department", nil)
+	}
+	if e.Request.URL.Query().Get("estimate_accuracy") == "1" {
+		if e.Auth == nil {
+			return e.UnauthorizedError("Login required", nil)
+		}
+		if err := withEstimateAccuracy(pbApp, context, e.Auth, response, start, end); err != nil {
+			return e.InternalServerError("Failed to calculate estimate accuracy", err)
+		}
+	}
 	return e.JSON(http.StatusOK, response)
 }
 
@@ -51,25 +86,16 @@ func HandleActualTasks(pbApp *pocketbase.PocketBase, context *app.AppContext, e
 		return e.BadRequestError("Valid dates required", nil)
 	}
 
-	records, _ := utils.FetchTasksByDateRange(pbApp, start, end, targetUser, 0, 0)
-	utils.SortRecordsChronologically(records)
-
-	latestTasks := make(map[string]app.TaskEntry)
-	for _, r := range records {
-		taskList, _ := utils.ParseTaskData(r.GetString(app.FieldData))
-		for _, t := range taskList {
-			taskNum := strings.TrimSpace(fmt.Sprintf("%v", t["task_number"]))
-			if taskNum == "" { continue }
-			t["source_file_date"] = r.GetString("file_date")
-			t["source_file_id"] = r.Id
-			latestTasks[taskNum] = t
-		}
+	history, err := utils.ReplayTasksByDateRange(pbApp, start, end, targetUser)
+	if err != nil {
+		return e.InternalServerError("Failed to load tasks", err)
 	}
 
+	statuses := context.Statuses()
 	result := []app.TaskEntry{}
-	for _, t := range latestTasks {
-		if !utils.IsStatusCompleted(t["status"], context.StatusMap) {
-			result = append(result, t)
+	for _, tl := range history.Timelines() {
+		if !utils.IsStatusCompleted(tl.Status(), statuses.Types) {
+			result = append(result, tl.Latest)
 		}
 	}
 	return e.JSON(http.StatusOK, result)
@@ -84,40 +110,19 @@ func HandleCompletedTasksGrouped(pbApp *pocketbase.PocketBase, context *app.AppC
 		return e.BadRequestError("Valid dates required", nil)
 	}
 
-	records, _ := utils.FetchTasksByDateRange(pbApp, start, end, targetUser, 0, 0)
-	utils.SortRecordsChronologically(records)
-
-	var totalMonthSpent, totalMonthEval float64
-	taskHistorySumSpent := make(map[string]float64)
-	taskHistorySumEval := make(map[string]float64)
-	taskLatestData := make(map[string]app.TaskEntry)
-
-	for _, r := range records {
-		taskList, _ := utils.ParseTaskData(r.GetString(app.FieldData))
-		for _, t := range taskList {
-			taskNum := strings.TrimSpace(fmt.Sprintf("%v", t["task_number"]))
-			if taskNum == "" { continue }
-
-			spent := utils.GetTimeSpent(t["time_spent"])
-			eval := utils.GetTimeSpent(t["programmer_estimate"])
-
-			totalMonthSpent += spent
-			totalMonthEval += eval
-			
-			taskHistorySumSpent[taskNum] += spent
-			taskHistorySumEval[taskNum] += eval
-			
-			t["source_file_date"] = r.GetString("file_date")
-			t["source_file_id"] = r.Id
-			taskLatestData[taskNum] = t
-		}
+	history, err := utils.ReplayTasksByDateRange(pbApp, start, end, targetUser)
+	if err != nil {
+		return e.InternalServerError("Failed to load tasks", err)
 	}
+	totalMonthSpent, totalMonthEval := history.Totals()
+	statuses := context.Statuses()
 
+	// У активных задач в итог месяца не входит только последний (незавершенный) этап
 	var activeLatestSpent, activeLatestEval float64
-	for _, t := range taskLatestData {
-		if !utils.IsStatusCompleted(t["status"], context.StatusMap) {
-			activeLatestSpent += utils.GetTimeSpent(t["time_spent"])
-			activeLatestEval += utils.GetTimeSpent(t["programmer_estimate"])
+	for _, tl := range history.Timelines() {
+		if !utils.IsStatusCompleted(tl.Status(), statuses.Types) {
+			activeLatestSpent += utils.GetTimeSpent(tl.Latest["time_spent"])
+			activeLatestEval += utils.GetTimeSpent(tl.Latest["programmer_estimate"])
 		}
 	}
 
@@ -127,14 +132,14 @@ func HandleCompletedTasksGrouped(pbApp *pocketbase.PocketBase, context *app.AppC
 	result := []app.TaskEntry{}
 	var currentResultSpent, currentResultEval float64
 
-	for _, t := range taskLatestData {
-		if utils.IsStatusCompleted(t["status"], context.StatusMap) {
-			taskNumKey := strings.TrimSpace(fmt.Sprintf("%v", t["task_number"]))
-			t["time_spent"] = taskHistorySumSpent[taskNumKey]
-			t["programmer_estimate"] = taskHistorySumEval[taskNumKey]
+	for _, tl := range history.Timelines() {
+		if utils.IsStatusCompleted(tl.Status(), statuses.Types) {
+			t := tl.Latest
+			t["time_spent"] = tl.TotalSpent
+			t["programmer_estimate"] = tl.TotalEstimate
 			result = append(result, t)
-			currentResultSpent += taskHistorySumSpent[taskNumKey]
-			currentResultEval += taskHistorySumEval[taskNumKey]
+			currentResultSpent += tl.TotalSpent
+			currentResultEval += tl.TotalEstimate
 		}
 	}
 
@@ -165,25 +170,16 @@ func HandleReturnedTasks(pbApp *pocketbase.PocketBase, context *app.AppContext,
 		return e.BadRequestError("Valid Start and End dates are required", nil)
 	}
 
-	records, _ := utils.FetchTasksByDateRange(pbApp, start, end, targetUser, 0, 0)
-	utils.SortRecordsChronologically(records)
-
-	latestTasks := make(map[string]app.TaskEntry)
-	for _, r := range records {
-		taskList, _ := utils.ParseTaskData(r.GetString(app.FieldData))
-		for _, t := range taskList {
-			taskNum := strings.TrimSpace(fmt.Sprintf("%v", t["task_number"]))
-			if taskNum == "" { continue }
-			t["source_file_date"] = r.GetString("file_date")
-			t["source_file_id"] = r.Id
-			latestTasks[taskNum] = t
-		}
+	history, err := utils.ReplayTasksByDateRange(pbApp, start, end, targetUser)
+	if err != nil {
+		return e.InternalServerError("Failed to load tasks", err)
 	}
 
+	statuses := context.Statuses()
 	result := []app.TaskEntry{}
-	for _, t := range latestTasks {
-		if utils.IsStatusInProgressReturn(t["status"], context.StatusMap) {
-			result = append(result, t)
+	for _, tl := range history.Timelines() {
+		if utils.IsStatusInProgressReturn(tl.Status(), statuses.Types) {
+			result = append(result, tl.Latest)
 		}
 	}
 	return e.JSON(http.StatusOK, result)
@@ -204,53 +200,59 @@ func HandleUpdateTaskTime(pbApp *pocketbase.PocketBase, context *app.AppContext,
 
 	if err := e.BindBody(&data); err != nil { return e.BadRequestError("Invalid body", err) }
 
-	record, err := pbApp.FindRecordById(app.CollectionTasks, data.RecordId)
-	if err != nil { return e.NotFoundError("Not found", err) }
-
-	taskList, _ := utils.ParseTaskData(record.GetString(app.FieldData))
-	found := false
-	for i, t := range taskList {
-		if strings.TrimSpace(fmt.Sprintf("%v", t["task_number"])) == data.TaskNumber {
-			found = true
-
-			// ВАЖНО: Универсальное получение числа (поддержка json.Number и string)
-			getVal := func(v interface{}) float64 {
-				if v == nil { return 0 }
-				switch val := v.(type) {
-				case json.Number:
-					f, _ := val.Float64()
-					return f
-				case float64: return val
-				case int: return float64(val)
-				case string:
-					f, _ := strconv.ParseFloat(strings.Replace(val, ",", ".", 1), 64)
-					return f
+	// Поиск, правка и сохранение в одной транзакции: хук пересобирает task_entries внутри нее же,
+	// и при его ошибке tasks.data не расходится со строками отчета
+	found, notFound := false, false
+	err := pbApp.RunInTransaction(func(txApp core.App) error {
+		record, err := txApp.FindRecordById(app.CollectionTasks, data.RecordId)
+		if err != nil { notFound = true; return err }
+
+		taskList, _ := utils.ParseTaskData(record.GetString(app.FieldData))
+		for i, t := range taskList {
+			if strings.TrimSpace(fmt.Sprintf("%v", t["task_number"])) == data.TaskNumber {
+				found = true
+
+				// ВАЖНО: Универсальное получение числа (поддержка json.Number и string)
+				getVal := func(v interface{}) float64 {
+					if v == nil { return 0 }
+					switch val := v.(type) {
+					case json.Number:
+						f, _ := val.Float64()
+						return f
+					case float64: return val
+					case int: return float64(val)
+					case string:
+						f, _ := strconv.ParseFloat(strings.Replace(val, ",", ".", 1), 64)
+						return f
+					}
+					return 0
 				}
-				return 0
-			}
 
-			oldTime := getVal(t["time_spent"])
-			origValInDB := getVal(t["original_time_spent"])
+				oldTime := getVal(t["time_spent"])
+				origValInDB := getVal(t["original_time_spent"])
 
-			// Если оригинала НЕТ (0), записываем текущее время как оригинал
-			if origValInDB == 0 {
-				t["original_time_spent"] = oldTime
-			}
+				// Если оригинала НЕТ (0), записываем текущее время как оригинал
+				if origValInDB == 0 {
+					t["original_time_spent"] = oldTime
+				}
 
-			t["time_spent"] = data.NewTime
-			t["is_edited"] = true
-			taskList[i] = t
+				t["time_spent"] = data.NewTime
+				t["is_edited"] = true
+				taskList[i] = t
 			
-			log.Printf("[TaskEdit] Task %s: OldTime=%.2f, OrigSaved=%.2f, NewTime=%.2f", 
-				data.TaskNumber, oldTime, getVal(t["original_time_spent"]), data.NewTime)
-			break
+				log.Printf("[TaskEdit] Task %s: OldTime=%.2f, OrigSaved=%.2f, NewTime=%.2f", 
+					data.TaskNumber, oldTime, getVal(t["original_time_spent"]), data.NewTime)
+				break
+			}
 		}
-	}
 
-	if found {
-		newJson, _ := json.Marshal(taskList)
+		if !found { return nil }
+		newJson, err := json.Marshal(taskList)
+		if err != nil { return err }
 		record.Set(app.FieldData, string(newJson))
-		pbApp.Save(record)
-	}
+		return txApp.Save(record)
+	})
+	if notFound { return e.NotFoundError("Not found", err) }
+	if err != nil { return e.InternalServerError("Failed to save task time", err) }
 	return e.JSON(http.StatusOK, map[string]interface{}{"success": found})
 }
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+	"my_pocketbase_app/internal/app"
+	appCore "my_pocketbase_app/internal/core"
+	"my_pocketbase_app/internal/utils"
+)
+
+func updateTaskTime(t *testing.T, pbApp *pocketbase.PocketBase, auth *core.Record, body string) error {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/kpi/update-task-time", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	e := &core.RequestEvent{App: pbApp, Auth: auth, Event: router.Event{Request: req, Response: rec}}
+	return HandleUpdateTaskTime(pbApp, &app.AppContext{}, e)
+}
+
+func entryHours(t *testing.T, pbApp core.App, taskNumber string) float64 {
+	t.Helper()
+	rec, err := pbApp.FindFirstRecordByFilter(app.CollectionTaskEntries, "task_number = {:num}", dbx.Params{"num": taskNumber})
+	if err != nil {
+		t.Fatalf("task entry %s not found: %v", taskNumber, err)
+	}
+	return rec.GetFloat("time_spent")
+}
+
+func TestUpdateTaskTimeRebuildsEntries(t *testing.T) {
+	pbApp := newTimesheetApp(t)
+	appCore.RegisterTaskEntriesSync(pbApp)
+	anna := saveRecord(t, pbApp, "users", map[string]interface{}{"email": "anna@corp.ru", "name": "Анна"})
+	coordinator := saveRecord(t, pbApp, "users", map[string]interface{}{"email": "boss@corp.ru", "name": "Босс", "is_coordinator": true})
+	addReport(t, pbApp, anna.Id, "2025-12-01", task("101", "Завершена", "CRM", 4, 2), task("102", "Выполняется", "CRM", 1, 0))
+	report, err := pbApp.FindFirstRecordByData(app.CollectionTasks, app.FieldUser, anna.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := updateTaskTime(t, pbApp, coordinator, `{"record_id": "`+report.Id+`", "task_number": "101", "new_time": 2.5}`); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if got := entryHours(t, pbApp, "101"); got != 2.5 {
+		t.Errorf("task_entries.time_spent = %v, want 2.5", got)
+	}
+	if got := entryHours(t, pbApp, "102"); got != 1 {
+		t.Errorf("other rows must be kept, 102 time_spent = %v", got)
+	}
+
+	// Ошибка пересборки task_entries откатывает и правку tasks.data
+	pbApp.OnRecordCreate(app.CollectionTaskEntries).BindFunc(func(e *core.RecordEvent) error {
+		return errors.New("disk full")
+	})
+	err = updateTaskTime(t, pbApp, coordinator, `{"record_id": "`+report.Id+`", "task_number": "101", "new_time": 7}`)
+	var apiErr *router.ApiError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusInternalServerError {
+		t.Fatalf("failed save: expected 500, got %v", err)
+	}
+	report, _ = pbApp.FindRecordById(app.CollectionTasks, report.Id)
+	if strings.Contains(report.GetString(app.FieldData), `"time_spent":7`) {
+		t.Error("tasks.data must be rolled back when task_entries cannot be rebuilt")
+	}
+	if got := entryHours(t, pbApp, "101"); got != 2.5 {
+		t.Errorf("task_entries must be rolled back, 101 time_spent = %v", got)
+	}
+
+	err = updateTaskTime(t, pbApp, coordinator, `{"record_id": "missing", "task_number": "101", "new_time": 1}`)
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+		t.Errorf("unknown report: expected 404, got %v", err)
+	}
+}
+
+func TestRowsWithoutTaskNumberCountHoursOnly(t *testing.T) {
+	pbApp := newTimesheetApp(t)
+	anna := saveRecord(t, pbApp, "users", map[string]interface{}{"email": "anna@corp.ru", "name": "Анна"})
+	addReport(t, pbApp, anna.Id, "2025-12-01",
+		task("101", "Завершена", "CRM", 4, 2),
+		app.TaskEntry{"task_number": "", "status": "Завершена", "time_spent": 1.5},
+		app.TaskEntry{"status": "Завершена", "time_spent": 0.5},
+	)
+
+	var ranking []utils.RankingItem
+	if err := callHandler(t, pbApp, anna, "/api/kpi/ranking?month=2025-12", &ranking, HandleRanking); err != nil {
+		t.Fatal(err)
+	}
+	if len(ranking) != 1 || ranking[0].TotalHours != 6 || ranking[0].CompletedTasks != 1 {
+		t.Errorf("rows without task number must add hours but no tasks: %+v", ranking)
+	}
+
+	stats, err := utils.LoadPeriodStats(pbApp, "2025-12-01 00:00:00", "2025-12-31 23:59:59", nil, estimateStatuses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalHours != 6 || stats.CompletedTasks != 1 || len(stats.Days) != 1 || stats.Days[0].Tasks != 1 {
+		t.Errorf("daily stats must add hours but no tasks: %+v", stats)
+	}
+}
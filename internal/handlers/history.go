@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"my_pocketbase_app/internal/app"
+	"my_pocketbase_app/internal/replay"
+	"my_pocketbase_app/internal/utils"
+)
+
+// TaskReport — строка задачи в одном конкретном отчете
+type TaskReport struct {
+	FileId             string  `json:"file_id"`
+	FileName           string  `json:"file_name"`
+	FileDate           string  `json:"file_date"`
+	UserId             string  `json:"user_id"`
+	UserName           string  `json:"user_name"`
+	Status             string  `json:"status"`
+	TimeSpent          float64 `json:"time_spent"`
+	ProgrammerEstimate float64 `json:"programmer_estimate"`
+	OriginalTimeSpent  float64 `json:"original_time_spent"`
+	IsEdited           bool    `json:"is_edited"`
+	Project            string  `json:"project"`
+	Description        string  `json:"description"`
+}
+
+// HandleTaskHistory возвращает полную историю задачи по всем загруженным отчетам
+// вместе с записью bitrix_tasks, если номер совпадает с bitrix_id.
+func HandleTaskHistory(pbApp *pocketbase.PocketBase, context *app.AppContext, e *core.RequestEvent) error {
+	if e.Auth == nil {
+		return e.UnauthorizedError("Login required", nil)
+	}
+	taskNumber := replay.NormalizeTaskNumber(e.Request.URL.Query().Get("task_number"))
+	if taskNumber == "" {
+		return e.BadRequestError("task_number parameter is required", nil)
+	}
+
+	// Обычный сотрудник видит только свои отчеты (как в RuleTaskView)
+	var visibility dbx.Expression
+	if !e.Auth.GetBool("superadmin") && !e.Auth.GetBool("is_coordinator") {
+		visibility = dbx.Or(dbx.HashExp{app.FieldUser: e.Auth.Id}, dbx.HashExp{"uploaded_by": e.Auth.Id})
+	}
+
+	userNames := make(map[string]string)
+	userName := func(id string) string {
+		if name, ok := userNames[id]; ok {
+			return name
+		}
+		name := "Unknown"
+		if u, err := pbApp.FindRecordById("users", id); err == nil {
+			name = u.GetString("name")
+		}
+		userNames[id] = name
+		return name
+	}
+
+	history := replay.New()
+	reports := []TaskReport{}
+	err := utils.EachTaskContaining(pbApp, taskNumber, visibility, func(r *core.Record) error {
+		taskList, err := utils.ParseTaskData(r.GetString(app.FieldData))
+		if err != nil {
+			log.Printf("[TaskHistory] Skipping file %s with invalid data: %v", r.Id, err)
+			return nil
+		}
+
+		rows := []app.TaskEntry{}
+		for _, t := range taskList {
+			if replay.NormalizeTaskNumber(t["task_number"]) == taskNumber {
+				rows = append(rows, t)
+			}
+		}
+		history.Add(replay.File{ID: r.Id, FileDate: r.GetString(app.FieldFileDate), Tasks: rows})
+
+		for _, t := range rows {
+			edited, _ := t["is_edited"].(bool)
+			reports = append(reports, TaskReport{
+				FileId:             r.Id,
+				FileName:           r.GetString(app.FieldFileName),
+				FileDate:           r.GetString(app.FieldFileDate),
+				UserId:             r.GetString(app.FieldUser),
+				UserName:           userName(r.GetString(app.FieldUser)),
+				Status:             strings.TrimSpace(stringValue(t["status"])),
+				TimeSpent:          utils.GetTimeSpent(t["time_spent"]),
+				ProgrammerEstimate: utils.GetTimeSpent(t["programmer_estimate"]),
+				OriginalTimeSpent:  utils.GetTimeSpent(t["original_time_spent"]),
+				IsEdited:           edited,
+				Project:            stringValue(t["project"]),
+				Description:        stringValue(t["description"]),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return e.InternalServerError("Failed to load task history", err)
+	}
+
+	response := map[string]interface{}{
+		"task_number": taskNumber,
+		"reports":     reports,
+		"bitrix_task": nil,
+	}
+	if tl, ok := history.Get(taskNumber); ok {
+		response["first_seen"] = tl.FirstSeen
+		response["last_seen"] = tl.LastSeen
+		response["status"] = tl.Status()
+		response["total_spent"] = tl.TotalSpent
+		response["total_estimate"] = tl.TotalEstimate
+		response["transitions"] = tl.Transitions
+	}
+
+	if bitrixId, err := strconv.Atoi(taskNumber); err == nil {
+		if bxTask, err := pbApp.FindFirstRecordByFilter("bitrix_tasks", "bitrix_id = {:id}", map[string]interface{}{"id": bitrixId}); err == nil {
+			response["bitrix_task"] = bxTask
+		}
+	}
+
+	return e.JSON(http.StatusOK, response)
+}
+
+func stringValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"my_pocketbase_app/internal/app"
+	"my_pocketbase_app/internal/org"
+	"my_pocketbase_app/internal/utils"
+)
+
+// OrgPerson — сотрудник из bitrix_users и привязанный к нему пользователь системы (если есть)
+type OrgPerson struct {
+	BitrixUserId string `json:"bitrix_user_id"`
+	BitrixId     int    `json:"bitrix_id"`
+	Name         string `json:"name"`
+	WorkPosition string `json:"work_position"`
+	Photo        string `json:"photo"`
+	Active       bool   `json:"active"`
+	UserId       string `json:"user_id,omitempty"`
+	UserName     string `json:"user_name,omitempty"`
+}
+
+// OrgDepartment — узел оргструктуры: руководитель, прямые сотрудники и дочерние отделы
+type OrgDepartment struct {
+	DepartmentID int             `json:"department_id"`
+	ParentID     int             `json:"parent_id"`
+	Name         string          `json:"name"`
+	Head         *OrgPerson      `json:"head"`
+	Members      []OrgPerson     `json:"members"`
+	Children     []OrgDepartment `json:"children"`
+}
+
+// HandleOrgTree возвращает вложенную структуру отделов Bitrix24 с руководителями и сотрудниками
+func HandleOrgTree(pbApp *pocketbase.PocketBase, context *app.AppContext, e *core.RequestEvent) error {
+	if e.Auth == nil {
+		return e.UnauthorizedError("Login required", nil)
+	}
+	tree, err := utils.LoadDepartmentTree(pbApp)
+	if err != nil {
+		return e.InternalServerError("Failed to load departments", err)
+	}
+	depts, err := pbApp.FindAllRecords("bitrix_departments")
+	if err != nil {
+		return e.InternalServerError("Failed to load departments", err)
+	}
+	bxUsers, err := pbApp.FindAllRecords("bitrix_users")
+	if err != nil {
+		return e.InternalServerError("Failed to load Bitrix users", err)
+	}
+	sysUsers, err := pbApp.FindAllRecords("users")
+	if err != nil {
+		return e.InternalServerError("Failed to load users", err)
+	}
+
+	linked := make(map[string]*core.Record, len(sysUsers))
+	for _, u := range sysUsers {
+		if bx := u.GetString("bitrix_user"); bx != "" {
+			linked[bx] = u
+		}
+	}
+	people := make(map[string]OrgPerson, len(bxUsers))
+	for _, u := range bxUsers {
+		p := OrgPerson{
+			BitrixUserId: u.Id,
+			BitrixId:     u.GetInt("bitrix_id"),
+			Name:         u.GetString("full_name"),
+			WorkPosition: u.GetString("work_position"),
+			Photo:        u.GetString("photo"),
+			Active:       u.GetBool("active"),
+		}
+		if sys := linked[u.Id]; sys != nil {
+			p.UserId = sys.Id
+			p.UserName = sys.GetString("name")
+		}
+		people[u.Id] = p
+	}
+
+	deptIds := make(map[string]int, len(depts)) // id записи -> bitrix_id
+	heads := make(map[int]string, len(depts))   // bitrix_id отдела -> id записи руководителя
+	for _, d := range depts {
+		deptIds[d.Id] = d.GetInt("bitrix_id")
+		heads[d.GetInt("bitrix_id")] = d.GetString("head")
+	}
+	members := make(map[int][]OrgPerson)
+	for _, u := range bxUsers {
+		for _, id := range u.GetStringSlice("departments") {
+			if bxId, ok := deptIds[id]; ok {
+				members[bxId] = append(members[bxId], people[u.Id])
+			}
+		}
+	}
+
+	var build func(n *org.Node) OrgDepartment
+	build = func(n *org.Node) OrgDepartment {
+		d := OrgDepartment{DepartmentID: n.ID, ParentID: n.ParentID, Name: n.Name, Members: members[n.ID], Children: []OrgDepartment{}}
+		if head, ok := people[heads[n.ID]]; ok {
+			d.Head = &head
+		}
+		if d.Members == nil {
+			d.Members = []OrgPerson{}
+		}
+		sort.Slice(d.Members, func(i, j int) bool { return d.Members[i].Name < d.Members[j].Name })
+		for _, child := range n.Children {
+			d.Children = append(d.Children, build(child))
+		}
+		return d
+	}
+
+	response := []OrgDepartment{}
+	for _, root := range tree.Nested() {
+		response = append(response, build(root))
+	}
+	return e.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"my_pocketbase_app/internal/app"
+	"my_pocketbase_app/internal/utils"
+)
+
+// BouncedTask — задача, которая хотя бы раз уходила в возврат
+type BouncedTask struct {
+	TaskNumber       string  `json:"task_number"`
+	Bounces          int     `json:"bounces"`
+	HoursAfterReturn float64 `json:"hours_after_return"`
+	Status           string  `json:"status"`
+}
+
+// ReturnStats — показатели качества одного сотрудника за период
+type ReturnStats struct {
+	UserId              string        `json:"user_id"`
+	UserName            string        `json:"user_name"`
+	CompletedTasks      int           `json:"completed_tasks"`
+	ReturnedTasks       int           `json:"returned_tasks"`
+	ReturnRate          float64       `json:"return_rate"`
+	Appeals             int           `json:"appeals"`
+	AvgHoursAfterReturn float64       `json:"avg_hours_after_return"`
+	Tasks               []BouncedTask `json:"tasks"`
+}
+
+// HandleReturnAnalytics считает возвраты и апелляции по сотрудникам за месяц (month) или год (year).
+// Переходы статусов берутся из проигрывания истории отчетов (replay).
+// Обычный сотрудник видит только себя, superadmin и координатор — всех или одного (user).
+func HandleReturnAnalytics(pbApp *pocketbase.PocketBase, context *app.AppContext, e *core.RequestEvent) error {
+	if e.Auth == nil {
+		return e.UnauthorizedError("Login required", nil)
+	}
+	query := e.Request.URL.Query()
+	start, end, ok := utils.PeriodFromQuery(query.Get("month"), query.Get("year"))
+	if !ok {
+		return e.BadRequestError("Valid month (YYYY-MM) or year (YYYY) parameter is required", nil)
+	}
+
+	targetUser := query.Get("user")
+	if !canViewOthers(e.Auth) {
+		if targetUser != "" && targetUser != e.Auth.Id {
+			return e.ForbiddenError("Insufficient permissions", nil)
+		}
+		targetUser = e.Auth.Id
+	}
+
+	histories, err := utils.ReplayTasksByUser(pbApp, start, end, targetUser)
+	if err != nil {
+		return e.InternalServerError("Failed to load tasks", err)
+	}
+	names, err := utils.LoadUserNames(pbApp)
+	if err != nil {
+		return e.InternalServerError("Failed to load users", err)
+	}
+
+	statuses := context.Statuses()
+	response := []ReturnStats{}
+	for userId, history := range histories {
+		stats := ReturnStats{UserId: userId, UserName: names[userId], Tasks: []BouncedTask{}}
+		if stats.UserName == "" {
+			stats.UserName = "Unknown"
+		}
+
+		var hoursAfterReturn float64
+		for _, tl := range history.Timelines() {
+			if utils.IsStatusCompleted(tl.Status(), statuses.Types) {
+				stats.CompletedTasks++
+			}
+
+			bounced := BouncedTask{TaskNumber: tl.TaskNumber, Status: tl.Status()}
+			for _, tr := range tl.Transitions {
+				if utils.IsStatusAppeal(tr.To, statuses.Types) {
+					stats.Appeals++
+				}
+				if utils.IsStatusReturnKind(tr.To, statuses.Types) {
+					// Переход между двумя статусами возврата — это все еще тот же возврат
+					if tr.From != "" && utils.IsStatusReturnKind(tr.From, statuses.Types) {
+						continue
+					}
+					if bounced.Bounces == 0 {
+						bounced.HoursAfterReturn = tl.TotalSpent - tr.SpentBefore
+					}
+					bounced.Bounces++
+				}
+			}
+			if bounced.Bounces > 0 {
+				stats.ReturnedTasks++
+				hoursAfterReturn += bounced.HoursAfterReturn
+				stats.Tasks = append(stats.Tasks, bounced)
+			}
+		}
+
+		if stats.CompletedTasks > 0 {
+			stats.ReturnRate = float64(stats.ReturnedTasks) / float64(stats.CompletedTasks)
+		}
+		if stats.ReturnedTasks > 0 {
+			stats.AvgHoursAfterReturn = hoursAfterReturn / float64(stats.ReturnedTasks)
+		}
+		sort.Slice(stats.Tasks, func(i, j int) bool { return stats.Tasks[i].Bounces > stats.Tasks[j].Bounces })
+		response = append(response, stats)
+	}
+
+	sort.Slice(response, func(i, j int) bool { return response[i].ReturnRate > response[j].ReturnRate })
+	return e.JSON(http.StatusOK, response)
+}
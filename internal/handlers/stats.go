@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"my_pocketbase_app/internal/app"
+	"my_pocketbase_app/internal/utils"
+)
+
+// statsScope определяет, чьи отчеты агрегировать: department — команда отдела (с дочерними),
+// иначе user (по умолчанию — текущий пользователь). Чужого сотрудника и отдел видят только
+// superadmin и координатор (как в RuleTaskView). Ошибка — готовый ответ API.
+func statsScope(pbApp *pocketbase.PocketBase, e *core.RequestEvent) (map[string]bool, error) {
+	query := e.Request.URL.Query()
+	dept, userId := query.Get("department"), query.Get("user")
+	if (dept != "" || (userId != "" && userId != e.Auth.Id)) && !canViewOthers(e.Auth) {
+		return nil, e.ForbiddenError("Insufficient permissions", nil)
+	}
+	if dept != "" {
+		users, ok, err := departmentMembers(pbApp, dept)
+		if err != nil {
+			return nil, e.InternalServerError("Failed to load departments", err)
+		}
+		if !ok {
+			return nil, e.BadRequestError("Unknown department", nil)
+		}
+		return users, nil
+	}
+	if userId == "" {
+		userId = e.Auth.Id
+	}
+	return map[string]bool{userId: true}, nil
+}
+
+// HandleDailyStats отдает статистику по дням за день (date=YYYY-MM-DD), месяц (month) или год (year)
+// для пользователя (user, по умолчанию текущий) или отдела (department).
+func HandleDailyStats(pbApp *pocketbase.PocketBase, context *app.AppContext, e *core.RequestEvent) error {
+	if e.Auth == nil {
+		return e.UnauthorizedError("Login required", nil)
+	}
+	query := e.Request.URL.Query()
+	start, end, ok := utils.PeriodFromQuery(query.Get("month"), query.Get("year"))
+	if date := query.Get("date"); date != "" {
+		if !utils.IsValidDateTime(date) || len(date) != len("2006-01-02") {
+			return e.BadRequestError("Valid date parameter is required (YYYY-MM-DD)", nil)
+		}
+		start, end, ok = date+" 00:00:00", date+" 23:59:59", true
+	}
+	if !ok {
+		return e.BadRequestError("Valid date (YYYY-MM-DD), month (YYYY-MM) or year (YYYY) parameter is required", nil)
+	}
+
+	users, err := statsScope(pbApp, e)
+	if err != nil {
+		return err
+	}
+
+	stats, err := utils.LoadPeriodStats(pbApp, start, end, users, context.Statuses().Types)
+	if err != nil {
+		return e.InternalServerError("Failed to calculate stats", err)
+	}
+	return e.JSON(http.StatusOK, stats)
+}
+
+// HandleComparison сравнивает месяц (month) или год (year) с предыдущим периодом
+// для пользователя (user, по умолчанию текущий) или отдела (department).
+func HandleComparison(pbApp *pocketbase.PocketBase, context *app.AppContext, e *core.RequestEvent) error {
+	if e.Auth == nil {
+		return e.UnauthorizedError("Login required", nil)
+	}
+	query := e.Request.URL.Query()
+	start, end, ok := utils.PeriodFromQuery(query.Get("month"), query.Get("year"))
+	if !ok {
+		return e.BadRequestError("Valid month (YYYY-MM) or year (YYYY) parameter is required", nil)
+	}
+	prevStart, prevEnd, _ := utils.PeriodFromQuery(utils.PreviousPeriod(query.Get("month"), query.Get("year")))
+
+	users, err := statsScope(pbApp, e)
+	if err != nil {
+		return err
+	}
+
+	statuses := context.Statuses()
+	current, err := utils.LoadPeriodStats(pbApp, start, end, users, statuses.Types)
+	if err != nil {
+		return e.InternalServerError("Failed to calculate stats", err)
+	}
+	previous, err := utils.LoadPeriodStats(pbApp, prevStart, prevEnd, users, statuses.Types)
+	if err != nil {
+		return e.InternalServerError("Failed to calculate stats", err)
+	}
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"current":         current,
+		"previous":        previous,
+		"hours_delta":     current.TotalHours - previous.TotalHours,
+		"completed_delta": current.CompletedTasks - previous.CompletedTasks,
+	})
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"my_pocketbase_app/internal/app"
+	"my_pocketbase_app/internal/utils"
+)
+
+const defaultHoursTolerance = 0.25
+
+// TaskHours — часы по одной задаче за день: из Excel-отчета и из учета времени Bitrix24
+type TaskHours struct {
+	TaskNumber  string  `json:"task_number"`
+	ExcelHours  float64 `json:"excel_hours"`
+	BitrixHours float64 `json:"bitrix_hours"`
+	Diff        float64 `json:"diff"`
+	Mismatch    bool    `json:"mismatch"`
+}
+
+// DayReconciliation — сверка часов сотрудника за один день
+type DayReconciliation struct {
+	UserId      string      `json:"user_id"`
+	UserName    string      `json:"user_name"`
+	Date        string      `json:"date"`
+	ExcelHours  float64     `json:"excel_hours"`
+	BitrixHours float64     `json:"bitrix_hours"`
+	Diff        float64     `json:"diff"`
+	Mismatch    bool        `json:"mismatch"`
+	Tasks       []TaskHours `json:"tasks"`
+}
+
+// HandleTimeReconciliation сравнивает по сотрудникам и дням часы из отчетов (task_entries)
+// с часами, списанными в Bitrix24 (bitrix_time_entries) на те же номера задач.
+// Параметры: month или year, необязательные user, tolerance (часы, по умолчанию 0.25), mismatches_only=1.
+func HandleTimeReconciliation(pbApp *pocketbase.PocketBase, context *app.AppContext, e *core.RequestEvent) error {
+	if e.Auth == nil || (!e.Auth.GetBool("superadmin") && !e.Auth.GetBool("is_coordinator")) {
+		return e.ForbiddenError("Only coordinators can reconcile timesheets", nil)
+	}
+	query := e.Request.URL.Query()
+	start, end, ok := utils.PeriodFromQuery(query.Get("month"), query.Get("year"))
+	if !ok {
+		return e.BadRequestError("Valid month (YYYY-MM) or year (YYYY) parameter is required", nil)
+	}
+	tolerance := defaultHoursTolerance
+	if t := query.Get("tolerance"); t != "" {
+		v, err := strconv.ParseFloat(t, 64)
+		if err != nil || v < 0 {
+			return e.BadRequestError("Invalid tolerance", nil)
+		}
+		tolerance = v
+	}
+	targetUser := query.Get("user")
+	mismatchesOnly := query.Get("mismatches_only") == "1"
+
+	type dayKey struct{ user, date string }
+	excel := make(map[dayKey]map[string]float64)
+	bitrix := make(map[dayKey]map[string]float64)
+	add := func(m map[dayKey]map[string]float64, k dayKey, task string, hours float64) {
+		if m[k] == nil {
+			m[k] = make(map[string]float64)
+		}
+		m[k][task] += hours
+	}
+
+	// Часы из отчетов: только числовые номера задач — они совпадают с ID задач Bitrix24
+	excelRows, err := pbApp.DB().NewQuery("SELECT " + app.FieldUser + ", substr(" + app.FieldFileDate + ", 1, 10) AS day, task_number, COALESCE(SUM(time_spent), 0) FROM " + app.CollectionTaskEntries + " WHERE " + app.FieldFileDate + " >= {:start} AND " + app.FieldFileDate + " <= {:end} GROUP BY " + app.FieldUser + ", day, task_number").Bind(map[string]interface{}{"start": start, "end": end}).Rows()
+	if err != nil {
+		return e.InternalServerError("Failed to load report hours", err)
+	}
+	defer excelRows.Close()
+	for excelRows.Next() {
+		var userId, date, taskNumber string
+		var hours float64
+		if err := excelRows.Scan(&userId, &date, &taskNumber, &hours); err != nil {
+			return e.InternalServerError("Failed to load report hours", err)
+		}
+		if targetUser != "" && userId != targetUser {
+			continue
+		}
+		if _, err := strconv.Atoi(taskNumber); err != nil {
+			continue
+		}
+		add(excel, dayKey{userId, date}, taskNumber, hours)
+	}
+	if err := excelRows.Err(); err != nil {
+		return e.InternalServerError("Failed to load report hours", err)
+	}
+
+	// Часы из Bitrix24: пользователь системы связан с bitrix_users через users.bitrix_user
+	bitrixRows, err := pbApp.DB().NewQuery("SELECT u.id, te.day, CAST(te.task_bitrix_id AS INTEGER), COALESCE(SUM(te.seconds), 0) FROM bitrix_time_entries te JOIN users u ON u.bitrix_user = te.user WHERE te.day >= {:start} AND te.day <= {:end} GROUP BY u.id, te.day, te.task_bitrix_id").Bind(map[string]interface{}{"start": start[:10], "end": end[:10]}).Rows()
+	if err != nil {
+		return e.InternalServerError("Failed to load Bitrix hours", err)
+	}
+	defer bitrixRows.Close()
+	for bitrixRows.Next() {
+		var userId, date string
+		var taskId int64
+		var seconds float64
+		if err := bitrixRows.Scan(&userId, &date, &taskId, &seconds); err != nil {
+			return e.InternalServerError("Failed to load Bitrix hours", err)
+		}
+		if targetUser != "" && userId != targetUser {
+			continue
+		}
+		add(bitrix, dayKey{userId, date}, strconv.FormatInt(taskId, 10), seconds/3600)
+	}
+	if err := bitrixRows.Err(); err != nil {
+		return e.InternalServerError("Failed to load Bitrix hours", err)
+	}
+
+	names, err := utils.LoadUserNames(pbApp)
+	if err != nil {
+		return e.InternalServerError("Failed to load users", err)
+	}
+
+	keys := make(map[dayKey]bool)
+	for k := range excel {
+		keys[k] = true
+	}
+	for k := range bitrix {
+		keys[k] = true
+	}
+
+	response := []DayReconciliation{}
+	for k := range keys {
+		day := DayReconciliation{UserId: k.user, UserName: names[k.user], Date: k.date, Tasks: []TaskHours{}}
+		if day.UserName == "" {
+			day.UserName = "Unknown"
+		}
+		tasks := make(map[string]bool)
+		for t := range excel[k] {
+			tasks[t] = true
+		}
+		for t := range bitrix[k] {
+			tasks[t] = true
+		}
+		for t := range tasks {
+			th := TaskHours{TaskNumber: t, ExcelHours: excel[k][t], BitrixHours: bitrix[k][t]}
+			th.Diff = th.ExcelHours - th.BitrixHours
+			th.Mismatch = math.Abs(th.Diff) > tolerance
+			day.ExcelHours += th.ExcelHours
+			day.BitrixHours += th.BitrixHours
+			day.Mismatch = day.Mismatch || th.Mismatch
+			day.Tasks = append(day.Tasks, th)
+		}
+		day.Diff = day.ExcelHours - day.BitrixHours
+		if mismatchesOnly && !day.Mismatch {
+			continue
+		}
+		sort.Slice(day.Tasks, func(i, j int) bool { return math.Abs(day.Tasks[i].Diff) > math.Abs(day.Tasks[j].Diff) })
+		response = append(response, day)
+	}
+
+	sort.Slice(response, func(i, j int) bool {
+		if response[i].Date != response[j].Date {
+			return response[i].Date < response[j].Date
+		}
+		return response[i].UserName < response[j].UserName
+	})
+	return e.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/pocketbase/pocketbase/tools/router"
+	"my_pocketbase_app/internal/app"
+	appCore "my_pocketbase_app/internal/core"
+	_ "my_pocketbase_app/internal/migrations"
+)
+
+func newTimesheetApp(t *testing.T) *pocketbase.PocketBase {
+	t.Helper()
+	testApp, err := tests.NewTestAppWithConfig(core.BaseAppConfig{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create test app: %v", err)
+	}
+	t.Cleanup(testApp.Cleanup)
+	return &pocketbase.PocketBase{App: testApp}
+}
+
+func saveRecord(t *testing.T, pbApp core.App, collection string, fields map[string]interface{}) *core.Record {
+	t.Helper()
+	col, err := pbApp.FindCollectionByNameOrId(collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := core.NewRecord(col)
+	rec.Load(fields)
+	if col.IsAuth() {
+		rec.SetPassword("1234567890")
+	}
+	if err := pbApp.Save(rec); err != nil {
+		t.Fatalf("save %s: %v", collection, err)
+	}
+	return rec
+}
+
+// addReport сохраняет отчет за день и пересобирает его строки task_entries, как хук загрузки
+func addReport(t *testing.T, pbApp core.App, userId, day string, tasks ...app.TaskEntry) {
+	t.Helper()
+	rec := saveRecord(t, pbApp, app.CollectionTasks, map[string]interface{}{
+		app.FieldUser: userId, app.FieldFileDate: day + " 00:00:00.000Z", app.FieldData: tasks,
+	})
+	if err := appCore.SyncTaskEntries(pbApp, rec); err != nil {
+		t.Fatalf("sync task entries: %v", err)
+	}
+}
+
+func reconcile(t *testing.T, pbApp *pocketbase.PocketBase, auth *core.Record, query string) ([]DayReconciliation, error) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/kpi/time-reconciliation?"+query, nil)
+	rec := httptest.NewRecorder()
+	e := &core.RequestEvent{App: pbApp, Auth: auth, Event: router.Event{Request: req, Response: rec}}
+	if err := HandleTimeReconciliation(pbApp, &app.AppContext{}, e); err != nil {
+		return nil, err
+	}
+	var days []DayReconciliation
+	if err := json.Unmarshal(rec.Body.Bytes(), &days); err != nil {
+		t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+	}
+	return days, nil
+}
+
+func TestHandleTimeReconciliation(t *testing.T) {
+	pbApp := newTimesheetApp(t)
+
+	bxAnna := saveRecord(t, pbApp, "bitrix_users", map[string]interface{}{"bitrix_id": 1, "full_name": "Анна Смирнова"})
+	bxIvan := saveRecord(t, pbApp, "bitrix_users", map[string]interface{}{"bitrix_id": 2, "full_name": "Иван Петров"})
+	anna := saveRecord(t, pbApp, "users", map[string]interface{}{"email": "anna@corp.ru", "name": "Анна", "bitrix_user": bxAnna.Id})
+	ivan := saveRecord(t, pbApp, "users", map[string]interface{}{"email": "ivan@corp.ru", "name": "Иван", "bitrix_user": bxIvan.Id})
+	coordinator := saveRecord(t, pbApp, "users", map[string]interface{}{"email": "boss@corp.ru", "name": "Босс", "is_coordinator": true})
+
+	// Анна 1 декабря: 101 совпадает, по 102 в Bitrix на час меньше, INTERNAL не сверяется.
+	// Иван 1 декабря: все в пределах допуска. Анна 2 декабря: часы только в Bitrix.
+	addReport(t, pbApp, anna.Id, "2025-12-01",
+		app.TaskEntry{"task_number": "101", "time_spent": 2.0},
+		app.TaskEntry{"task_number": "102", "time_spent": 3.0},
+		app.TaskEntry{"task_number": "INTERNAL", "time_spent": 1.0},
+	)
+	addReport(t, pbApp, ivan.Id, "2025-12-01", app.TaskEntry{"task_number": "103", "time_spent": 1.5})
+	entries := []struct {
+		id, task int
+		user     string
+		day      string
+		seconds  int
+	}{
+		{1, 101, bxAnna.Id, "2025-12-01", 3600},
+		{2, 101, bxAnna.Id, "2025-12-01", 3600},
+		{3, 102, bxAnna.Id, "2025-12-01", 7200},
+		{4, 103, bxIvan.Id, "2025-12-01", 5400 - 600},
+		{5, 104, bxAnna.Id, "2025-12-02", 1800},
+		{6, 104, bxAnna.Id, "2025-11-30", 1800},
+	}
+	for _, te := range entries {
+		saveRecord(t, pbApp, "bitrix_time_entries", map[string]interface{}{
+			"bitrix_id": te.id, "task_bitrix_id": te.task, "user": te.user, "day": te.day, "seconds": te.seconds,
+		})
+	}
+
+	days, err := reconcile(t, pbApp, coordinator, "month=2025-12")
+	if err != nil {
+		t.Fatalf("reconciliation failed: %v", err)
+	}
+	if len(days) != 3 {
+		t.Fatalf("expected 3 user-days, got %d: %+v", len(days), days)
+	}
+
+	annaDay := days[0]
+	if annaDay.UserId != anna.Id || annaDay.Date != "2025-12-01" || annaDay.ExcelHours != 5 || annaDay.BitrixHours != 4 || annaDay.Diff != 1 || !annaDay.Mismatch {
+		t.Errorf("unexpected Anna 2025-12-01: %+v", annaDay)
+	}
+	if len(annaDay.Tasks) != 2 || annaDay.Tasks[0].TaskNumber != "102" || !annaDay.Tasks[0].Mismatch || annaDay.Tasks[1].TaskNumber != "101" || annaDay.Tasks[1].Mismatch {
+		t.Errorf("Anna tasks must be sorted by diff with only 102 mismatched: %+v", annaDay.Tasks)
+	}
+	ivanDay := days[1]
+	if ivanDay.UserId != ivan.Id || ivanDay.Date != "2025-12-01" || ivanDay.Mismatch {
+		t.Errorf("Ivan's 10 minute gap is within tolerance: %+v", ivanDay)
+	}
+	bitrixOnly := days[2]
+	if bitrixOnly.UserId != anna.Id || bitrixOnly.Date != "2025-12-02" || bitrixOnly.ExcelHours != 0 || bitrixOnly.BitrixHours != 0.5 || !bitrixOnly.Mismatch {
+		t.Errorf("unexpected Anna 2025-12-02: %+v", bitrixOnly)
+	}
+
+	days, err = reconcile(t, pbApp, coordinator, "month=2025-12&mismatches_only=1&user="+anna.Id)
+	if err != nil {
+		t.Fatalf("filtered reconciliation failed: %v", err)
+	}
+	if len(days) != 2 || days[0].UserId != anna.Id || days[1].UserId != anna.Id {
+		t.Errorf("expected Anna's two mismatched days, got %+v", days)
+	}
+
+	days, err = reconcile(t, pbApp, coordinator, "month=2025-12&tolerance=2&user="+anna.Id)
+	if err != nil {
+		t.Fatalf("reconciliation with tolerance failed: %v", err)
+	}
+	if len(days) != 2 || days[0].Mismatch || days[1].Mismatch {
+		t.Errorf("a 2 hour tolerance must hide all of Anna's gaps: %+v", days)
+	}
+
+	_, err = reconcile(t, pbApp, anna, "month=2025-12")
+	var apiErr *router.ApiError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusForbidden {
+		t.Errorf("regular users must get 403, got %v", err)
+	}
+}
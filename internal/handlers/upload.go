@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+	"my_pocketbase_app/internal/app"
+	"my_pocketbase_app/internal/report"
+)
+
+const maxFilesPerDay = 2
+
+// uploadError отдает ошибку загрузки с машинным кодом и (опционально) построчными диагностиками
+func uploadError(e *core.RequestEvent, code, message string, diags []report.Diagnostic) error {
+	if diags == nil {
+		diags = []report.Diagnostic{}
+	}
+	return e.JSON(http.StatusBadRequest, map[string]interface{}{
+		"code":    code,
+		"message": message,
+		"errors":  diags,
+	})
+}
+
+// loadReportFields читает настройку колонок из task_fields в порядке отображения
+func loadReportFields(pbApp core.App) ([]report.Field, error) {
+	records, err := pbApp.FindRecordsByFilter("task_fields", "id != ''", "order", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]report.Field, 0, len(records))
+	for _, r := range records {
+		fields = append(fields, report.Field{
+			Key:      r.GetString("key"),
+			Title:    r.GetString("title"),
+			Type:     r.GetString("type"),
+			Required: r.GetBool("required"),
+		})
+	}
+	return fields, nil
+}
+
+// HandleUploadReport принимает сырой .xlsx, разбирает и валидирует его на сервере,
+// затем атомарно сохраняет запись tasks и запись в upload_logs.
+func HandleUploadReport(pbApp *pocketbase.PocketBase, context *app.AppContext, e *core.RequestEvent) error {
+	auth := e.Auth
+	if auth == nil {
+		return e.UnauthorizedError("Login required", nil)
+	}
+	isAdmin := auth.GetBool("superadmin")
+
+	fileDate := e.Request.FormValue("file_date")
+	day, err := time.Parse("2006-01-02", fileDate)
+	if err != nil {
+		return e.BadRequestError("Valid file_date is required (YYYY-MM-DD)", nil)
+	}
+
+	targetUser := e.Request.FormValue("user")
+	if targetUser == "" {
+		targetUser = auth.Id
+	}
+	if targetUser != auth.Id && !isAdmin && !auth.GetBool("is_coordinator") {
+		return e.ForbiddenError("Insufficient permissions", nil)
+	}
+	if _, err := pbApp.FindRecordById("users", targetUser); err != nil {
+		return e.BadRequestError("Unknown user", err)
+	}
+
+	mf, header, err := e.Request.FormFile("excel_file")
+	if err != nil {
+		return e.BadRequestError("excel_file is required", err)
+	}
+	defer mf.Close()
+
+	// Имя файла должно начинаться с даты отчета (ДД.ММ.ГГГГ)
+	if !strings.HasPrefix(header.Filename, day.Format("02.01.2006")) {
+		return uploadError(e, "bad_prefix", "File name must start with "+day.Format("02.01.2006"), nil)
+	}
+
+	existing, err := pbApp.FindRecordsByFilter(app.CollectionTasks, "user = {:user} && file_date >= {:start} && file_date <= {:end}", "", 0, 0, map[string]interface{}{
+		"user":  targetUser,
+		"start": fileDate + " 00:00:00",
+		"end":   fileDate + " 23:59:59",
+	})
+	if err != nil {
+		return e.InternalServerError("Failed to check existing files", err)
+	}
+	for _, r := range existing {
+		if r.GetString(app.FieldFileName) == header.Filename {
+			return uploadError(e, "file_exists", "File already uploaded", nil)
+		}
+	}
+	if len(existing) >= maxFilesPerDay && !isAdmin {
+		return uploadError(e, "limit_reached", "Daily file limit reached", nil)
+	}
+
+	fields, err := loadReportFields(pbApp)
+	if err != nil {
+		return e.InternalServerError("Failed to load task fields", err)
+	}
+	rows, err := report.ReadXLSX(mf)
+	if err != nil {
+		return uploadError(e, "invalid_file", "Failed to read Excel file", nil)
+	}
+	tasks, diags := report.Parse(rows, fields, context.Statuses().Types, day)
+	if len(diags) > 0 {
+		return uploadError(e, "validation_failed", "Validation failed", diags)
+	}
+
+	excelFile, err := filesystem.NewFileFromMultipart(header)
+	if err != nil {
+		return e.BadRequestError("Invalid excel_file", err)
+	}
+	dataJson, err := json.Marshal(tasks)
+	if err != nil {
+		return e.InternalServerError("Failed to encode tasks", err)
+	}
+
+	var saved *core.Record
+	err = pbApp.RunInTransaction(func(txApp core.App) error {
+		tasksCol, err := txApp.FindCollectionByNameOrId(app.CollectionTasks)
+		if err != nil {
+			return err
+		}
+		record := core.NewRecord(tasksCol)
+		record.Set(app.FieldUser, targetUser)
+		record.Set("uploaded_by", auth.Id)
+		record.Set(app.FieldData, string(dataJson))
+		record.Set(app.FieldFileName, header.Filename)
+		record.Set(app.FieldFileDate, fileDate+" 12:00:00")
+		record.Set("excel_file", excelFile)
+		if err := txApp.Save(record); err != nil {
+			return err
+		}
+
+		logsCol, err := txApp.FindCollectionByNameOrId("upload_logs")
+		if err != nil {
+			return err
+		}
+		logRec := core.NewRecord(logsCol)
+		logRec.Set("file_name", header.Filename)
+		logRec.Set("uploaded_by", auth.Id)
+		logRec.Set("target_user", targetUser)
+		if err := txApp.Save(logRec); err != nil {
+			return err
+		}
+		saved = record
+		return nil
+	})
+	if err != nil {
+		return e.InternalServerError("Failed to save report", err)
+	}
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"id":          saved.Id,
+		"tasks_count": len(tasks),
+	})
+}
+
+// HandleValidateReport — "сухой прогон" загрузки: принимает .xlsx (multipart) или JSON-список задач
+// и возвращает построчные диагностики. Ничего не сохраняет и не расходует дневной лимит файлов.
+func HandleValidateReport(pbApp *pocketbase.PocketBase, context *app.AppContext, e *core.RequestEvent) error {
+	if e.Auth == nil {
+		return e.UnauthorizedError("Login required", nil)
+	}
+
+	fields, err := loadReportFields(pbApp)
+	if err != nil {
+		return e.InternalServerError("Failed to load task fields", err)
+	}
+
+	var fileDate string
+	var rows []report.Row
+	diags := []report.Diagnostic{}
+
+	if strings.HasPrefix(e.Request.Header.Get("Content-Type"), "multipart/form-data") {
+		fileDate = e.Request.FormValue("file_date")
+		mf, _, err := e.Request.FormFile("excel_file")
+		if err != nil {
+			return e.BadRequestError("excel_file is required", err)
+		}
+		defer mf.Close()
+
+		sheet, err := report.ReadXLSX(mf)
+		if err != nil {
+			return uploadError(e, "invalid_file", "Failed to read Excel file", nil)
+		}
+		var fileDiags []report.Diagnostic
+		rows, fileDiags = report.ExtractRows(sheet, fields)
+		diags = append(diags, fileDiags...)
+	} else {
+		body := struct {
+			FileDate string          `json:"file_date"`
+			Tasks    []app.TaskEntry `json:"tasks"`
+		}{}
+		if err := e.BindBody(&body); err != nil {
+			return e.BadRequestError("Invalid body", err)
+		}
+		fileDate = body.FileDate
+		rows = report.RowsFromEntries(body.Tasks)
+	}
+
+	day, err := time.Parse("2006-01-02", fileDate)
+	if err != nil {
+		return e.BadRequestError("Valid file_date is required (YYYY-MM-DD)", nil)
+	}
+
+	tasks, rowDiags := report.Validate(rows, fields, context.Statuses().Types)
+	diags = append(diags, rowDiags...)
+	diags = append(diags, report.CheckReport(rows, day)...)
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"valid":       len(diags) == 0,
+		"rows_count":  len(rows),
+		"tasks_count": len(tasks),
+		"errors":      diags,
+	})
+}
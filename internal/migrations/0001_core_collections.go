@@ -0,0 +1,196 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+	rules "my_pocketbase_app/internal/core"
+)
+
+const excelMimeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+const excelMimeXLS = "application/vnd.ms-excel"
+
+var statusColors = []string{"slate", "gray", "zinc", "neutral", "stone", "red", "orange", "amber", "yellow", "lime", "green", "emerald", "teal", "cyan", "sky", "blue", "indigo", "violet", "purple", "fuchsia", "pink", "rose", "success", "warning", "danger", "info", "primary", "secondary"}
+
+// Базовая схема приложения: настройки, справочники, отчеты, отгулы, уведомления
+func init() {
+	m.Register(func(app core.App) error {
+		settings := findOrNew(app, "settings")
+		ensureFields(settings,
+			&core.TextField{Name: "key", Required: true},
+			&core.TextField{Name: "value", Required: true},
+		)
+		settings.AddIndex("idx_settings_key", true, "key", "")
+		settings.ListRule = types.Pointer(rules.RuleAuthOnly)
+		if err := save(app, settings); err != nil {
+			return err
+		}
+
+		statuses := findOrNew(app, "statuses")
+		ensureFields(statuses,
+			&core.TextField{Name: "title", Required: true, Presentable: true},
+			&core.TextField{Name: "slug", Required: true},
+			&core.SelectField{Name: "color", Required: true, MaxSelect: 1, Values: statusColors},
+			&core.AutodateField{Name: "created", OnCreate: true},
+		)
+		setPresentable(statuses, "title")
+		statuses.ListRule = types.Pointer(rules.RuleAuthOnly)
+		if err := save(app, statuses); err != nil {
+			return err
+		}
+
+		taskFields := findOrNew(app, "task_fields")
+		ensureFields(taskFields,
+			&core.TextField{Name: "key", Required: true},
+			&core.TextField{Name: "title", Required: true, Presentable: true},
+			&core.TextField{Name: "type", Required: true},
+			&core.TextField{Name: "width"},
+			&core.BoolField{Name: "required"},
+			&core.BoolField{Name: "filterable"},
+			&core.NumberField{Name: "order"},
+		)
+		setPresentable(taskFields, "title")
+		taskFields.ListRule = types.Pointer(rules.RuleAuthOnly)
+		if err := save(app, taskFields); err != nil {
+			return err
+		}
+
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		ensureFields(users,
+			&core.BoolField{Name: "superadmin"},
+			&core.BoolField{Name: "is_coordinator"},
+		)
+		setPresentable(users, "name")
+		users.ListRule = types.Pointer(rules.RuleAuthOnly)
+		users.ViewRule = types.Pointer(rules.RuleAuthOnly)
+		if err := save(app, users); err != nil {
+			return err
+		}
+
+		leaveReqs := findOrNew(app, "leave_requests")
+		ensureFields(leaveReqs,
+			&core.RelationField{Name: "user", CollectionId: users.Id, MaxSelect: 1, Required: true},
+			&core.DateField{Name: "start_date", Required: true},
+			&core.DateField{Name: "end_date", Required: true},
+			&core.TextField{Name: "reason", Required: true},
+			&core.SelectField{Name: "status", MaxSelect: 1, Values: []string{"pending", "approved", "rejected"}},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		leaveReqs.ListRule = types.Pointer(rules.RuleLeaveView)
+		leaveReqs.ViewRule = types.Pointer(rules.RuleLeaveView)
+		leaveReqs.CreateRule = types.Pointer(rules.RuleAuthOnly)
+		leaveReqs.UpdateRule = types.Pointer(rules.RuleAdminOrCoordinatorOnly)
+		leaveReqs.DeleteRule = types.Pointer(rules.RuleAdminOrCoordinatorOnly)
+		if err := save(app, leaveReqs); err != nil {
+			return err
+		}
+
+		tasks := findOrNew(app, "tasks")
+		ensureFields(tasks,
+			&core.RelationField{Name: "user", CollectionId: users.Id, MaxSelect: 1, Required: true},
+			&core.RelationField{Name: "uploaded_by", CollectionId: users.Id, MaxSelect: 1},
+			&core.JSONField{Name: "data", MaxSize: 2000000},
+			&core.FileField{Name: "excel_file", MaxSelect: 1, MaxSize: 5242880, MimeTypes: []string{excelMimeXLSX, excelMimeXLS}},
+			&core.DateField{Name: "file_date"},
+			&core.TextField{Name: "file_name"},
+			// Нужен для хронологии загрузок в один день
+			&core.AutodateField{Name: "created", OnCreate: true},
+		)
+		tasks.AddIndex("idx_tasks_file_date", false, "file_date", "")
+		tasks.AddIndex("idx_tasks_user", false, "user", "")
+		tasks.AddIndex("idx_tasks_user_file_date", false, "user,file_date", "")
+		tasks.ListRule = types.Pointer(rules.RuleTaskView)
+		tasks.ViewRule = types.Pointer(rules.RuleTaskView)
+		// Создание только через /api/kpi/upload (серверная валидация отчета)
+		tasks.CreateRule = nil
+		tasks.UpdateRule = types.Pointer(rules.RuleTaskView)
+		tasks.DeleteRule = types.Pointer(rules.RuleTaskDelete)
+		if err := save(app, tasks); err != nil {
+			return err
+		}
+
+		// Строки отчетов: одна запись на строку Excel (производная от tasks.data)
+		entries := findOrNew(app, "task_entries")
+		ensureFields(entries,
+			&core.RelationField{Name: "task", CollectionId: tasks.Id, MaxSelect: 1, Required: true},
+			&core.RelationField{Name: "user", CollectionId: users.Id, MaxSelect: 1, Required: true},
+			&core.DateField{Name: "file_date"},
+			&core.NumberField{Name: "line", OnlyInt: true},
+			&core.TextField{Name: "task_number", Required: true, Presentable: true},
+			&core.TextField{Name: "status"},
+			&core.NumberField{Name: "time_spent"},
+			&core.NumberField{Name: "programmer_estimate"},
+			&core.TextField{Name: "project"},
+			&core.JSONField{Name: "extra", MaxSize: 200000},
+		)
+		entries.AddIndex("idx_task_entries_task", false, "task", "")
+		entries.AddIndex("idx_task_entries_file_date", false, "file_date", "")
+		entries.AddIndex("idx_task_entries_user_file_date", false, "user,file_date", "")
+		entries.AddIndex("idx_task_entries_task_number", false, "task_number", "")
+		entries.ListRule = types.Pointer(rules.RuleTaskEntryView)
+		entries.ViewRule = types.Pointer(rules.RuleTaskEntryView)
+		entries.CreateRule = nil
+		entries.UpdateRule = nil
+		entries.DeleteRule = nil
+		if err := save(app, entries); err != nil {
+			return err
+		}
+
+		deletionLogs := findOrNew(app, "deletion_logs")
+		ensureFields(deletionLogs,
+			&core.TextField{Name: "file_name", Required: true},
+			&core.TextField{Name: "reason", Required: true},
+			&core.RelationField{Name: "deleted_by", CollectionId: users.Id, MaxSelect: 1},
+			&core.FileField{Name: "excel_file", MaxSelect: 1, MimeTypes: []string{excelMimeXLSX, excelMimeXLS}},
+			&core.AutodateField{Name: "created", OnCreate: true},
+		)
+		uploadLogs := findOrNew(app, "upload_logs")
+		ensureFields(uploadLogs,
+			&core.TextField{Name: "file_name", Required: true},
+			&core.RelationField{Name: "uploaded_by", CollectionId: users.Id, MaxSelect: 1},
+			&core.RelationField{Name: "target_user", CollectionId: users.Id, MaxSelect: 1},
+			&core.AutodateField{Name: "created", OnCreate: true},
+		)
+		for _, col := range []*core.Collection{deletionLogs, uploadLogs} {
+			col.ListRule = types.Pointer(rules.RuleAdminOnly)
+			col.ViewRule = types.Pointer(rules.RuleAdminOnly)
+			col.CreateRule = types.Pointer(rules.RuleAdminOnly)
+			if err := save(app, col); err != nil {
+				return err
+			}
+		}
+
+		notifications := findOrNew(app, "notifications")
+		ensureFields(notifications,
+			&core.RelationField{Name: "user", CollectionId: users.Id, MaxSelect: 1, Required: true},
+			&core.TextField{Name: "message", Required: true},
+			&core.BoolField{Name: "is_read"},
+			&core.TextField{Name: "type"},
+			&core.AutodateField{Name: "created", OnCreate: true},
+		)
+		notifications.ListRule = types.Pointer(rules.RuleNotification)
+		notifications.ViewRule = types.Pointer(rules.RuleNotification)
+		notifications.UpdateRule = types.Pointer(rules.RuleNotification)
+		notifications.DeleteRule = types.Pointer(rules.RuleNotification)
+		notifications.CreateRule = types.Pointer(rules.RuleAuthOnly)
+		if err := save(app, notifications); err != nil {
+			return err
+		}
+
+		// Пустая коллекция-сигнал: запись в ней будит подписчиков realtime на пересчет рейтинга
+		rankingUpdates := findOrNew(app, "ranking_updates")
+		rankingUpdates.ListRule = types.Pointer(rules.RuleAuthOnly)
+		rankingUpdates.ViewRule = types.Pointer(rules.RuleAuthOnly)
+		rankingUpdates.CreateRule = types.Pointer(rules.RuleAuthOnly)
+		return save(app, rankingUpdates)
+	}, func(app core.App) error {
+		if err := deleteCollections(app, "ranking_updates", "notifications", "upload_logs", "deletion_logs", "task_entries", "tasks", "leave_requests", "task_fields", "statuses", "settings"); err != nil {
+			return err
+		}
+		return removeFields(app, "users", "superadmin", "is_coordinator")
+	})
+}
@@ -0,0 +1,237 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+	rules "my_pocketbase_app/internal/core"
+)
+
+// ruleActiveTasks — видимость кэша активных задач: ответственный видит свои, координаторы и админы — все
+const ruleActiveTasks = "@request.auth.superadmin = true || @request.auth.is_coordinator = true || responsible = @request.auth.bitrix_user"
+
+// Реплика Bitrix24: отделы, группы, пользователи, задачи и служебные журналы синхронизации
+func init() {
+	m.Register(func(app core.App) error {
+		depts := findOrNew(app, "bitrix_departments")
+		ensureFields(depts,
+			&core.NumberField{Name: "bitrix_id", Required: true},
+			&core.TextField{Name: "name", Required: true, Presentable: true},
+			&core.NumberField{Name: "parent_bitrix_id"},
+			&core.NumberField{Name: "head_bitrix_id"},
+		)
+		setPresentable(depts, "name")
+		depts.AddIndex("idx_bx_dept_id", true, "bitrix_id", "")
+		depts.ListRule = types.Pointer(rules.RuleAuthOnly)
+		if err := save(app, depts); err != nil {
+			return err
+		}
+
+		groups := findOrNew(app, "bitrix_groups")
+		ensureFields(groups,
+			&core.NumberField{Name: "bitrix_id", Required: true},
+			&core.TextField{Name: "name", Required: true, Presentable: true},
+			&core.TextField{Name: "description", Max: 500000},
+		)
+		setPresentable(groups, "name")
+		groups.AddIndex("idx_bx_group_id", true, "bitrix_id", "")
+		groups.ListRule = types.Pointer(rules.RuleAuthOnly)
+		if err := save(app, groups); err != nil {
+			return err
+		}
+
+		users := findOrNew(app, "bitrix_users")
+		ensureFields(users,
+			&core.NumberField{Name: "bitrix_id", Required: true},
+			&core.TextField{Name: "full_name", Presentable: true},
+			&core.RelationField{Name: "departments", CollectionId: depts.Id, MaxSelect: 99},
+			&core.TextField{Name: "first_name"},
+			&core.TextField{Name: "last_name"},
+			&core.TextField{Name: "second_name"},
+			&core.TextField{Name: "email"},
+			&core.BoolField{Name: "active"},
+			&core.TextField{Name: "work_position"},
+			&core.TextField{Name: "photo", Max: 2000},
+		)
+		setPresentable(users, "full_name")
+		users.AddIndex("idx_bx_user_id", true, "bitrix_id", "")
+		users.AddIndex("idx_bx_user_email", false, "email", "")
+		users.ListRule = types.Pointer(rules.RuleAuthOnly)
+		if err := save(app, users); err != nil {
+			return err
+		}
+
+		// Связи на себя и на bitrix_users можно добавить только после сохранения обеих коллекций
+		ensureFields(depts,
+			&core.RelationField{Name: "parent", CollectionId: depts.Id, MaxSelect: 1},
+			&core.RelationField{Name: "head", CollectionId: users.Id, MaxSelect: 1},
+		)
+		if err := save(app, depts); err != nil {
+			return err
+		}
+
+		// bitrix_tasks — полный архив, bitrix_tasks_active — кэш незавершенных задач для UI (те же поля)
+		tasks := findOrNew(app, "bitrix_tasks")
+		ensureTaskFields(tasks, users, groups)
+		tasks.AddIndex("idx_bx_task_id", true, "bitrix_id", "")
+		tasks.AddIndex("idx_bx_task_modified", false, "bitrix_modified", "")
+		tasks.ListRule = types.Pointer(rules.RuleAuthOnly)
+		tasks.ViewRule = types.Pointer(rules.RuleAuthOnly)
+		if err := save(app, tasks); err != nil {
+			return err
+		}
+		ensureFields(tasks, &core.RelationField{Name: "parent", CollectionId: tasks.Id, MaxSelect: 1})
+		if err := save(app, tasks); err != nil {
+			return err
+		}
+
+		active := findOrNew(app, "bitrix_tasks_active")
+		ensureTaskFields(active, users, groups)
+		active.AddIndex("idx_bx_active_task_id", true, "bitrix_id", "")
+		active.ListRule = types.Pointer(ruleActiveTasks)
+		active.ViewRule = types.Pointer(ruleActiveTasks)
+		if err := save(app, active); err != nil {
+			return err
+		}
+		ensureFields(active, &core.RelationField{Name: "parent", CollectionId: active.Id, MaxSelect: 1})
+		if err := save(app, active); err != nil {
+			return err
+		}
+
+		// Журнал задач, удаленных в Bitrix24 (заполняется сверкой ReconcileDeleted)
+		deleted := findOrNew(app, "bitrix_deletion_logs")
+		ensureFields(deleted,
+			&core.NumberField{Name: "bitrix_id", Required: true, Presentable: true},
+			&core.TextField{Name: "title"},
+			&core.JSONField{Name: "removed_from"},
+			&core.JSONField{Name: "snapshot", MaxSize: 2000000},
+			&core.AutodateField{Name: "created", OnCreate: true},
+		)
+		deleted.AddIndex("idx_bx_deleted_id", false, "bitrix_id", "")
+		deleted.ListRule = types.Pointer(rules.RuleAdminOnly)
+		deleted.ViewRule = types.Pointer(rules.RuleAdminOnly)
+		if err := save(app, deleted); err != nil {
+			return err
+		}
+
+		// История запусков синхронизации
+		runs := findOrNew(app, "bitrix_sync_runs")
+		ensureFields(runs,
+			&core.SelectField{Name: "kind", Required: true, MaxSelect: 1, Values: []string{"full", "incremental", "reconcile"}},
+			&core.SelectField{Name: "status", Required: true, MaxSelect: 1, Values: []string{"running", "success", "failed"}},
+			&core.DateField{Name: "started", Required: true},
+			&core.DateField{Name: "finished"},
+			&core.NumberField{Name: "created_count"},
+			&core.NumberField{Name: "updated_count"},
+			&core.NumberField{Name: "deleted_count"},
+			&core.TextField{Name: "error", Max: 5000},
+			&core.DateField{Name: "high_water_mark"},
+		)
+		runs.AddIndex("idx_bx_sync_runs_started", false, "started", "")
+		runs.ListRule = types.Pointer(rules.RuleAuthOnly)
+		runs.ViewRule = types.Pointer(rules.RuleAuthOnly)
+		if err := save(app, runs); err != nil {
+			return err
+		}
+
+		// Записи учета времени по задачам (task.elapseditem)
+		timeEntries := findOrNew(app, "bitrix_time_entries")
+		ensureFields(timeEntries,
+			&core.NumberField{Name: "bitrix_id", Required: true, Presentable: true},
+			&core.NumberField{Name: "task_bitrix_id", Required: true},
+			&core.RelationField{Name: "user", CollectionId: users.Id, MaxSelect: 1},
+			&core.NumberField{Name: "user_bitrix_id"},
+			&core.DateField{Name: "date"},
+			// day — календарный день записи в часовом поясе портала (YYYY-MM-DD), по нему идет сверка с отчетами
+			&core.TextField{Name: "day", Max: 10},
+			&core.NumberField{Name: "seconds"},
+			&core.TextField{Name: "comment", Max: 50000},
+		)
+		timeEntries.AddIndex("idx_bx_time_id", true, "bitrix_id", "")
+		timeEntries.AddIndex("idx_bx_time_user_day", false, "user, day", "")
+		timeEntries.AddIndex("idx_bx_time_task", false, "task_bitrix_id", "")
+		timeEntries.ListRule = types.Pointer(rules.RuleAdminOrCoordinatorOnly)
+		timeEntries.ViewRule = types.Pointer(rules.RuleAdminOrCoordinatorOnly)
+		if err := save(app, timeEntries); err != nil {
+			return err
+		}
+
+		// Комментарии задач (task.commentitem.getlist)
+		comments := findOrNew(app, "bitrix_task_comments")
+		ensureFields(comments,
+			&core.NumberField{Name: "bitrix_id", Required: true},
+			&core.NumberField{Name: "task_bitrix_id", Required: true},
+			&core.RelationField{Name: "author", CollectionId: users.Id, MaxSelect: 1},
+			&core.TextField{Name: "author_name"},
+			&core.TextField{Name: "text", Max: 500000},
+			&core.DateField{Name: "posted"},
+		)
+		comments.AddIndex("idx_bx_comment_id", true, "bitrix_id", "")
+		comments.AddIndex("idx_bx_comment_task", false, "task_bitrix_id", "")
+		comments.ListRule = types.Pointer(rules.RuleAuthOnly)
+		comments.ViewRule = types.Pointer(rules.RuleAuthOnly)
+		if err := save(app, comments); err != nil {
+			return err
+		}
+
+		// Чек-листы задач (task.checklistitem.getlist)
+		checklist := findOrNew(app, "bitrix_task_checklist")
+		ensureFields(checklist,
+			&core.NumberField{Name: "bitrix_id", Required: true},
+			&core.NumberField{Name: "task_bitrix_id", Required: true},
+			// parent_bitrix_id — ID пункта-заголовка чек-листа (0 для корневых пунктов)
+			&core.NumberField{Name: "parent_bitrix_id"},
+			&core.TextField{Name: "title", Max: 5000, Presentable: true},
+			&core.NumberField{Name: "sort_index"},
+			&core.BoolField{Name: "is_complete"},
+		)
+		checklist.AddIndex("idx_bx_checklist_id", true, "bitrix_id", "")
+		checklist.AddIndex("idx_bx_checklist_task", false, "task_bitrix_id", "")
+		checklist.ListRule = types.Pointer(rules.RuleAuthOnly)
+		checklist.ViewRule = types.Pointer(rules.RuleAuthOnly)
+		return save(app, checklist)
+	}, func(app core.App) error {
+		// users.bitrix_user ссылается на bitrix_users — его снимает откат 0003.
+		// bitrix_departments.head тоже ссылается на bitrix_users, поэтому снимается до удаления коллекций.
+		if err := removeFields(app, "bitrix_departments", "head"); err != nil {
+			return err
+		}
+		return deleteCollections(app,
+			"bitrix_task_checklist", "bitrix_task_comments", "bitrix_time_entries", "bitrix_sync_runs", "bitrix_deletion_logs",
+			"bitrix_tasks_active", "bitrix_tasks", "bitrix_users", "bitrix_groups", "bitrix_departments",
+		)
+	})
+}
+
+// ensureTaskFields — общий набор полей bitrix_tasks и bitrix_tasks_active (кроме связи parent на себя)
+func ensureTaskFields(col, users, groups *core.Collection) {
+	ensureFields(col,
+		&core.NumberField{Name: "bitrix_id", Required: true, Presentable: true},
+		&core.TextField{Name: "title", Required: true},
+		&core.TextField{Name: "description", Max: 500000},
+		&core.NumberField{Name: "status"},
+
+		&core.RelationField{Name: "responsible", CollectionId: users.Id, MaxSelect: 1},
+		&core.RelationField{Name: "created_by", CollectionId: users.Id, MaxSelect: 1},
+		&core.RelationField{Name: "group", CollectionId: groups.Id, MaxSelect: 1},
+
+		&core.NumberField{Name: "priority"},
+		&core.NumberField{Name: "comments_count"},
+		&core.NumberField{Name: "parent_bitrix_id"},
+		&core.NumberField{Name: "time_estimate"},
+		&core.NumberField{Name: "time_spent"},
+
+		&core.DateField{Name: "created_date"},
+		&core.DateField{Name: "status_changed_date"},
+		&core.DateField{Name: "bitrix_modified"},
+		&core.DateField{Name: "deadline"},
+		&core.DateField{Name: "start_date_plan"},
+		&core.DateField{Name: "end_date_plan"},
+		&core.DateField{Name: "closed_date"},
+
+		&core.JSONField{Name: "tags"},
+		&core.JSONField{Name: "accomplices"},
+		&core.JSONField{Name: "auditors"},
+		&core.JSONField{Name: "uf_crm_task"},
+	)
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Связь пользователя системы с пользователем Bitrix24 (users.bitrix_user)
+func init() {
+	m.Register(func(app core.App) error {
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+		bxUsers, err := app.FindCollectionByNameOrId("bitrix_users")
+		if err != nil {
+			return err
+		}
+		ensureFields(users, &core.RelationField{Name: "bitrix_user", CollectionId: bxUsers.Id, MaxSelect: 1})
+		return save(app, users)
+	}, func(app core.App) error {
+		return removeFields(app, "users", "bitrix_user")
+	})
+}
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+	rules "my_pocketbase_app/internal/core"
+)
+
+// Агрегат по task_entries вместо разбора tasks.data через json_each
+const monthlyUserStatsQuery = `SELECT (te.user || '_' || strftime('%Y-%m', te.file_date)) as id, te.user as user, u.name as user_name, u.email as user_email, strftime('%Y-%m', te.file_date) as month, COALESCE(SUM(te.time_spent), 0) as total_hours FROM task_entries te JOIN users u ON u.id = te.user GROUP BY te.user, month`
+
+// Представление monthly_user_stats — часы сотрудников по месяцам
+func init() {
+	m.Register(func(app core.App) error {
+		view, err := app.FindCollectionByNameOrId("monthly_user_stats")
+		if err != nil {
+			view = core.NewViewCollection("monthly_user_stats")
+		}
+		view.ViewQuery = monthlyUserStatsQuery
+		view.ListRule = types.Pointer(rules.RuleAuthOnly)
+		return save(app, view)
+	}, func(app core.App) error {
+		return deleteCollections(app, "monthly_user_stats")
+	})
+}
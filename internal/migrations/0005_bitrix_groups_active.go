@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// bitrix_groups.active: SyncGroups записывал признак активности группы, но поля не было и значение терялось
+func init() {
+	m.Register(func(app core.App) error {
+		groups, err := app.FindCollectionByNameOrId("bitrix_groups")
+		if err != nil {
+			return err
+		}
+		ensureFields(groups, &core.BoolField{Name: "active"})
+		return save(app, groups)
+	}, func(app core.App) error {
+		return removeFields(app, "bitrix_groups", "active")
+	})
+}
@@ -0,0 +1,21 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// statuses.type: тип статуса был только в config.json, и админка видела статусы без него.
+// Значения проставляет сверка с config.json при запуске (internal/reconcile).
+func init() {
+	m.Register(func(app core.App) error {
+		statuses, err := app.FindCollectionByNameOrId("statuses")
+		if err != nil {
+			return err
+		}
+		ensureFields(statuses, &core.SelectField{Name: "type", MaxSelect: 1, Values: []string{"final", "in_progress", "return"}})
+		return save(app, statuses)
+	}, func(app core.App) error {
+		return removeFields(app, "statuses", "type")
+	})
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Коллекция secrets: зашифрованные учетные данные (вебхук Bitrix24).
+// Правила не заданы — через API коллекция доступна только суперпользователям PocketBase,
+// а value скрыто из ответов; читает ее только серверный код (internal/secrets).
+func init() {
+	m.Register(func(app core.App) error {
+		secrets := findOrNew(app, "secrets")
+		ensureFields(secrets,
+			&core.TextField{Name: "key", Required: true, Presentable: true},
+			&core.TextField{Name: "value", Required: true, Hidden: true, Max: 10000},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		secrets.AddIndex("idx_secrets_key", true, "key", "")
+		return save(app, secrets)
+	}, func(app core.App) error {
+		return deleteCollections(app, "secrets")
+	})
+}
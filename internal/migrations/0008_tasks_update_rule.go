@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+	rules "my_pocketbase_app/internal/core"
+)
+
+// tasks.UpdateRule: владелец мог PATCH-ем переписать tasks.data в обход серверной валидации
+// и исказить рейтинг. Отчеты меняются только через /api/kpi/upload.
+func init() {
+	m.Register(func(app core.App) error {
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+		tasks.UpdateRule = nil
+		return save(app, tasks)
+	}, func(app core.App) error {
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+		tasks.UpdateRule = types.Pointer(rules.RuleTaskView)
+		return save(app, tasks)
+	})
+}
@@ -0,0 +1,72 @@
+package migrations
+
+import (
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"my_pocketbase_app/internal/app"
+)
+
+var statusTypeValues = []string{app.StatusFinal, app.StatusFinalReturn, app.StatusAppeal, app.StatusInProgress, app.StatusReturn}
+
+// statuses.type: final_return и appeal. Раньше возвраты и апелляции угадывались по суффиксу slug
+// (*_return, *_appeal), и переименование статуса в админке молча меняло аналитику возвратов.
+// Существующие завершенные статусы с такими slug переводятся на новые типы один раз.
+func init() {
+	m.Register(func(app core.App) error {
+		statuses, err := app.FindCollectionByNameOrId("statuses")
+		if err != nil {
+			return err
+		}
+		if f, ok := statuses.Fields.GetByName("type").(*core.SelectField); ok {
+			f.Values = statusTypeValues
+		}
+		if err := save(app, statuses); err != nil {
+			return err
+		}
+		return retypeStatuses(app, func(slug, statusType string) string {
+			switch {
+			case statusType == "final" && strings.HasSuffix(slug, "_return"):
+				return "final_return"
+			case statusType == "final" && strings.HasSuffix(slug, "_appeal"):
+				return "appeal"
+			}
+			return statusType
+		})
+	}, func(app core.App) error {
+		if err := retypeStatuses(app, func(slug, statusType string) string {
+			if statusType == "final_return" || statusType == "appeal" {
+				return "final"
+			}
+			return statusType
+		}); err != nil {
+			return err
+		}
+		statuses, err := app.FindCollectionByNameOrId("statuses")
+		if err != nil {
+			return err
+		}
+		if f, ok := statuses.Fields.GetByName("type").(*core.SelectField); ok {
+			f.Values = []string{"final", "in_progress", "return"}
+		}
+		return save(app, statuses)
+	})
+}
+
+// retypeStatuses пересчитывает statuses.type функцией next(slug, type)
+func retypeStatuses(app core.App, next func(slug, statusType string) string) error {
+	records, err := app.FindAllRecords("statuses")
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		if t := next(r.GetString("slug"), r.GetString("type")); t != r.GetString("type") {
+			r.Set("type", t)
+			if err := app.Save(r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// task_entries.task_number необязателен: строки отчета без номера задачи хранятся ради часов
+// (рейтинг и дневная статистика суммируют time_spent по всем строкам), но в подсчет задач не входят.
+func init() {
+	m.Register(func(app core.App) error {
+		return setTaskNumberRequired(app, false)
+	}, func(app core.App) error {
+		if _, err := app.DB().NewQuery("DELETE FROM task_entries WHERE task_number = ''").Execute(); err != nil {
+			return err
+		}
+		return setTaskNumberRequired(app, true)
+	})
+}
+
+func setTaskNumberRequired(app core.App, required bool) error {
+	entries, err := app.FindCollectionByNameOrId("task_entries")
+	if err != nil {
+		return err
+	}
+	field, ok := entries.Fields.GetByName("task_number").(*core.TextField)
+	if !ok {
+		return nil
+	}
+	field.Required = required
+	return save(app, entries)
+}
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// bitrix_time_entries.bitrix_created — дата создания записи в Bitrix24: по ней SyncTimeEntries находит
+// недавние записи, чтобы перечитать их и удалить те, что стерли на портале
+func init() {
+	m.Register(func(app core.App) error {
+		timeEntries, err := app.FindCollectionByNameOrId("bitrix_time_entries")
+		if err != nil {
+			return err
+		}
+		ensureFields(timeEntries, &core.DateField{Name: "bitrix_created"})
+		timeEntries.AddIndex("idx_bx_time_created", false, "bitrix_created", "")
+		return save(app, timeEntries)
+	}, func(app core.App) error {
+		timeEntries, err := app.FindCollectionByNameOrId("bitrix_time_entries")
+		if err != nil {
+			return err
+		}
+		timeEntries.RemoveIndex("idx_bx_time_created")
+		timeEntries.Fields.RemoveByName("bitrix_created")
+		return save(app, timeEntries)
+	})
+}
@@ -0,0 +1,70 @@
+// Package migrations — нумерованные миграции схемы (PocketBase migrations).
+// Применяются автоматически при запуске serve (RunAllMigrations) и вручную командой migrate.
+//
+// Базовые миграции (0001–0004) идемпотентны: на базах, созданных до появления миграций,
+// они не пересоздают коллекции, а докидывают недостающие поля, индексы и правила.
+// Новые изменения схемы — только новым файлом с очередным номером, старые файлы не меняются.
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// findOrNew возвращает существующую коллекцию или новую base-коллекцию с этим именем
+func findOrNew(app core.App, name string) *core.Collection {
+	if col, err := app.FindCollectionByNameOrId(name); err == nil {
+		return col
+	}
+	return core.NewBaseCollection(name)
+}
+
+// ensureFields добавляет поля, которых еще нет в коллекции. Существующие поля не трогает.
+func ensureFields(col *core.Collection, fields ...core.Field) {
+	for _, f := range fields {
+		if col.Fields.GetByName(f.GetName()) == nil {
+			col.Fields.Add(f)
+		}
+	}
+}
+
+// setPresentable помечает текстовое поле как отображаемое в связях админки
+func setPresentable(col *core.Collection, name string) {
+	if f, ok := col.Fields.GetByName(name).(*core.TextField); ok {
+		f.Presentable = true
+	}
+}
+
+func save(app core.App, col *core.Collection) error {
+	if err := app.Save(col); err != nil {
+		return fmt.Errorf("save %s: %w", col.Name, err)
+	}
+	return nil
+}
+
+// deleteCollections удаляет коллекции по имени (для down); отсутствующие пропускает
+func deleteCollections(app core.App, names ...string) error {
+	for _, name := range names {
+		col, err := app.FindCollectionByNameOrId(name)
+		if err != nil {
+			continue
+		}
+		if err := app.Delete(col); err != nil {
+			return fmt.Errorf("delete %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// removeFields удаляет поля из коллекции (для down)
+func removeFields(app core.App, name string, fields ...string) error {
+	col, err := app.FindCollectionByNameOrId(name)
+	if err != nil {
+		return nil
+	}
+	for _, f := range fields {
+		col.Fields.RemoveByName(f)
+	}
+	return save(app, col)
+}
@@ -0,0 +1,154 @@
+package migrations
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+	appConst "my_pocketbase_app/internal/app"
+)
+
+func newTestApp(t *testing.T) core.App {
+	t.Helper()
+	app, err := tests.NewTestAppWithConfig(core.BaseAppConfig{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create test app: %v", err)
+	}
+	t.Cleanup(app.Cleanup)
+	return app
+}
+
+func TestMigrationsCreateSchema(t *testing.T) {
+	app := newTestApp(t)
+
+	for _, name := range []string{
+		"settings", "statuses", "task_fields", "tasks", "task_entries", "monthly_user_stats",
+		"bitrix_departments", "bitrix_users", "bitrix_tasks", "bitrix_tasks_active", "bitrix_sync_runs",
+	} {
+		if _, err := app.FindCollectionByNameOrId(name); err != nil {
+			t.Errorf("collection %s missing: %v", name, err)
+		}
+	}
+	depts, _ := app.FindCollectionByNameOrId("bitrix_departments")
+	if f, ok := depts.Fields.GetByName("parent").(*core.RelationField); !ok || f.CollectionId != depts.Id {
+		t.Errorf("bitrix_departments.parent should be a self relation, got %#v", depts.Fields.GetByName("parent"))
+	}
+
+	tasks, _ := app.FindCollectionByNameOrId("tasks")
+	if tasks.CreateRule != nil || tasks.UpdateRule != nil {
+		t.Errorf("tasks must be created and updated only by the server, got create=%v update=%v", tasks.CreateRule, tasks.UpdateRule)
+	}
+
+	// Строки отчета без номера задачи хранятся ради часов
+	entries, _ := app.FindCollectionByNameOrId("task_entries")
+	if entries.Fields.GetByName("task_number").(*core.TextField).Required {
+		t.Error("task_entries.task_number must be optional")
+	}
+
+	// config.Load проверяет цвета по app.StatusColors — список должен совпадать со схемой
+	statuses, _ := app.FindCollectionByNameOrId("statuses")
+	if f, ok := statuses.Fields.GetByName("color").(*core.SelectField); !ok || !slices.Equal(f.Values, appConst.StatusColors) {
+		t.Errorf("statuses.color values must match app.StatusColors, got %#v", statuses.Fields.GetByName("color"))
+	}
+
+	list, err := List(app)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != len(core.AppMigrations.Items()) {
+		t.Fatalf("List returned %d entries, want %d", len(list), len(core.AppMigrations.Items()))
+	}
+	for _, s := range list {
+		if s.Applied.IsZero() || s.Missing {
+			t.Errorf("migration %s: applied=%v missing=%v", s.File, s.Applied, s.Missing)
+		}
+	}
+}
+
+// Базовые миграции должны проходить повторно на уже созданной схеме (БД до перехода на миграции)
+func TestMigrationsUpIdempotent(t *testing.T) {
+	app := newTestApp(t)
+
+	for _, mig := range core.AppMigrations.Items() {
+		if err := mig.Up(app); err != nil {
+			t.Fatalf("re-running %s: %v", mig.File, err)
+		}
+	}
+}
+
+// Откат всех миграций приложения и повторное применение: каждая функция отката должна быть рабочей
+func TestMigrationsDownUpRoundTrip(t *testing.T) {
+	app := newTestApp(t)
+	runner := core.NewMigrationsRunner(app, core.AppMigrations)
+	total := len(core.AppMigrations.Items())
+
+	reverted, err := runner.Down(total)
+	if err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if len(reverted) != total {
+		t.Fatalf("reverted %d migrations, want %d", len(reverted), total)
+	}
+	for _, name := range []string{"statuses", "tasks", "bitrix_tasks", "bitrix_departments"} {
+		if _, err := app.FindCollectionByNameOrId(name); err == nil {
+			t.Errorf("collection %s should be removed after full rollback", name)
+		}
+	}
+	list, err := List(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range list {
+		if !s.Applied.IsZero() {
+			t.Errorf("%s should be pending after rollback", s.File)
+		}
+	}
+
+	if _, err := runner.Up(); err != nil {
+		t.Fatalf("up after rollback: %v", err)
+	}
+	list, err = List(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range list {
+		if s.Applied.IsZero() {
+			t.Errorf("%s should be applied again after up", s.File)
+		}
+	}
+}
+
+// Возвраты и апелляции определяются типом статуса; старые завершенные *_return/*_appeal переводятся один раз
+func TestStatusesRetypedToReturnAndAppeal(t *testing.T) {
+	app := newTestApp(t)
+
+	statuses, err := app.FindCollectionByNameOrId("statuses")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for slug, statusType := range map[string]string{"completed_return": "final", "completed_appeal": "final", "in_progress_return": "return"} {
+		r := core.NewRecord(statuses)
+		r.Load(map[string]any{"slug": slug, "title": slug, "color": "warning", "type": statusType})
+		if err := app.Save(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, mig := range core.AppMigrations.Items() {
+		if mig.File == "0009_statuses_return_appeal_types.go" {
+			if err := mig.Up(app); err != nil {
+				t.Fatalf("up: %v", err)
+			}
+		}
+	}
+	for slug, want := range map[string]string{"completed_return": "final_return", "completed_appeal": "appeal", "in_progress_return": "return"} {
+		r, err := app.FindFirstRecordByData("statuses", "slug", slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.GetString("type"); got != want {
+			t.Errorf("%s: type %q, want %q", slug, got, want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Status — состояние одной миграции приложения
+type Status struct {
+	File    string
+	Applied time.Time // нулевое значение — миграция еще не применена
+	Missing bool      // отмечена в _migrations, но файла миграции больше нет
+}
+
+// List возвращает миграции приложения по порядку с отметкой о применении.
+// Применяется ли миграция, определяется по таблице _migrations (как у core.MigrationsRunner).
+func List(app core.App) ([]Status, error) {
+	var rows []struct {
+		File    string `db:"file"`
+		Applied int64  `db:"applied"`
+	}
+	if app.HasTable(core.DefaultMigrationsTable) {
+		if err := app.DB().Select("file", "applied").From(core.DefaultMigrationsTable).All(&rows); err != nil {
+			return nil, err
+		}
+	}
+	applied := make(map[string]int64, len(rows))
+	for _, r := range rows {
+		applied[r.File] = r.Applied
+	}
+
+	var result []Status
+	for _, mig := range core.AppMigrations.Items() {
+		s := Status{File: mig.File}
+		if ts, ok := applied[mig.File]; ok {
+			s.Applied = time.UnixMicro(ts)
+			delete(applied, mig.File)
+		}
+		result = append(result, s)
+	}
+	// Оставшиеся записи — системные миграции PocketBase или удаленные файлы приложения
+	system := make(map[string]bool)
+	for _, mig := range core.SystemMigrations.Items() {
+		system[mig.File] = true
+	}
+	var missing []Status
+	for file, ts := range applied {
+		if !system[file] {
+			missing = append(missing, Status{File: file, Applied: time.UnixMicro(ts), Missing: true})
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].File < missing[j].File })
+	return append(result, missing...), nil
+}
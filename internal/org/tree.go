@@ -0,0 +1,222 @@
+// Package org строит дерево отделов Bitrix24 (bitrix_departments.parent_bitrix_id)
+// и сворачивает показатели сотрудников вверх по дереву: родительский отдел включает все дочерние.
+package org
+
+import (
+	"log"
+	"sort"
+)
+
+// Department — отдел в терминах Bitrix24 (идентификаторы — bitrix_id)
+type Department struct {
+	ID       int
+	ParentID int
+	Name     string
+}
+
+// MemberStats — показатели одного сотрудника за период
+type MemberStats struct {
+	Hours          float64
+	CompletedTasks int
+}
+
+// Totals — показатели отдела вместе со всеми дочерними отделами
+type Totals struct {
+	DepartmentID      int     `json:"department_id"`
+	ParentID          int     `json:"parent_id"`
+	Name              string  `json:"name"`
+	Depth             int     `json:"depth"`
+	Headcount         int     `json:"headcount"`
+	TotalHours        float64 `json:"total_hours"`
+	CompletedTasks    int     `json:"completed_tasks"`
+	AvgHours          float64 `json:"avg_hours"`
+	AvgCompletedTasks float64 `json:"avg_completed_tasks"`
+}
+
+// Tree — дерево отделов. Отдел с неизвестным родителем считается корневым.
+// Если родители отделов замкнуты в цикл, корнем становится отдел цикла с наименьшим ID.
+type Tree struct {
+	depts    map[int]Department
+	children map[int][]int
+	roots    []int
+}
+
+func NewTree(departments []Department) *Tree {
+	t := &Tree{depts: make(map[int]Department, len(departments)), children: make(map[int][]int)}
+	for _, d := range departments {
+		t.depts[d.ID] = d
+	}
+	for _, d := range departments {
+		if _, ok := t.depts[d.ParentID]; ok && d.ParentID != d.ID {
+			t.children[d.ParentID] = append(t.children[d.ParentID], d.ID)
+		} else {
+			t.roots = append(t.roots, d.ID)
+		}
+	}
+	t.breakCycles()
+	byName := func(ids []int) {
+		sort.Slice(ids, func(i, j int) bool {
+			if t.depts[ids[i]].Name != t.depts[ids[j]].Name {
+				return t.depts[ids[i]].Name < t.depts[ids[j]].Name
+			}
+			return ids[i] < ids[j]
+		})
+	}
+	byName(t.roots)
+	for _, ids := range t.children {
+		byName(ids)
+	}
+	return t
+}
+
+// breakCycles делает корнями отделы, недостижимые от корней из-за цикла в parent_bitrix_id:
+// иначе они выпадали бы из Rollup и Nested вместе со своими ветками
+func (t *Tree) breakCycles() {
+	reached := make(map[int]bool, len(t.depts))
+	mark := func(id int) {
+		for cur := range t.Subtree(id) {
+			reached[cur] = true
+		}
+	}
+	for _, id := range t.roots {
+		mark(id)
+	}
+
+	ids := make([]int, 0, len(t.depts))
+	for id := range t.depts {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		if reached[id] {
+			continue
+		}
+		d := t.depts[id]
+		log.Printf("[Org] Department %d (%s) is in a parent cycle, treating it as a root", d.ID, d.Name)
+		siblings := t.children[d.ParentID]
+		for i, child := range siblings {
+			if child == id {
+				t.children[d.ParentID] = append(siblings[:i:i], siblings[i+1:]...)
+				break
+			}
+		}
+		d.ParentID = 0
+		t.depts[id] = d
+		t.roots = append(t.roots, id)
+		mark(id)
+	}
+}
+
+// Has сообщает, есть ли отдел в дереве
+func (t *Tree) Has(id int) bool {
+	_, ok := t.depts[id]
+	return ok
+}
+
+// Subtree возвращает отдел и всех его потомков
+func (t *Tree) Subtree(id int) map[int]bool {
+	result := make(map[int]bool)
+	if !t.Has(id) {
+		return result
+	}
+	stack := []int{id}
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if result[cur] {
+			continue
+		}
+		result[cur] = true
+		stack = append(stack, t.children[cur]...)
+	}
+	return result
+}
+
+// ancestors возвращает отдел и всю цепочку его родителей (защита от циклов в данных Bitrix)
+func (t *Tree) ancestors(id int) []int {
+	var result []int
+	seen := make(map[int]bool)
+	for t.Has(id) && !seen[id] {
+		seen[id] = true
+		result = append(result, id)
+		id = t.depts[id].ParentID
+	}
+	return result
+}
+
+// Rollup сворачивает показатели сотрудников по дереву. members — отделы каждого сотрудника.
+// Сотрудник из нескольких отделов одной ветки учитывается в общем предке один раз.
+// Результат — все отделы в порядке обхода дерева в глубину (по имени внутри уровня).
+func (t *Tree) Rollup(members map[string][]int, stats map[string]MemberStats) []Totals {
+	totals := make(map[int]*Totals, len(t.depts))
+	for id, d := range t.depts {
+		totals[id] = &Totals{DepartmentID: id, ParentID: d.ParentID, Name: d.Name}
+	}
+
+	for userId, deptIds := range members {
+		counted := make(map[int]bool)
+		for _, deptId := range deptIds {
+			for _, id := range t.ancestors(deptId) {
+				if counted[id] {
+					continue
+				}
+				counted[id] = true
+				s := stats[userId]
+				totals[id].Headcount++
+				totals[id].TotalHours += s.Hours
+				totals[id].CompletedTasks += s.CompletedTasks
+			}
+		}
+	}
+
+	result := make([]Totals, 0, len(t.depts))
+	visited := make(map[int]bool)
+	var walk func(id, depth int)
+	walk = func(id, depth int) {
+		if visited[id] {
+			return
+		}
+		visited[id] = true
+		tot := totals[id]
+		tot.Depth = depth
+		if tot.Headcount > 0 {
+			tot.AvgHours = tot.TotalHours / float64(tot.Headcount)
+			tot.AvgCompletedTasks = float64(tot.CompletedTasks) / float64(tot.Headcount)
+		}
+		result = append(result, *tot)
+		for _, child := range t.children[id] {
+			walk(child, depth+1)
+		}
+	}
+	for _, id := range t.roots {
+		walk(id, 0)
+	}
+	return result
+}
+
+// Node — отдел во вложенном представлении дерева
+type Node struct {
+	Department
+	Children []*Node
+}
+
+// Nested возвращает дерево в виде вложенных узлов: корни и дети упорядочены по имени
+func (t *Tree) Nested() []*Node {
+	visited := make(map[int]bool)
+	var build func(id int) *Node
+	build = func(id int) *Node {
+		visited[id] = true
+		node := &Node{Department: t.depts[id], Children: []*Node{}}
+		for _, child := range t.children[id] {
+			if !visited[child] {
+				node.Children = append(node.Children, build(child))
+			}
+		}
+		return node
+	}
+	roots := make([]*Node, 0, len(t.roots))
+	for _, id := range t.roots {
+		roots = append(roots, build(id))
+	}
+	return roots
+}
@@ -0,0 +1,137 @@
+package org
+
+import "testing"
+
+func testTree() *Tree {
+	return NewTree([]Department{
+		{ID: 1, Name: "Компания"},
+		{ID: 2, ParentID: 1, Name: "Разработка"},
+		{ID: 3, ParentID: 2, Name: "Бэкенд"},
+		{ID: 4, ParentID: 2, Name: "Фронтенд"},
+		{ID: 5, ParentID: 1, Name: "Аналитика"},
+	})
+}
+
+func TestSubtree(t *testing.T) {
+	tree := testTree()
+	got := tree.Subtree(2)
+	for _, id := range []int{2, 3, 4} {
+		if !got[id] {
+			t.Errorf("Subtree(2) missing %d", id)
+		}
+	}
+	if got[1] || got[5] || len(got) != 3 {
+		t.Errorf("Subtree(2) = %v, want {2,3,4}", got)
+	}
+	if len(tree.Subtree(42)) != 0 {
+		t.Error("Subtree of unknown department must be empty")
+	}
+}
+
+func TestRollup(t *testing.T) {
+	tree := testTree()
+	members := map[string][]int{
+		"alice": {3},
+		"bob":   {4},
+		"carol": {3, 4}, // в двух отделах одной ветки
+		"dave":  {5},
+	}
+	stats := map[string]MemberStats{
+		"alice": {Hours: 10, CompletedTasks: 2},
+		"bob":   {Hours: 20, CompletedTasks: 1},
+		"carol": {Hours: 30, CompletedTasks: 3},
+	}
+
+	byId := make(map[int]Totals)
+	var order []int
+	for _, tot := range tree.Rollup(members, stats) {
+		byId[tot.DepartmentID] = tot
+		order = append(order, tot.DepartmentID)
+	}
+
+	wantOrder := []int{1, 5, 2, 3, 4}
+	if len(order) != len(wantOrder) {
+		t.Fatalf("order = %v, want %v", order, wantOrder)
+	}
+	for i := range wantOrder {
+		if order[i] != wantOrder[i] {
+			t.Fatalf("order = %v, want %v", order, wantOrder)
+		}
+	}
+
+	cases := []struct {
+		id        int
+		headcount int
+		hours     float64
+		completed int
+		avgHours  float64
+		depth     int
+	}{
+		{1, 4, 60, 6, 15, 0},
+		{2, 3, 60, 6, 20, 1},
+		{3, 2, 40, 5, 20, 2},
+		{4, 2, 50, 4, 25, 2},
+		{5, 1, 0, 0, 0, 1},
+	}
+	for _, c := range cases {
+		got := byId[c.id]
+		if got.Headcount != c.headcount || got.TotalHours != c.hours || got.CompletedTasks != c.completed || got.AvgHours != c.avgHours || got.Depth != c.depth {
+			t.Errorf("department %d = %+v, want headcount=%d hours=%v completed=%d avg=%v depth=%d",
+				c.id, got, c.headcount, c.hours, c.completed, c.avgHours, c.depth)
+		}
+	}
+}
+
+func TestRollupCycle(t *testing.T) {
+	tree := NewTree([]Department{
+		{ID: 1, ParentID: 2, Name: "A"},
+		{ID: 2, ParentID: 1, Name: "B"},
+		{ID: 3, ParentID: 2, Name: "C"},
+		{ID: 4, Name: "D"},
+	})
+	if len(tree.Subtree(1)) != 3 {
+		t.Errorf("Subtree(1) = %v, want the whole cycle branch", tree.Subtree(1))
+	}
+
+	// Цикл разрывается на отделе с наименьшим ID: он становится корнем, ветка остается в отчете
+	got := tree.Rollup(map[string][]int{"u": {3}}, map[string]MemberStats{"u": {Hours: 2}})
+	want := []struct{ id, parent, depth int }{{1, 0, 0}, {2, 1, 1}, {3, 2, 2}, {4, 0, 0}}
+	if len(got) != len(want) {
+		t.Fatalf("Rollup() = %+v, want all 4 departments", got)
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.DepartmentID != w.id || g.ParentID != w.parent || g.Depth != w.depth {
+			t.Errorf("Rollup()[%d] = %+v, want id %d parent %d depth %d", i, g, w.id, w.parent, w.depth)
+		}
+		if w.id != 4 && (g.Headcount != 1 || g.TotalHours != 2) {
+			t.Errorf("department %d: headcount %d hours %v, want the member counted once", w.id, g.Headcount, g.TotalHours)
+		}
+	}
+
+	roots := tree.Nested()
+	if len(roots) != 2 || roots[0].ID != 1 || roots[1].ID != 4 {
+		t.Fatalf("Nested() roots = %+v, want [1 4]", roots)
+	}
+	if len(roots[0].Children) != 1 || roots[0].Children[0].ID != 2 || len(roots[0].Children[0].Children) != 1 {
+		t.Errorf("cycle branch must be nested under 1: %+v", roots[0].Children)
+	}
+}
+
+func TestNested(t *testing.T) {
+	roots := testTree().Nested()
+	if len(roots) != 1 || roots[0].ID != 1 {
+		t.Fatalf("Nested() roots = %+v, want [1]", roots)
+	}
+	var names []string
+	for _, child := range roots[0].Children {
+		names = append(names, child.Name)
+	}
+	if len(names) != 2 || names[0] != "Аналитика" || names[1] != "Разработка" {
+		t.Errorf("children of root = %v, want [Аналитика Разработка]", names)
+	}
+	dev := roots[0].Children[1]
+	if len(dev.Children) != 2 || dev.Children[0].ID != 3 || dev.Children[1].ID != 4 {
+		t.Errorf("children of 2 = %+v, want [3 4]", dev.Children)
+	}
+}
@@ -0,0 +1,222 @@
+// Package reconcile сверяет справочники из config.json (статусы и поля задач)
+// с коллекциями statuses и task_fields и приводит их к согласованному виду по политике.
+package reconcile
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+	"my_pocketbase_app/internal/app"
+	"my_pocketbase_app/internal/config"
+)
+
+// Policy — что делать с расхождениями между config.json и БД
+type Policy string
+
+const (
+	// PolicyFile — файл источник истины: добавить, обновить, удалить записи, которых нет в файле
+	PolicyFile Policy = app.ReconcilePolicyFile
+	// PolicyMerge — добавить и обновить из файла, записи, созданные в админке, оставить
+	PolicyMerge Policy = app.ReconcilePolicyMerge
+	// PolicyDB — БД источник истины: только добавить отсутствующие и заполнить пустые поля, остальное в отчет
+	PolicyDB Policy = app.ReconcilePolicyDB
+)
+
+// ParsePolicy разбирает значение reconcile_policy из config.json; пустое значение — PolicyMerge
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return PolicyMerge, nil
+	case PolicyFile, PolicyMerge, PolicyDB:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown reconcile policy %q (expected file, merge or db)", s)
+}
+
+// Action — что сделано с записью
+type Action string
+
+const (
+	ActionAdd    Action = "add"    // запись есть только в файле, добавлена в БД
+	ActionUpdate Action = "update" // поля записи в БД приведены к файлу
+	ActionRemove Action = "remove" // запись есть только в БД, удалена
+	ActionDiffer Action = "differ" // поля расходятся, по политике оставлено значение БД
+	ActionExtra  Action = "extra"  // запись есть только в БД, по политике оставлена
+)
+
+// Change — одна строка отчета сверки
+type Change struct {
+	Collection string
+	Key        string // slug статуса или key поля
+	Action     Action
+	Fields     []string // измененные или расходящиеся поля
+}
+
+// Report — итог сверки
+type Report struct {
+	Policy  Policy
+	Changes []Change
+}
+
+// Log пишет отчет в лог сервера
+func (r *Report) Log() {
+	if len(r.Changes) == 0 {
+		log.Printf("[INFO] Config reconcile (%s): statuses and task_fields match config.json", r.Policy)
+		return
+	}
+	for _, c := range r.Changes {
+		level := "[INFO]"
+		if c.Action == ActionDiffer || c.Action == ActionExtra {
+			level = "[WARN]"
+		}
+		line := fmt.Sprintf("%s Config reconcile (%s): %s %s %q", level, r.Policy, c.Collection, c.Action, c.Key)
+		if len(c.Fields) > 0 {
+			line += " (" + strings.Join(c.Fields, ", ") + ")"
+		}
+		log.Println(line)
+	}
+}
+
+// fieldValue — значение поля записи в том виде, в каком его возвращает Record.Get
+type fieldValue struct {
+	name  string
+	value any
+}
+
+// item — запись справочника из файла
+type item struct {
+	key    string
+	values []fieldValue
+}
+
+// Run сверяет statuses и task_fields с конфигом в одной транзакции
+func Run(app core.App, cfg config.AppConfig, policy Policy) (*Report, error) {
+	report := &Report{Policy: policy}
+	err := app.RunInTransaction(func(txApp core.App) error {
+		if err := apply(txApp, report, "statuses", "slug", statusItems(cfg.Statuses), policy); err != nil {
+			return err
+		}
+		return apply(txApp, report, "task_fields", "key", fieldItems(cfg.TaskFields), policy)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func statusItems(statuses []config.StatusConfig) []item {
+	items := make([]item, 0, len(statuses))
+	for _, s := range statuses {
+		items = append(items, item{key: strings.TrimSpace(s.Slug), values: []fieldValue{
+			{"title", s.Title},
+			{"color", s.Color},
+			{"type", s.Type},
+		}})
+	}
+	return items
+}
+
+func fieldItems(fields []config.TaskFieldConfig) []item {
+	items := make([]item, 0, len(fields))
+	for i, f := range fields {
+		items = append(items, item{key: strings.TrimSpace(f.Key), values: []fieldValue{
+			{"title", f.Title},
+			{"type", f.Type},
+			{"required", f.Required},
+			{"width", f.Width},
+			{"filterable", f.Filterable},
+			{"order", float64(i)}, // порядок колонок — порядок в файле
+		}})
+	}
+	return items
+}
+
+func apply(txApp core.App, report *Report, collection, keyField string, items []item, policy Policy) error {
+	col, err := txApp.FindCollectionByNameOrId(collection)
+	if err != nil {
+		return err
+	}
+	records, err := txApp.FindAllRecords(collection)
+	if err != nil {
+		return err
+	}
+
+	// Записи без ключа и повторы ключа считаются лишними
+	existing := make(map[string]*core.Record, len(records))
+	var extra []*core.Record
+	for _, r := range records {
+		key := strings.TrimSpace(r.GetString(keyField))
+		if _, dup := existing[key]; dup || key == "" {
+			extra = append(extra, r)
+			continue
+		}
+		existing[key] = r
+	}
+
+	seen := make(map[string]bool, len(items))
+	for _, it := range items {
+		if it.key == "" || seen[it.key] {
+			continue
+		}
+		seen[it.key] = true
+
+		record, ok := existing[it.key]
+		if !ok {
+			record = core.NewRecord(col)
+			record.Set(keyField, it.key)
+			for _, v := range it.values {
+				record.Set(v.name, v.value)
+			}
+			if err := txApp.Save(record); err != nil {
+				return fmt.Errorf("%s %q: %w", collection, it.key, err)
+			}
+			report.Changes = append(report.Changes, Change{Collection: collection, Key: it.key, Action: ActionAdd})
+			continue
+		}
+		delete(existing, it.key)
+
+		var updated, differ []string
+		for _, v := range it.values {
+			current := record.Get(v.name)
+			if current == v.value {
+				continue
+			}
+			// При политике db пустые текстовые поля все равно заполняются: это не правка из админки,
+			// а поле, которого раньше не было (например, statuses.type)
+			if s, isText := current.(string); policy == PolicyDB && !(isText && s == "") {
+				differ = append(differ, v.name)
+				continue
+			}
+			record.Set(v.name, v.value)
+			updated = append(updated, v.name)
+		}
+		if len(updated) > 0 {
+			if err := txApp.Save(record); err != nil {
+				return fmt.Errorf("%s %q: %w", collection, it.key, err)
+			}
+			report.Changes = append(report.Changes, Change{Collection: collection, Key: it.key, Action: ActionUpdate, Fields: updated})
+		}
+		if len(differ) > 0 {
+			report.Changes = append(report.Changes, Change{Collection: collection, Key: it.key, Action: ActionDiffer, Fields: differ})
+		}
+	}
+
+	for _, r := range existing {
+		extra = append(extra, r)
+	}
+	sort.Slice(extra, func(i, j int) bool { return extra[i].GetString(keyField) < extra[j].GetString(keyField) })
+	for _, r := range extra {
+		change := Change{Collection: collection, Key: r.GetString(keyField), Action: ActionExtra}
+		if policy == PolicyFile {
+			if err := txApp.Delete(r); err != nil {
+				return fmt.Errorf("%s %q: %w", collection, change.Key, err)
+			}
+			change.Action = ActionRemove
+		}
+		report.Changes = append(report.Changes, change)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package reconcile
+
+import (
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+	"my_pocketbase_app/internal/app"
+	"my_pocketbase_app/internal/config"
+	_ "my_pocketbase_app/internal/migrations"
+)
+
+func newTestApp(t *testing.T) core.App {
+	t.Helper()
+	pbApp, err := tests.NewTestAppWithConfig(core.BaseAppConfig{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create test app: %v", err)
+	}
+	t.Cleanup(pbApp.Cleanup)
+	return pbApp
+}
+
+var testConfig = config.AppConfig{
+	Statuses: []config.StatusConfig{
+		{Title: "Завершена", Slug: "completed", Color: "success", Type: "final"},
+		{Title: "Выполняется", Slug: "in_progress", Color: "info", Type: "in_progress"},
+	},
+	TaskFields: []config.TaskFieldConfig{
+		{Key: "task_number", Title: "№ Задачи", Type: "text", Required: true},
+		{Key: "time_spent", Title: "Затрачено", Type: "number"},
+	},
+}
+
+// seed имитирует базу до сверки: статус без типа с другим цветом и статус, добавленный в админке
+func seed(t *testing.T, pbApp core.App) {
+	t.Helper()
+	col, err := pbApp.FindCollectionByNameOrId("statuses")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []map[string]any{
+		{"slug": "completed", "title": "Завершена", "color": "green"},
+		{"slug": "on_hold", "title": "Отложена", "color": "gray", "type": "in_progress"},
+	} {
+		record := core.NewRecord(col)
+		record.Load(s)
+		if err := pbApp.Save(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func actions(report *Report) map[string]Action {
+	result := make(map[string]Action)
+	for _, c := range report.Changes {
+		result[c.Collection+"/"+c.Key] = c.Action
+	}
+	return result
+}
+
+func TestRunMerge(t *testing.T) {
+	pbApp := newTestApp(t)
+	seed(t, pbApp)
+
+	report, err := Run(pbApp, testConfig, PolicyMerge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := actions(report)
+	want := map[string]Action{
+		"statuses/completed":      ActionUpdate,
+		"statuses/in_progress":    ActionAdd,
+		"statuses/on_hold":        ActionExtra,
+		"task_fields/task_number": ActionAdd,
+		"task_fields/time_spent":  ActionAdd,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("changes = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: %s, want %s", k, got[k], v)
+		}
+	}
+
+	completed, err := pbApp.FindFirstRecordByData("statuses", "slug", "completed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completed.GetString("type") != "final" || completed.GetString("color") != "success" {
+		t.Errorf("completed not updated: type=%q color=%q", completed.GetString("type"), completed.GetString("color"))
+	}
+	field, err := pbApp.FindFirstRecordByData("task_fields", "key", "time_spent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if field.GetInt("order") != 1 {
+		t.Errorf("time_spent order = %d, want 1", field.GetInt("order"))
+	}
+
+	// Повторная сверка ничего не меняет, кроме отметки о лишней записи
+	again, err := Run(pbApp, testConfig, PolicyMerge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Changes) != 1 || again.Changes[0].Action != ActionExtra {
+		t.Errorf("second run changes = %+v", again.Changes)
+	}
+}
+
+func TestRunFileRemovesExtra(t *testing.T) {
+	pbApp := newTestApp(t)
+	seed(t, pbApp)
+
+	report, err := Run(pbApp, testConfig, PolicyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actions(report)["statuses/on_hold"] != ActionRemove {
+		t.Errorf("on_hold should be removed, changes = %+v", report.Changes)
+	}
+	if _, err := pbApp.FindFirstRecordByData("statuses", "slug", "on_hold"); err == nil {
+		t.Error("on_hold is still in DB")
+	}
+}
+
+func TestRunDBKeepsAdminEdits(t *testing.T) {
+	pbApp := newTestApp(t)
+	seed(t, pbApp)
+
+	report, err := Run(pbApp, testConfig, PolicyDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var completed []Change
+	for _, c := range report.Changes {
+		if c.Key == "completed" {
+			completed = append(completed, c)
+		}
+	}
+	// Пустой type заполняется, отличающийся color остается как в БД
+	if len(completed) != 2 ||
+		completed[0].Action != ActionUpdate || completed[0].Fields[0] != "type" ||
+		completed[1].Action != ActionDiffer || completed[1].Fields[0] != "color" {
+		t.Fatalf("completed changes = %+v", completed)
+	}
+	record, _ := pbApp.FindFirstRecordByData("statuses", "slug", "completed")
+	if record.GetString("color") != "green" || record.GetString("type") != "final" {
+		t.Errorf("completed: color=%q type=%q", record.GetString("color"), record.GetString("type"))
+	}
+}
+
+func TestLoadStatuses(t *testing.T) {
+	pbApp := newTestApp(t)
+	seed(t, pbApp)
+	if _, err := Run(pbApp, testConfig, PolicyMerge); err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := LoadStatuses(pbApp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"completed": "final", "завершена": "final", "in_progress": "in_progress", "отложена": "in_progress",
+	} {
+		if got := set.Types[name]; got != want {
+			t.Errorf("Types[%q] = %q, want %q", name, got, want)
+		}
+	}
+	if set.Slugs["отложена"] != "on_hold" {
+		t.Errorf("Slugs[отложена] = %q", set.Slugs["отложена"])
+	}
+}
+
+func TestStatusHooksReloadRegistry(t *testing.T) {
+	pbApp := newTestApp(t)
+	context := &app.AppContext{}
+	RegisterStatusHooks(pbApp, context)
+	if _, err := Run(pbApp, testConfig, PolicyMerge); err != nil {
+		t.Fatal(err)
+	}
+	before := context.Statuses()
+	if before.Types["completed"] != "final" {
+		t.Fatalf("registry not loaded after reconcile: %v", before.Types)
+	}
+
+	// Статус, добавленный в админке, сразу учитывается в расчетах
+	col, _ := pbApp.FindCollectionByNameOrId("statuses")
+	partial := core.NewRecord(col)
+	partial.Load(map[string]any{"slug": "completed_partial", "title": "Завершена (частично)", "color": "warning", "type": "final"})
+	if err := pbApp.Save(partial); err != nil {
+		t.Fatal(err)
+	}
+	if got := context.Statuses().Types["завершена (частично)"]; got != "final" {
+		t.Errorf("new status type = %q, want final", got)
+	}
+	if _, ok := before.Types["завершена (частично)"]; ok {
+		t.Error("previous snapshot must not change")
+	}
+
+	if err := pbApp.Delete(partial); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := context.Statuses().Types["completed_partial"]; ok {
+		t.Error("deleted status is still in registry")
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for in, want := range map[string]Policy{"": PolicyMerge, "File": PolicyFile, " db ": PolicyDB} {
+		if got, err := ParsePolicy(in); err != nil || got != want {
+			t.Errorf("ParsePolicy(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParsePolicy("mirror"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
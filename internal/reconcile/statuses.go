@@ -0,0 +1,61 @@
+package reconcile
+
+import (
+	"log"
+
+	"github.com/pocketbase/pocketbase/core"
+	"my_pocketbase_app/internal/app"
+	"my_pocketbase_app/internal/utils"
+)
+
+// LoadStatuses собирает снимок статусов из коллекции statuses,
+// чтобы расчеты KPI видели те же статусы и типы, что и админка
+func LoadStatuses(pbApp core.App) (*app.StatusSet, error) {
+	records, err := pbApp.FindAllRecords("statuses")
+	if err != nil {
+		return nil, err
+	}
+
+	set := &app.StatusSet{Types: make(map[string]string), Slugs: make(map[string]string)}
+	for _, r := range records {
+		slug, title, statusType := r.GetString("slug"), r.GetString("title"), r.GetString("type")
+		if statusType == "" {
+			log.Printf("[WARN] Status %q has no type in DB, set it in the admin panel or config.json", slug)
+		}
+		for _, name := range []string{slug, title} {
+			norm := utils.NormalizeStatus(name)
+			if norm == "" {
+				continue
+			}
+			if statusType != "" {
+				set.Types[norm] = statusType
+			}
+			set.Slugs[norm] = slug
+		}
+	}
+	return set, nil
+}
+
+// ReloadStatuses перечитывает statuses и публикует снимок в реестре контекста
+func ReloadStatuses(pbApp core.App, context *app.AppContext) error {
+	set, err := LoadStatuses(pbApp)
+	if err != nil {
+		return err
+	}
+	context.StatusRegistry.Replace(set)
+	return nil
+}
+
+// RegisterStatusHooks пересобирает реестр статусов после любого изменения коллекции statuses
+func RegisterStatusHooks(pbApp core.App, context *app.AppContext) {
+	reload := func(e *core.RecordEvent) error {
+		// Хуки *Success срабатывают после коммита, поэтому читаем через основное приложение, а не e.App
+		if err := ReloadStatuses(pbApp, context); err != nil {
+			log.Printf("[ERROR] Failed to reload statuses: %v", err)
+		}
+		return e.Next()
+	}
+	pbApp.OnRecordAfterCreateSuccess("statuses").BindFunc(reload)
+	pbApp.OnRecordAfterUpdateSuccess("statuses").BindFunc(reload)
+	pbApp.OnRecordAfterDeleteSuccess("statuses").BindFunc(reload)
+}
@@ -0,0 +1,159 @@
+// Package replay реализует правило "последнее состояние задачи побеждает":
+// отчеты (файлы tasks) проигрываются в хронологическом порядке, и для каждого
+// номера задачи строится история — статусы, накопленные часы и файлы-источники.
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"my_pocketbase_app/internal/app"
+)
+
+// File — один загруженный отчет. Файлы должны подаваться в порядке (file_date, время загрузки).
+type File struct {
+	ID       string
+	FileDate string
+	Tasks    []app.TaskEntry
+}
+
+// Transition — смена статуса задачи. Первое появление задачи — переход из пустого статуса.
+// SpentBefore — накопленные часы до отчета, в котором произошла смена.
+type Transition struct {
+	From        string  `json:"from"`
+	To          string  `json:"to"`
+	FileDate    string  `json:"file_date"`
+	FileID      string  `json:"file_id"`
+	SpentBefore float64 `json:"spent_before"`
+}
+
+// Timeline — история одной задачи по всем проигранным отчетам
+type Timeline struct {
+	TaskNumber    string        `json:"task_number"`
+	FirstSeen     string        `json:"first_seen"`
+	LastSeen      string        `json:"last_seen"`
+	Transitions   []Transition  `json:"transitions"`
+	TotalSpent    float64       `json:"total_spent"`
+	TotalEstimate float64       `json:"total_estimate"`
+	SourceFiles   []string      `json:"source_files"`
+	Latest        app.TaskEntry `json:"latest"`
+}
+
+// Status возвращает статус из последнего отчета
+func (t *Timeline) Status() string {
+	return statusOf(t.Latest)
+}
+
+// Replay накапливает истории задач
+type Replay struct {
+	timelines     map[string]*Timeline
+	order         []string
+	totalSpent    float64
+	totalEstimate float64
+}
+
+func New() *Replay {
+	return &Replay{timelines: make(map[string]*Timeline)}
+}
+
+// NormalizeTaskNumber приводит номер задачи к строке без пробелов.
+// Пустой результат означает, что строка отчета не относится к задаче.
+func NormalizeTaskNumber(v interface{}) string {
+	switch n := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		// Числа из JSON — float64, %v дал бы 1.234567e+06
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return strings.TrimSpace(fmt.Sprintf("%v", v))
+}
+
+// Add проигрывает очередной файл. Каждая строка помечается source_file_date / source_file_id.
+func (r *Replay) Add(f File) {
+	for _, t := range f.Tasks {
+		taskNum := NormalizeTaskNumber(t["task_number"])
+		if taskNum == "" {
+			continue
+		}
+		t["source_file_date"] = f.FileDate
+		t["source_file_id"] = f.ID
+
+		spent := number(t["time_spent"])
+		estimate := number(t["programmer_estimate"])
+		r.totalSpent += spent
+		r.totalEstimate += estimate
+
+		tl, exists := r.timelines[taskNum]
+		if !exists {
+			tl = &Timeline{TaskNumber: taskNum, FirstSeen: f.FileDate, Transitions: []Transition{}, SourceFiles: []string{}}
+			r.timelines[taskNum] = tl
+			r.order = append(r.order, taskNum)
+		}
+
+		status := statusOf(t)
+		prev := ""
+		if tl.Latest != nil {
+			prev = statusOf(tl.Latest)
+		}
+		if !exists || !strings.EqualFold(prev, status) {
+			tl.Transitions = append(tl.Transitions, Transition{From: prev, To: status, FileDate: f.FileDate, FileID: f.ID, SpentBefore: tl.TotalSpent})
+		}
+
+		tl.TotalSpent += spent
+		tl.TotalEstimate += estimate
+		tl.LastSeen = f.FileDate
+		if n := len(tl.SourceFiles); n == 0 || tl.SourceFiles[n-1] != f.ID {
+			tl.SourceFiles = append(tl.SourceFiles, f.ID)
+		}
+		tl.Latest = t
+	}
+}
+
+// Timelines возвращает истории в порядке первого появления задач
+func (r *Replay) Timelines() []*Timeline {
+	result := make([]*Timeline, 0, len(r.order))
+	for _, num := range r.order {
+		result = append(result, r.timelines[num])
+	}
+	return result
+}
+
+// Get возвращает историю задачи по номеру
+func (r *Replay) Get(taskNumber string) (*Timeline, bool) {
+	tl, ok := r.timelines[NormalizeTaskNumber(taskNumber)]
+	return tl, ok
+}
+
+// Totals — сумма часов и оценок по всем строкам всех файлов
+func (r *Replay) Totals() (spent, estimate float64) {
+	return r.totalSpent, r.totalEstimate
+}
+
+func statusOf(t app.TaskEntry) string {
+	if t == nil || t["status"] == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprintf("%v", t["status"]))
+}
+
+// number разбирает часы из строки отчета: json.Number, число или строка с запятой
+func number(v interface{}) float64 {
+	switch val := v.(type) {
+	case json.Number:
+		f, _ := val.Float64()
+		return f
+	case float64:
+		return val
+	case int:
+		return float64(val)
+	case int64:
+		return float64(val)
+	case string:
+		f, _ := strconv.ParseFloat(strings.Replace(strings.TrimSpace(val), ",", ".", 1), 64)
+		return f
+	}
+	return 0
+}
@@ -0,0 +1,187 @@
+package replay
+
+import (
+	"encoding/json"
+	"testing"
+
+	"my_pocketbase_app/internal/app"
+)
+
+func file(id, date string, tasks ...app.TaskEntry) File {
+	return File{ID: id, FileDate: date, Tasks: tasks}
+}
+
+func TestReplayLatestStateWins(t *testing.T) {
+	cases := []struct {
+		name          string
+		files         []File
+		taskNumber    string
+		wantStatus    string
+		wantSpent     float64
+		wantEstimate  float64
+		wantFirstSeen string
+		wantLastSeen  string
+		wantSources   []string
+		wantStatuses  []string
+	}{
+		{
+			name: "single report",
+			files: []File{
+				file("f1", "2025-12-01", app.TaskEntry{"task_number": "101", "status": "Выполняется", "time_spent": json.Number("2"), "programmer_estimate": json.Number("8")}),
+			},
+			taskNumber: "101", wantStatus: "Выполняется", wantSpent: 2, wantEstimate: 8,
+			wantFirstSeen: "2025-12-01", wantLastSeen: "2025-12-01",
+			wantSources: []string{"f1"}, wantStatuses: []string{"Выполняется"},
+		},
+		{
+			name: "same-day re-upload overrides earlier file",
+			files: []File{
+				file("f1", "2025-12-01", app.TaskEntry{"task_number": "101", "status": "Выполняется", "time_spent": 3.0}),
+				file("f2", "2025-12-01", app.TaskEntry{"task_number": "101", "status": "Завершена", "time_spent": 1.0}),
+			},
+			taskNumber: "101", wantStatus: "Завершена", wantSpent: 4,
+			wantFirstSeen: "2025-12-01", wantLastSeen: "2025-12-01",
+			wantSources: []string{"f1", "f2"}, wantStatuses: []string{"Выполняется", "Завершена"},
+		},
+		{
+			name: "whitespace and numeric task numbers are the same task",
+			files: []File{
+				file("f1", "2025-12-01", app.TaskEntry{"task_number": json.Number("101"), "status": "Выполняется", "time_spent": "1,5"}),
+				file("f2", "2025-12-02", app.TaskEntry{"task_number": " 101 ", "status": "Выполняется", "time_spent": json.Number("2.5")}),
+			},
+			taskNumber: "101", wantStatus: "Выполняется", wantSpent: 4,
+			wantFirstSeen: "2025-12-01", wantLastSeen: "2025-12-02",
+			wantSources: []string{"f1", "f2"}, wantStatuses: []string{"Выполняется"},
+		},
+		{
+			name: "status bounce is recorded as transitions",
+			files: []File{
+				file("f1", "2025-12-01", app.TaskEntry{"task_number": "7", "status": "Завершена"}),
+				file("f2", "2025-12-02", app.TaskEntry{"task_number": "7", "status": "Выполняется (возврат)"}),
+				file("f3", "2025-12-03", app.TaskEntry{"task_number": "7", "status": "завершена"}),
+			},
+			taskNumber: "7", wantStatus: "завершена",
+			wantFirstSeen: "2025-12-01", wantLastSeen: "2025-12-03",
+			wantSources: []string{"f1", "f2", "f3"}, wantStatuses: []string{"Завершена", "Выполняется (возврат)", "завершена"},
+		},
+		{
+			name: "duplicate rows in one file sum hours and keep one source",
+			files: []File{
+				file("f1", "2025-12-01",
+					app.TaskEntry{"task_number": "9", "status": "Выполняется", "time_spent": 1.0},
+					app.TaskEntry{"task_number": "9", "status": "Выполняется", "time_spent": 2.0},
+				),
+			},
+			taskNumber: "9", wantStatus: "Выполняется", wantSpent: 3,
+			wantFirstSeen: "2025-12-01", wantLastSeen: "2025-12-01",
+			wantSources: []string{"f1"}, wantStatuses: []string{"Выполняется"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := New()
+			for _, f := range c.files {
+				r.Add(f)
+			}
+			tl, ok := r.Get(c.taskNumber)
+			if !ok {
+				t.Fatalf("Task %q not found", c.taskNumber)
+			}
+			if tl.Status() != c.wantStatus {
+				t.Errorf("Status == %q, want %q", tl.Status(), c.wantStatus)
+			}
+			if tl.TotalSpent != c.wantSpent {
+				t.Errorf("TotalSpent == %v, want %v", tl.TotalSpent, c.wantSpent)
+			}
+			if tl.TotalEstimate != c.wantEstimate {
+				t.Errorf("TotalEstimate == %v, want %v", tl.TotalEstimate, c.wantEstimate)
+			}
+			if tl.FirstSeen != c.wantFirstSeen || tl.LastSeen != c.wantLastSeen {
+				t.Errorf("Seen == %s..%s, want %s..%s", tl.FirstSeen, tl.LastSeen, c.wantFirstSeen, c.wantLastSeen)
+			}
+			if len(tl.SourceFiles) != len(c.wantSources) {
+				t.Fatalf("SourceFiles == %v, want %v", tl.SourceFiles, c.wantSources)
+			}
+			for i := range c.wantSources {
+				if tl.SourceFiles[i] != c.wantSources[i] {
+					t.Errorf("SourceFiles == %v, want %v", tl.SourceFiles, c.wantSources)
+				}
+			}
+			if len(tl.Transitions) != len(c.wantStatuses) {
+				t.Fatalf("Transitions == %+v, want statuses %v", tl.Transitions, c.wantStatuses)
+			}
+			for i, want := range c.wantStatuses {
+				if tl.Transitions[i].To != want {
+					t.Errorf("Transition %d To == %q, want %q", i, tl.Transitions[i].To, want)
+				}
+			}
+			if tl.Latest["source_file_id"] != c.files[len(c.files)-1].ID {
+				t.Errorf("Latest source_file_id == %v, want %s", tl.Latest["source_file_id"], c.files[len(c.files)-1].ID)
+			}
+		})
+	}
+}
+
+func TestReplaySkipsRowsWithoutTaskNumber(t *testing.T) {
+	r := New()
+	r.Add(file("f1", "2025-12-01",
+		app.TaskEntry{"task_number": nil, "time_spent": 5.0},
+		app.TaskEntry{"task_number": "   ", "time_spent": 5.0},
+		app.TaskEntry{"task_number": "1", "time_spent": 1.0},
+	))
+	if n := len(r.Timelines()); n != 1 {
+		t.Fatalf("Expected 1 timeline, got %d", n)
+	}
+	if spent, _ := r.Totals(); spent != 1 {
+		t.Errorf("Totals spent == %v, want 1", spent)
+	}
+}
+
+func TestNormalizeTaskNumber(t *testing.T) {
+	cases := map[string]struct {
+		in   interface{}
+		want string
+	}{
+		"missing":     {nil, ""},
+		"padded":      {" 101 ", "101"},
+		"float":       {float64(1234567), "1234567"},
+		"json number": {json.Number("102"), "102"},
+	}
+	for name, c := range cases {
+		if got := NormalizeTaskNumber(c.in); got != c.want {
+			t.Errorf("%s: NormalizeTaskNumber(%#v) = %q, want %q", name, c.in, got, c.want)
+		}
+	}
+}
+
+func TestReplayTimelinesOrder(t *testing.T) {
+	r := New()
+	r.Add(file("f1", "2025-12-01", app.TaskEntry{"task_number": "b"}, app.TaskEntry{"task_number": "a"}))
+	r.Add(file("f2", "2025-12-02", app.TaskEntry{"task_number": "c"}, app.TaskEntry{"task_number": "b"}))
+	got := []string{}
+	for _, tl := range r.Timelines() {
+		got = append(got, tl.TaskNumber)
+	}
+	if len(got) != 3 || got[0] != "b" || got[1] != "a" || got[2] != "c" {
+		t.Errorf("Timelines order == %v, want [b a c]", got)
+	}
+}
+
+func TestReplayTransitionSpentBefore(t *testing.T) {
+	r := New()
+	r.Add(file("f1", "2025-12-01", app.TaskEntry{"task_number": "5", "status": "Завершена", "time_spent": 4.0}))
+	r.Add(file("f2", "2025-12-02", app.TaskEntry{"task_number": "5", "status": "Выполняется (возврат)", "time_spent": 1.0}))
+	r.Add(file("f3", "2025-12-03", app.TaskEntry{"task_number": "5", "status": "Выполняется (возврат)", "time_spent": 2.0}))
+
+	tl, _ := r.Get("5")
+	if len(tl.Transitions) != 2 {
+		t.Fatalf("Expected 2 transitions, got %+v", tl.Transitions)
+	}
+	if tl.Transitions[1].SpentBefore != 4 {
+		t.Errorf("SpentBefore == %v, want 4", tl.Transitions[1].SpentBefore)
+	}
+	if after := tl.TotalSpent - tl.Transitions[1].SpentBefore; after != 3 {
+		t.Errorf("Hours after return == %v, want 3", after)
+	}
+}
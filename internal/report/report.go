@@ -0,0 +1,295 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"my_pocketbase_app/internal/app"
+)
+
+// Коды диагностик. Фронтенд по ним подбирает локализованный текст.
+const (
+	CodeEmptyFile     = "empty_file"
+	CodeMissingColumn = "missing_column"
+	CodeRequired      = "required"
+	CodeNotNumber     = "not_number"
+	CodeInvalidDate   = "invalid_date"
+	CodeInvalidStatus = "invalid_status"
+
+	CodeDuplicateTask  = "duplicate_task"
+	CodeDateOutOfRange = "date_out_of_range"
+)
+
+// PreferredSheet — лист, который берется в первую очередь (как и на клиенте)
+const PreferredSheet = "Лист1"
+
+// systemKeys заполняются сервером (HandleUpdateTaskTime) и не читаются из Excel
+var systemKeys = map[string]bool{
+	"original_time_spent": true,
+	"is_edited":           true,
+}
+
+var dateLayouts = []string{"2006-01-02", "02.01.2006", "2006-01-02 15:04:05", "01-02-06", "1/2/06", "1/2/2006"}
+
+// Field описывает колонку отчета (запись из коллекции task_fields)
+type Field struct {
+	Key      string `json:"key"`
+	Title    string `json:"title"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+}
+
+// Diagnostic — одна ошибка валидации с привязкой к строке и колонке Excel.
+// Row — номер строки в Excel (1 — заголовок), 0 — ошибка уровня файла.
+type Diagnostic struct {
+	Row    int    `json:"row"`
+	Column string `json:"column,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Code   string `json:"code"`
+	Value  string `json:"value,omitempty"`
+	Ref    int    `json:"ref,omitempty"` // для дубликатов — строка первого вхождения
+}
+
+// ReadXLSX читает строки отчета из листа "Лист1" (или первого листа).
+// Значения берутся "сырыми", чтобы даты приходили серийными числами Excel, а не в формате локали.
+func ReadXLSX(r io.Reader) ([][]string, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("workbook has no sheets")
+	}
+	sheet := sheets[0]
+	for _, s := range sheets {
+		if s == PreferredSheet {
+			sheet = s
+			break
+		}
+	}
+	return f.GetRows(sheet, excelize.Options{RawCellValue: true})
+}
+
+// Row — строка отчета до валидации: номер строки в источнике и сырые значения по ключам task_fields
+type Row struct {
+	Number int
+	Values map[string]string
+}
+
+// Parse сопоставляет колонки по заголовкам из task_fields, валидирует каждую строку
+// и проверяет отчет целиком за день day (CheckReport).
+// Возвращает только корректные строки и полный список диагностик по файлу.
+func Parse(rows [][]string, fields []Field, statusMap map[string]string, day time.Time) ([]app.TaskEntry, []Diagnostic) {
+	extracted, diags := ExtractRows(rows, fields)
+	if len(diags) > 0 {
+		return nil, diags
+	}
+	tasks, diags := Validate(extracted, fields, statusMap)
+	return tasks, append(diags, CheckReport(extracted, day)...)
+}
+
+// ExtractRows находит колонки по заголовкам (первая строка листа) и собирает непустые строки.
+// Отсутствие обязательной колонки — ошибка уровня файла.
+func ExtractRows(rows [][]string, fields []Field) ([]Row, []Diagnostic) {
+	diags := []Diagnostic{}
+	if len(rows) == 0 {
+		return nil, append(diags, Diagnostic{Code: CodeEmptyFile})
+	}
+
+	headers := make(map[string]int)
+	for i, h := range rows[0] {
+		norm := normalize(h)
+		if _, exists := headers[norm]; !exists {
+			headers[norm] = i
+		}
+	}
+
+	columns := make(map[string]int)
+	for _, f := range fields {
+		if idx, ok := headers[normalize(f.Title)]; ok {
+			columns[f.Key] = idx
+		} else if f.Required && !systemKeys[f.Key] {
+			diags = append(diags, Diagnostic{Row: 1, Column: f.Key, Title: f.Title, Code: CodeMissingColumn})
+		}
+	}
+	if len(diags) > 0 {
+		return nil, diags
+	}
+
+	result := []Row{}
+	for i, row := range rows[1:] {
+		if isEmptyRow(row, columns) {
+			continue
+		}
+		values := make(map[string]string, len(columns))
+		for key, idx := range columns {
+			values[key] = cell(row, idx)
+		}
+		result = append(result, Row{Number: i + 2, Values: values})
+	}
+	return result, diags
+}
+
+// RowsFromEntries превращает готовый JSON-список задач в строки для валидации (нумерация с 1)
+func RowsFromEntries(entries []app.TaskEntry) []Row {
+	result := make([]Row, 0, len(entries))
+	for i, t := range entries {
+		values := make(map[string]string, len(t))
+		for key, v := range t {
+			if v == nil {
+				continue
+			}
+			values[key] = strings.TrimSpace(formatValue(v))
+		}
+		result = append(result, Row{Number: i + 1, Values: values})
+	}
+	return result
+}
+
+// formatValue печатает значение из JSON. Числа из BindBody приходят как float64,
+// и %v превратил бы номер задачи 1234567 в "1.234567e+06".
+func formatValue(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// Validate проверяет обязательные поля, числа, даты и статусы по каждой строке.
+// Строки с ошибками в результат не попадают.
+func Validate(rows []Row, fields []Field, statusMap map[string]string) ([]app.TaskEntry, []Diagnostic) {
+	diags := []Diagnostic{}
+	tasks := []app.TaskEntry{}
+	for _, row := range rows {
+		task := app.TaskEntry{}
+		rowOk := true
+		for _, f := range fields {
+			if systemKeys[f.Key] {
+				continue
+			}
+			raw := row.Values[f.Key]
+
+			fail := func(code string) {
+				diags = append(diags, Diagnostic{Row: row.Number, Column: f.Key, Title: f.Title, Code: code, Value: raw})
+				rowOk = false
+			}
+
+			if f.Required && raw == "" {
+				fail(CodeRequired)
+				continue
+			}
+
+			switch {
+			case f.Type == "number":
+				num, ok := ParseNumber(raw)
+				if !ok {
+					fail(CodeNotNumber)
+					continue
+				}
+				task[f.Key] = num
+			case f.Type == "date":
+				if raw == "" {
+					task[f.Key] = ""
+					continue
+				}
+				date, ok := ParseDate(raw)
+				if !ok {
+					fail(CodeInvalidDate)
+					continue
+				}
+				task[f.Key] = date.Format("2006-01-02")
+			case f.Key == "status":
+				if raw != "" {
+					if _, known := statusMap[normalize(raw)]; !known {
+						fail(CodeInvalidStatus)
+						continue
+					}
+				}
+				task[f.Key] = raw
+			default:
+				task[f.Key] = raw
+			}
+		}
+		if rowOk {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, diags
+}
+
+// CheckReport ищет проблемы уровня отчета: повторяющиеся номера задач
+// и даты строк, не совпадающие с днем отчета (file_date).
+func CheckReport(rows []Row, day time.Time) []Diagnostic {
+	diags := []Diagnostic{}
+	firstSeen := make(map[string]int)
+	for _, row := range rows {
+		if num := row.Values["task_number"]; num != "" {
+			if first, exists := firstSeen[num]; exists {
+				diags = append(diags, Diagnostic{Row: row.Number, Column: "task_number", Code: CodeDuplicateTask, Value: num, Ref: first})
+			} else {
+				firstSeen[num] = row.Number
+			}
+		}
+
+		if raw := row.Values["date"]; raw != "" {
+			if date, ok := ParseDate(raw); ok && date.Format("2006-01-02") != day.Format("2006-01-02") {
+				// Ячейка даты может прийти серийным числом Excel — в диагностику идет сама дата
+				diags = append(diags, Diagnostic{Row: row.Number, Column: "date", Code: CodeDateOutOfRange, Value: date.Format("02.01.2006")})
+			}
+		}
+	}
+	return diags
+}
+
+// ParseNumber разбирает число из ячейки, допуская запятую как десятичный разделитель.
+// Пустое значение считается нулем.
+func ParseNumber(raw string) (float64, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, true
+	}
+	f, err := strconv.ParseFloat(strings.Replace(raw, ",", ".", 1), 64)
+	return f, err == nil
+}
+
+// ParseDate понимает серийные даты Excel и типовые текстовые форматы
+func ParseDate(raw string) (time.Time, bool) {
+	raw = strings.TrimSpace(raw)
+	if serial, err := strconv.ParseFloat(raw, 64); err == nil {
+		t, err := excelize.ExcelDateToTime(serial, false)
+		return t, err == nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func normalize(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+func cell(row []string, idx int) string {
+	if idx < 0 || idx >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[idx])
+}
+
+func isEmptyRow(row []string, columns map[string]int) bool {
+	for _, idx := range columns {
+		if cell(row, idx) != "" {
+			return false
+		}
+	}
+	return true
+}
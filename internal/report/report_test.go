@@ -0,0 +1,130 @@
+package report
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"my_pocketbase_app/internal/app"
+)
+
+var testFields = []Field{
+	{Key: "task_number", Title: "№ Задачи", Type: "text", Required: true},
+	{Key: "time_spent", Title: "Затрачено", Type: "number", Required: true},
+	{Key: "status", Title: "Статус", Type: "select", Required: true},
+	{Key: "date", Title: "Дата", Type: "date", Required: true},
+	{Key: "is_edited", Title: "Ред.", Type: "boolean", Required: false},
+}
+
+var testStatuses = map[string]string{"завершена": "final", "completed": "final"}
+
+var testDay = time.Date(2025, 12, 25, 0, 0, 0, 0, time.UTC)
+
+func TestParseValidRows(t *testing.T) {
+	rows := [][]string{
+		{"№ Задачи", " затрачено ", "Статус", "Дата"},
+		{"101", "2,5", "Завершена", "46016"},
+		{"", "", "", ""},
+		{"102", "1", "completed", "25.12.2025"},
+	}
+	tasks, diags := Parse(rows, testFields, testStatuses, testDay)
+	if len(diags) != 0 {
+		t.Fatalf("Expected no diagnostics, got %+v", diags)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("Expected 2 tasks, got %d", len(tasks))
+	}
+	if tasks[0]["time_spent"] != 2.5 {
+		t.Errorf("Expected 2.5 hours, got %v", tasks[0]["time_spent"])
+	}
+	if tasks[0]["date"] != "2025-12-25" || tasks[1]["date"] != "2025-12-25" {
+		t.Errorf("Expected both dates to be 2025-12-25, got %v and %v", tasks[0]["date"], tasks[1]["date"])
+	}
+	if _, exists := tasks[0]["is_edited"]; exists {
+		t.Error("System field is_edited must not be read from Excel")
+	}
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	rows := [][]string{
+		{"№ Задачи", "Затрачено", "Статус", "Дата"},
+		{"", "abc", "Непонятно", "2025-12-25"},
+		{"103", "1", "Завершена", "2025-12-25"},
+	}
+	tasks, diags := Parse(rows, testFields, testStatuses, testDay)
+	if len(tasks) != 1 {
+		t.Fatalf("Expected 1 valid task, got %d", len(tasks))
+	}
+
+	codes := map[string]bool{}
+	for _, d := range diags {
+		if d.Row != 2 {
+			t.Errorf("Expected diagnostic on row 2, got %d", d.Row)
+		}
+		codes[d.Code] = true
+	}
+	for _, code := range []string{CodeRequired, CodeNotNumber, CodeInvalidStatus} {
+		if !codes[code] {
+			t.Errorf("Expected diagnostic %q, got %+v", code, diags)
+		}
+	}
+}
+
+func TestParseMissingColumn(t *testing.T) {
+	rows := [][]string{{"№ Задачи", "Статус", "Дата"}}
+	_, diags := Parse(rows, testFields, testStatuses, testDay)
+	if len(diags) != 1 || diags[0].Code != CodeMissingColumn || diags[0].Column != "time_spent" {
+		t.Errorf("Expected missing time_spent column, got %+v", diags)
+	}
+}
+
+func TestParseChecksReport(t *testing.T) {
+	rows := [][]string{
+		{"№ Задачи", "Затрачено", "Статус", "Дата"},
+		{"101", "1", "Завершена", "25.12.2025"},
+		{"101", "2", "Завершена", "24.12.2025"},
+	}
+	_, diags := Parse(rows, testFields, testStatuses, testDay)
+	codes := map[string]bool{}
+	for _, d := range diags {
+		codes[d.Code] = true
+	}
+	if len(diags) != 2 || !codes[CodeDuplicateTask] || !codes[CodeDateOutOfRange] {
+		t.Errorf("Expected duplicate_task and date_out_of_range, got %+v", diags)
+	}
+}
+
+func TestCheckReport(t *testing.T) {
+	var entries []app.TaskEntry
+	body := `[
+		{"task_number": " 101 ", "date": "2025-12-25"},
+		{"task_number": 102, "date": "2025-12-24"},
+		{"task_number": "101", "date": "2025-12-25"}
+	]`
+	if err := json.Unmarshal([]byte(body), &entries); err != nil {
+		t.Fatal(err)
+	}
+	rows := RowsFromEntries(entries)
+	day, _ := time.Parse("2006-01-02", "2025-12-25")
+	diags := CheckReport(rows, day)
+	if len(diags) != 2 {
+		t.Fatalf("Expected 2 diagnostics, got %+v", diags)
+	}
+	if diags[0].Code != CodeDateOutOfRange || diags[0].Row != 2 || diags[0].Value != "24.12.2025" {
+		t.Errorf("Expected date_out_of_range on row 2 with the date, got %+v", diags[0])
+	}
+	if diags[1].Code != CodeDuplicateTask || diags[1].Row != 3 || diags[1].Ref != 1 {
+		t.Errorf("Expected duplicate_task on row 3 referencing row 1, got %+v", diags[1])
+	}
+}
+
+func TestRowsFromEntriesFormatsFloats(t *testing.T) {
+	// Так числа приходят из BindBody: float64, а не json.Number
+	rows := RowsFromEntries([]app.TaskEntry{{"task_number": float64(1234567), "time_spent": 2.5}})
+	if got := rows[0].Values["task_number"]; got != "1234567" {
+		t.Errorf("Expected task number 1234567, got %q", got)
+	}
+	if got := rows[0].Values["time_spent"]; got != "2.5" {
+		t.Errorf("Expected 2.5, got %q", got)
+	}
+}
@@ -0,0 +1,107 @@
+// Package secrets хранит учетные данные в коллекции secrets в зашифрованном виде (AES-256-GCM).
+// Ключ шифрования берется только из окружения (KPI_SECRET_KEY) и в БД не попадает.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+)
+
+// EnvKey — переменная окружения с ключом шифрования: ровно 32 символа
+const EnvKey = "KPI_SECRET_KEY"
+
+// Имена секретов
+const (
+	BitrixWebhook          = "bitrix_webhook"           // входящий вебхук Bitrix24
+	BitrixApplicationToken = "bitrix_application_token" // токен исходящего вебхука (события задач)
+)
+
+const collection = "secrets"
+
+var (
+	// ErrNoKey — ключ шифрования не задан или имеет неверную длину
+	ErrNoKey = fmt.Errorf("%s must be set to a 32-character encryption key", EnvKey)
+	// ErrNotFound — секрет еще не сохранен
+	ErrNotFound = errors.New("secret not found")
+)
+
+func encryptionKey() (string, error) {
+	key := os.Getenv(EnvKey)
+	if len(key) != 32 {
+		return "", ErrNoKey
+	}
+	return key, nil
+}
+
+// Get расшифровывает секрет. Только для серверного кода: значение не должно уходить в ответы API.
+func Get(app core.App, name string) (string, error) {
+	key, err := encryptionKey()
+	if err != nil {
+		return "", err
+	}
+	record, err := app.FindFirstRecordByData(collection, "key", name)
+	if err != nil {
+		return "", ErrNotFound
+	}
+	plain, err := security.Decrypt(record.GetString("value"), key)
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret %s (wrong %s?): %w", name, EnvKey, err)
+	}
+	return string(plain), nil
+}
+
+// Set шифрует и сохраняет секрет, заменяя прежнее значение
+func Set(app core.App, name, value string) error {
+	key, err := encryptionKey()
+	if err != nil {
+		return err
+	}
+	cipherText, err := security.Encrypt([]byte(value), key)
+	if err != nil {
+		return err
+	}
+
+	record, err := app.FindFirstRecordByData(collection, "key", name)
+	if err != nil {
+		col, err := app.FindCollectionByNameOrId(collection)
+		if err != nil {
+			return err
+		}
+		record = core.NewRecord(col)
+		record.Set("key", name)
+	}
+	record.Set("value", cipherText)
+	return app.Save(record)
+}
+
+// Seed сохраняет начальное значение секрета, только если его еще нет (значение из конфига не должно
+// перетирать секрет, замененный через API). Возвращает true, если значение записано,
+// и текущее значение, если секрет уже был.
+func Seed(app core.App, name, value string) (stored bool, current string, err error) {
+	current, err = Get(app, name)
+	if errors.Is(err, ErrNotFound) {
+		return true, "", Set(app, name, value)
+	}
+	return false, current, err
+}
+
+// MoveFromSettings переносит значение, которое раньше лежало открытым текстом в settings,
+// в зашифрованный секрет и удаляет запись settings. Возвращает true, если перенос был.
+func MoveFromSettings(app core.App, name string) (bool, error) {
+	record, err := app.FindFirstRecordByData("settings", "key", name)
+	if err != nil {
+		return false, nil
+	}
+	return true, app.RunInTransaction(func(txApp core.App) error {
+		if value := record.GetString("value"); value != "" {
+			if err := Set(txApp, name, value); err != nil {
+				return err
+			}
+		}
+		return txApp.Delete(record)
+	})
+}
@@ -0,0 +1,147 @@
+package secrets
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+	_ "my_pocketbase_app/internal/migrations"
+)
+
+const testKey = "0123456789abcdef0123456789abcdef"
+
+func newTestApp(t *testing.T) core.App {
+	t.Helper()
+	app, err := tests.NewTestAppWithConfig(core.BaseAppConfig{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create test app: %v", err)
+	}
+	t.Cleanup(app.Cleanup)
+	return app
+}
+
+func TestSetGetEncrypted(t *testing.T) {
+	app := newTestApp(t)
+	t.Setenv(EnvKey, testKey)
+	webhook := "https://corp.bitrix24.ru/rest/1/secret-token"
+
+	if err := Set(app, BitrixWebhook, webhook); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Get(app, BitrixWebhook)
+	if err != nil || got != webhook {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+
+	record, err := app.FindFirstRecordByData("secrets", "key", BitrixWebhook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(record.GetString("value"), "secret-token") {
+		t.Error("secret is stored in plain text")
+	}
+
+	// Повторный Set заменяет значение, а не создает вторую запись
+	if err := Set(app, BitrixWebhook, webhook+"-2"); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := app.CountRecords("secrets"); n != 1 {
+		t.Errorf("secrets count = %d, want 1", n)
+	}
+}
+
+func TestGetRequiresKey(t *testing.T) {
+	app := newTestApp(t)
+	t.Setenv(EnvKey, testKey)
+	if err := Set(app, BitrixWebhook, "https://corp.bitrix24.ru/rest/1/x"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(EnvKey, "")
+	if _, err := Get(app, BitrixWebhook); !errors.Is(err, ErrNoKey) {
+		t.Errorf("without key: %v, want ErrNoKey", err)
+	}
+	t.Setenv(EnvKey, "fedcba9876543210fedcba9876543210")
+	if _, err := Get(app, BitrixWebhook); err == nil {
+		t.Error("decrypting with another key should fail")
+	}
+	t.Setenv(EnvKey, testKey)
+	if _, err := Get(app, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing secret: %v, want ErrNotFound", err)
+	}
+}
+
+func TestSeedKeepsRotatedSecret(t *testing.T) {
+	app := newTestApp(t)
+	t.Setenv(EnvKey, testKey)
+	fromConfig := "https://corp.bitrix24.ru/rest/1/config"
+
+	stored, _, err := Seed(app, BitrixWebhook, fromConfig)
+	if err != nil || !stored {
+		t.Fatalf("first Seed = %v, %v", stored, err)
+	}
+
+	// Вебхук заменили через API — значение из конфига при следующем старте его не перетирает
+	if err := Set(app, BitrixWebhook, "https://corp.bitrix24.ru/rest/1/rotated"); err != nil {
+		t.Fatal(err)
+	}
+	stored, current, err := Seed(app, BitrixWebhook, fromConfig)
+	if err != nil || stored || current != "https://corp.bitrix24.ru/rest/1/rotated" {
+		t.Errorf("second Seed = %v, %q, %v", stored, current, err)
+	}
+	if got, _ := Get(app, BitrixWebhook); got != "https://corp.bitrix24.ru/rest/1/rotated" {
+		t.Errorf("rotated webhook replaced by config: %q", got)
+	}
+
+	t.Setenv(EnvKey, "")
+	if _, _, err := Seed(app, BitrixWebhook, fromConfig); !errors.Is(err, ErrNoKey) {
+		t.Errorf("without key: %v, want ErrNoKey", err)
+	}
+}
+
+func TestMoveFromSettings(t *testing.T) {
+	app := newTestApp(t)
+	t.Setenv(EnvKey, testKey)
+
+	settings, err := app.FindCollectionByNameOrId("settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := core.NewRecord(settings)
+	record.Set("key", BitrixWebhook)
+	record.Set("value", "https://corp.bitrix24.ru/rest/1/plain")
+	if err := app.Save(record); err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := MoveFromSettings(app, BitrixWebhook)
+	if err != nil || !moved {
+		t.Fatalf("MoveFromSettings = %v, %v", moved, err)
+	}
+	if _, err := app.FindFirstRecordByData("settings", "key", BitrixWebhook); err == nil {
+		t.Error("plain text webhook is still in settings")
+	}
+	if got, _ := Get(app, BitrixWebhook); got != "https://corp.bitrix24.ru/rest/1/plain" {
+		t.Errorf("moved secret = %q", got)
+	}
+
+	if moved, err := MoveFromSettings(app, BitrixWebhook); err != nil || moved {
+		t.Errorf("second move = %v, %v", moved, err)
+	}
+}
+
+func TestCollectionIsNotPublic(t *testing.T) {
+	app := newTestApp(t)
+	col, err := app.FindCollectionByNameOrId("secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if col.ListRule != nil || col.ViewRule != nil || col.CreateRule != nil || col.UpdateRule != nil || col.DeleteRule != nil {
+		t.Error("secrets must be available to superusers only")
+	}
+	if !col.Fields.GetByName("value").GetHidden() {
+		t.Error("secrets.value must be hidden from API responses")
+	}
+}
@@ -4,23 +4,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
-	"sync"
 	"time"
 	"strconv"
 
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
 	"my_pocketbase_app/internal/app"
+	"my_pocketbase_app/internal/org"
+	"my_pocketbase_app/internal/replay"
 )
 
-var taskSlicePool = sync.Pool{
-	New: func() interface{} {
-		s := make([]app.TaskEntry, 0, 64)
-		return &s
-	},
-}
-
 func IsValidYear(year string) bool {
 	_, err := time.Parse("2006", year)
 	return err == nil
@@ -38,70 +34,311 @@ func IsValidDateTime(dt string) bool {
 	return false
 }
 
-func FetchTasksByDateRange(pbApp *pocketbase.PocketBase, start, end, targetUser string, limit, offset int) ([]*core.Record, error) {
-	filter := "file_date >= {:start} && file_date <= {:end}"
-	params := map[string]interface{}{"start": start, "end": end}
+// EachTaskByDateRange потоково обходит файлы tasks за период в хронологическом порядке
+// (file_date, затем время загрузки). Записи читаются из курсора по одной, без ограничения количества.
+// Ошибка из fn прерывает обход и возвращается вызывающему.
+func EachTaskByDateRange(pbApp core.App, start, end, targetUser string, fn func(r *core.Record) error) error {
+	where := []dbx.Expression{dbx.NewExp(app.FieldFileDate+" >= {:start} AND "+app.FieldFileDate+" <= {:end}", dbx.Params{"start": start, "end": end})}
 	if targetUser != "" {
-		filter += " && user = {:user}"
-		params["user"] = targetUser
-	}
-	if limit <= 0 || limit > app.MaxFetchLimit { limit = app.MaxFetchLimit }
-	return pbApp.FindRecordsByFilter(app.CollectionTasks, filter, "+file_date", limit, offset, params)
-}
-
-func SortRecordsChronologically(records []*core.Record) {
-	for i := 0; i < len(records); i++ {
-		for j := i + 1; j < len(records); j++ {
-			dateI := records[i].GetString("file_date")
-			dateJ := records[j].GetString("file_date")
-			createdI := records[i].GetDateTime("created").String()
-			createdJ := records[j].GetDateTime("created").String()
-			if dateI > dateJ || (dateI == dateJ && createdI > createdJ) {
-				records[i], records[j] = records[j], records[i]
+		where = append(where, dbx.HashExp{app.FieldUser: targetUser})
+	}
+	return eachTask(pbApp, dbx.And(where...), fn)
+}
+
+// EachTaskContaining потоково обходит (в том же порядке) все файлы, где встречается задача с данным номером.
+// Файлы находятся через индекс task_entries.task_number; visibility — дополнительное условие или nil.
+func EachTaskContaining(pbApp core.App, taskNumber string, visibility dbx.Expression, fn func(r *core.Record) error) error {
+	where := []dbx.Expression{dbx.NewExp(app.CollectionTasks+".id IN (SELECT task FROM "+app.CollectionTaskEntries+" WHERE task_number = {:num})", dbx.Params{"num": taskNumber})}
+	if visibility != nil {
+		where = append(where, visibility)
+	}
+	return eachTask(pbApp, dbx.And(where...), fn)
+}
+
+func eachTask(pbApp core.App, where dbx.Expression, fn func(r *core.Record) error) error {
+	collection, err := pbApp.FindCachedCollectionByNameOrId(app.CollectionTasks)
+	if err != nil { return err }
+
+	rows, err := pbApp.RecordQuery(collection).
+		AndWhere(where).
+		OrderBy(app.FieldFileDate+" ASC", "created ASC", "rowid ASC").
+		Rows()
+	if err != nil { return err }
+	defer rows.Close()
+
+	for rows.Next() {
+		row := dbx.NullStringMap{}
+		if err := rows.ScanMap(row); err != nil { return err }
+		if err := fn(recordFromRow(collection, row)); err != nil { return err }
+	}
+	return rows.Err()
+}
+
+// recordFromRow собирает запись из строки курсора (значения нормализуются полями коллекции)
+func recordFromRow(collection *core.Collection, row dbx.NullStringMap) *core.Record {
+	record := core.NewRecord(collection)
+	for _, field := range collection.Fields {
+		if v, ok := row[field.GetName()]; ok && v.Valid {
+			record.Set(field.GetName(), v.String)
+		}
+	}
+	record.MarkAsNotNew()
+	return record
+}
+
+// ReplayTasksByDateRange проигрывает все файлы за период через replay (последнее состояние задачи побеждает).
+// Файлы с некорректным JSON пропускаются с записью в лог.
+func ReplayTasksByDateRange(pbApp core.App, start, end, targetUser string) (*replay.Replay, error) {
+	r := replay.New()
+	err := EachTaskByDateRange(pbApp, start, end, targetUser, func(rec *core.Record) error {
+		taskList, err := ParseTaskData(rec.GetString(app.FieldData))
+		if err != nil {
+			log.Printf("[Replay] Skipping file %s with invalid data: %v", rec.Id, err)
+			return nil
+		}
+		r.Add(replay.File{ID: rec.Id, FileDate: rec.GetString(app.FieldFileDate), Tasks: taskList})
+		return nil
+	})
+	if err != nil { return nil, err }
+	return r, nil
+}
+
+// ReplayTasksByUser — то же, что ReplayTasksByDateRange, но с отдельной историей для каждого пользователя
+func ReplayTasksByUser(pbApp core.App, start, end, targetUser string) (map[string]*replay.Replay, error) {
+	result := make(map[string]*replay.Replay)
+	err := EachTaskByDateRange(pbApp, start, end, targetUser, func(rec *core.Record) error {
+		taskList, err := ParseTaskData(rec.GetString(app.FieldData))
+		if err != nil {
+			log.Printf("[Replay] Skipping file %s with invalid data: %v", rec.Id, err)
+			return nil
+		}
+		userId := rec.GetString(app.FieldUser)
+		if result[userId] == nil { result[userId] = replay.New() }
+		result[userId].Add(replay.File{ID: rec.Id, FileDate: rec.GetString(app.FieldFileDate), Tasks: taskList})
+		return nil
+	})
+	if err != nil { return nil, err }
+	return result, nil
+}
+
+// LoadUserNames возвращает карту id -> имя для всех пользователей системы
+func LoadUserNames(pbApp core.App) (map[string]string, error) {
+	users, err := pbApp.FindAllRecords("users")
+	if err != nil { return nil, err }
+	names := make(map[string]string, len(users))
+	for _, u := range users {
+		names[u.Id] = u.GetString("name")
+	}
+	return names, nil
+}
+
+// LoadDepartmentTree строит дерево отделов из bitrix_departments
+func LoadDepartmentTree(pbApp core.App) (*org.Tree, error) {
+	records, err := pbApp.FindAllRecords("bitrix_departments")
+	if err != nil { return nil, err }
+	departments := make([]org.Department, 0, len(records))
+	for _, r := range records {
+		departments = append(departments, org.Department{ID: r.GetInt("bitrix_id"), ParentID: r.GetInt("parent_bitrix_id"), Name: r.GetString("name")})
+	}
+	return org.NewTree(departments), nil
+}
+
+// LoadUserDepartments возвращает отделы (bitrix_id) каждого пользователя системы
+// по цепочке users.bitrix_user -> bitrix_users.departments. Непривязанные пользователи не попадают в карту.
+func LoadUserDepartments(pbApp core.App) (map[string][]int, error) {
+	depts, err := pbApp.FindAllRecords("bitrix_departments")
+	if err != nil { return nil, err }
+	deptIds := make(map[string]int, len(depts))
+	for _, d := range depts {
+		deptIds[d.Id] = d.GetInt("bitrix_id")
+	}
+
+	bxUsers, err := pbApp.FindAllRecords("bitrix_users")
+	if err != nil { return nil, err }
+	bxUserDepts := make(map[string][]int, len(bxUsers))
+	for _, u := range bxUsers {
+		for _, id := range u.GetStringSlice("departments") {
+			if bxId, ok := deptIds[id]; ok {
+				bxUserDepts[u.Id] = append(bxUserDepts[u.Id], bxId)
 			}
 		}
 	}
+
+	users, err := pbApp.FindAllRecords("users")
+	if err != nil { return nil, err }
+	result := make(map[string][]int)
+	for _, u := range users {
+		if ids := bxUserDepts[u.GetString("bitrix_user")]; len(ids) > 0 {
+			result[u.Id] = ids
+		}
+	}
+	return result, nil
 }
 
-func StreamRanking(pbApp *pocketbase.PocketBase, start, end string, statusMap map[string]string) (interface{}, error) {
-	query := pbApp.DB().NewQuery("SELECT " + app.FieldUser + ", " + app.FieldData + " FROM " + app.CollectionTasks + " WHERE " + app.FieldFileDate + " >= {:start} AND " + app.FieldFileDate + " <= {:end} ORDER BY " + app.FieldFileDate + " ASC")
-	query.Bind(map[string]interface{}{"start": start, "end": end})
-	rows, err := query.Rows()
+// PeriodFromQuery разбирает month=YYYY-MM или year=YYYY в границы периода для фильтра по file_date
+func PeriodFromQuery(month, year string) (start, end string, ok bool) {
+	if month != "" && IsValidMonth(month) {
+		return month + "-01 00:00:00", month + "-31 23:59:59", true
+	}
+	if year != "" && IsValidYear(year) {
+		return year + "-01-01 00:00:00", year + "-12-31 23:59:59", true
+	}
+	return "", "", false
+}
+
+// PreviousPeriod возвращает предыдущий период для month=YYYY-MM или year=YYYY (формат сохраняется)
+func PreviousPeriod(month, year string) (prevMonth, prevYear string) {
+	if month != "" && IsValidMonth(month) {
+		t, _ := time.Parse("2006-01", month)
+		return t.AddDate(0, -1, 0).Format("2006-01"), ""
+	}
+	if year != "" && IsValidYear(year) {
+		t, _ := time.Parse("2006", year)
+		return "", t.AddDate(-1, 0, 0).Format("2006")
+	}
+	return "", ""
+}
+
+// DayStats — показатели за один день (по file_date отчетов)
+type DayStats struct {
+	Date           string         `json:"date"`
+	Hours          float64        `json:"hours"`
+	Tasks          int            `json:"tasks"`
+	CompletedTasks int            `json:"completed_tasks"`
+	Statuses       map[string]int `json:"statuses"`
+}
+
+// PeriodStats — показатели за период с разбивкой по дням (дни без отчетов не включаются)
+type PeriodStats struct {
+	Start          string     `json:"start"`
+	End            string     `json:"end"`
+	TotalHours     float64    `json:"total_hours"`
+	CompletedTasks int        `json:"completed_tasks"`
+	Days           []DayStats `json:"days"`
+}
+
+// LoadPeriodStats считает дневную статистику агрегатами по task_entries с той же семантикой, что StreamRanking:
+// часы — сумма строк, завершенная задача — последний статус задачи пользователя за период (засчитывается в день этого отчета).
+// Tasks — число разных задач пользователя в отчетах дня (строки без номера не считаются), Statuses — число строк по каждому статусу.
+// users ограничивает выборку; nil — все пользователи.
+func LoadPeriodStats(pbApp core.App, start, end string, users map[string]bool, statusMap map[string]string) (*PeriodStats, error) {
+	params := map[string]interface{}{"start": start, "end": end}
+	usersFilter := userInFilter(app.FieldUser, users, params)
+	days := make(map[string]*DayStats)
+	day := func(date string) *DayStats {
+		if days[date] == nil { days[date] = &DayStats{Date: date, Statuses: make(map[string]int)} }
+		return days[date]
+	}
+
+	hoursRows, err := pbApp.DB().NewQuery("SELECT " + app.FieldUser + ", substr(" + app.FieldFileDate + ", 1, 10) AS day, COALESCE(SUM(time_spent), 0), COUNT(DISTINCT NULLIF(task_number, '')) FROM " + app.CollectionTaskEntries + " WHERE " + app.FieldFileDate + " >= {:start} AND " + app.FieldFileDate + " <= {:end}" + usersFilter + " GROUP BY " + app.FieldUser + ", day").Bind(params).Rows()
 	if err != nil { return nil, err }
-	defer rows.Close()
+	defer hoursRows.Close()
+	for hoursRows.Next() {
+		var userId, date string
+		var hours float64
+		var tasks int
+		if err := hoursRows.Scan(&userId, &date, &hours, &tasks); err != nil { return nil, err }
+		d := day(date)
+		d.Hours += hours
+		d.Tasks += tasks
+	}
+	if err := hoursRows.Err(); err != nil { return nil, err }
+
+	statusRows, err := pbApp.DB().NewQuery("SELECT " + app.FieldUser + ", substr(" + app.FieldFileDate + ", 1, 10) AS day, status, COUNT(*) FROM " + app.CollectionTaskEntries + " WHERE " + app.FieldFileDate + " >= {:start} AND " + app.FieldFileDate + " <= {:end}" + usersFilter + " GROUP BY " + app.FieldUser + ", day, status").Bind(params).Rows()
+	if err != nil { return nil, err }
+	defer statusRows.Close()
+	for statusRows.Next() {
+		var userId, date, status string
+		var count int
+		if err := statusRows.Scan(&userId, &date, &status, &count); err != nil { return nil, err }
+		day(date).Statuses[status] += count
+	}
+	if err := statusRows.Err(); err != nil { return nil, err }
+
+	// Последний статус по каждой паре (user, task_number) в пределах периода — как в StreamRanking
+	latestRows, err := pbApp.DB().NewQuery("SELECT user, day, status, COUNT(*) FROM (SELECT te.user AS user, substr(te.file_date, 1, 10) AS day, te.status AS status, ROW_NUMBER() OVER (PARTITION BY te.user, te.task_number ORDER BY te.file_date DESC, t.rowid DESC, te.line DESC) AS rn FROM " + app.CollectionTaskEntries + " te JOIN " + app.CollectionTasks + " t ON t.id = te.task WHERE te.file_date >= {:start} AND te.file_date <= {:end} AND te.task_number != ''" + userInFilter("te.user", users, params) + ") WHERE rn = 1 GROUP BY user, day, status").Bind(params).Rows()
+	if err != nil { return nil, err }
+	defer latestRows.Close()
+	for latestRows.Next() {
+		var userId, date, status string
+		var count int
+		if err := latestRows.Scan(&userId, &date, &status, &count); err != nil { return nil, err }
+		if IsStatusCompleted(status, statusMap) { day(date).CompletedTasks += count }
+	}
+	if err := latestRows.Err(); err != nil { return nil, err }
 
+	result := &PeriodStats{Start: start, End: end, Days: make([]DayStats, 0, len(days))}
+	for _, d := range days {
+		result.TotalHours += d.Hours
+		result.CompletedTasks += d.CompletedTasks
+		result.Days = append(result.Days, *d)
+	}
+	sort.Slice(result.Days, func(i, j int) bool { return result.Days[i].Date < result.Days[j].Date })
+	return result, nil
+}
+
+// userInFilter возвращает условие " AND column IN (...)" для набора пользователей и дописывает параметры в params.
+// nil — без фильтра, пустой набор — ни одной строки.
+func userInFilter(column string, users map[string]bool, params map[string]interface{}) string {
+	if users == nil { return "" }
+	if len(users) == 0 { return " AND 0" }
+	ids := make([]string, 0, len(users))
+	for id := range users { ids = append(ids, id) }
+	sort.Strings(ids)
+	placeholders := make([]string, len(ids))
+	for i, id := range ids {
+		name := "u" + strconv.Itoa(i)
+		params[name] = id
+		placeholders[i] = "{:" + name + "}"
+	}
+	return " AND " + column + " IN (" + strings.Join(placeholders, ", ") + ")"
+}
+
+// RankingItem — строка рейтинга. Необязательные колонки заполняются хендлерами по запросу.
+type RankingItem struct {
+	UserId         string   `json:"user_id"`
+	UserName       string   `json:"user_name"`
+	UserEmail      string   `json:"user_email"`
+	TotalHours     float64  `json:"total_hours"`
+	CompletedTasks int      `json:"completed_tasks"`
+	EstimateMAPE   *float64 `json:"estimate_mape,omitempty"`
+}
+
+// StreamRanking считает рейтинг агрегатами по task_entries (индекс file_date).
+// Часы суммируются в SQL, а для завершенных задач берется последний статус каждой задачи пользователя.
+func StreamRanking(pbApp *pocketbase.PocketBase, start, end string, statusMap map[string]string) ([]RankingItem, error) {
 	type UserStats struct {
-		UserId       string
-		TotalHours   float64
-		TaskStatuses map[string]string
+		TotalHours     float64
+		CompletedTasks int
 	}
 	statsMap := make(map[string]*UserStats)
-	var userId string
-	var dataJson string
+	params := map[string]interface{}{"start": start, "end": end}
 
-	for rows.Next() {
-		if err := rows.Scan(&userId, &dataJson); err != nil { continue }
+	hoursRows, err := pbApp.DB().NewQuery("SELECT " + app.FieldUser + ", COALESCE(SUM(time_spent), 0) FROM " + app.CollectionTaskEntries + " WHERE " + app.FieldFileDate + " >= {:start} AND " + app.FieldFileDate + " <= {:end} GROUP BY " + app.FieldUser).Bind(params).Rows()
+	if err != nil { return nil, err }
+	defer hoursRows.Close()
+	for hoursRows.Next() {
+		var userId string
+		var hours float64
+		if err := hoursRows.Scan(&userId, &hours); err != nil { return nil, err }
 		if userId == "" { continue }
-		if _, exists := statsMap[userId]; !exists {
-			statsMap[userId] = &UserStats{UserId: userId, TotalHours: 0, TaskStatuses: make(map[string]string)}
-		}
-		entry := statsMap[userId]
-		taskListPtr := taskSlicePool.Get().(*[]app.TaskEntry)
-		*taskListPtr = (*taskListPtr)[:0]
-		decoder := json.NewDecoder(strings.NewReader(dataJson))
-		decoder.UseNumber()
-		if err := decoder.Decode(taskListPtr); err != nil {
-			log.Printf("[StreamRanking] Error decoding JSON for user %s: %v", userId, err)
-			taskSlicePool.Put(taskListPtr)
-			continue
-		}
-		for _, t := range *taskListPtr {
-			entry.TotalHours += GetTimeSpent(t["time_spent"])
-			tNum := fmt.Sprintf("%v", t["task_number"])
-			if tNum != "" { entry.TaskStatuses[tNum] = fmt.Sprintf("%v", t["status"]) }
-		}
-		taskSlicePool.Put(taskListPtr)
+		statsMap[userId] = &UserStats{TotalHours: hours}
+	}
+	if err := hoursRows.Err(); err != nil { return nil, err }
+
+	// Последний статус по каждой паре (user, task_number) в пределах периода; строки без номера задачи — только часы
+	statusRows, err := pbApp.DB().NewQuery("SELECT user, status, COUNT(*) FROM (SELECT te.user AS user, te.status AS status, ROW_NUMBER() OVER (PARTITION BY te.user, te.task_number ORDER BY te.file_date DESC, t.rowid DESC, te.line DESC) AS rn FROM " + app.CollectionTaskEntries + " te JOIN " + app.CollectionTasks + " t ON t.id = te.task WHERE te.file_date >= {:start} AND te.file_date <= {:end} AND te.task_number != '') WHERE rn = 1 GROUP BY user, status").Bind(params).Rows()
+	if err != nil { return nil, err }
+	defer statusRows.Close()
+	for statusRows.Next() {
+		var userId, status string
+		var count int
+		if err := statusRows.Scan(&userId, &status, &count); err != nil { return nil, err }
+		entry, exists := statsMap[userId]
+		if !exists { continue }
+		if IsStatusCompleted(status, statusMap) { entry.CompletedTasks += count }
 	}
+	if err := statusRows.Err(); err != nil { return nil, err }
 
 	users, _ := pbApp.FindRecordsByFilter("users", "id != ''", "", 1000, 0, nil)
 	userMap := make(map[string]string)
@@ -111,24 +348,13 @@ func StreamRanking(pbApp *pocketbase.PocketBase, start, end string, statusMap ma
 		emailMap[u.Id] = u.GetString("email")
 	}
 
-	type ResponseItem struct {
-		UserId         string  `json:"user_id"`
-		UserName       string  `json:"user_name"`
-		UserEmail      string  `json:"user_email"`
-		TotalHours     float64 `json:"total_hours"`
-		CompletedTasks int     `json:"completed_tasks"`
-	}
-	response := []ResponseItem{}
+	response := []RankingItem{}
 	for userId, stat := range statsMap {
-		completedCount := 0
-		for _, status := range stat.TaskStatuses {
-			if IsStatusCompleted(status, statusMap) { completedCount++ }
-		}
 		name := userMap[userId]
 		if name == "" { name = "Unknown" }
-		response = append(response, ResponseItem{
+		response = append(response, RankingItem{
 			UserId: userId, UserName: name, UserEmail: emailMap[userId],
-			TotalHours: stat.TotalHours, CompletedTasks: completedCount,
+			TotalHours: stat.TotalHours, CompletedTasks: stat.CompletedTasks,
 		})
 	}
 	return response, nil
@@ -166,20 +392,31 @@ func NormalizeStatus(status interface{}) string {
 	return strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", status)))
 }
 
+// IsStatusCompleted — завершенный статус (final, final_return или appeal). Тип берется только из statuses:
+// незнакомый статус не считается завершенным
 func IsStatusCompleted(status interface{}, statusMap map[string]string) bool {
-	norm := NormalizeStatus(status)
-	if t, exists := statusMap[norm]; exists { return t == "final" }
-	return norm == "completed" || norm == "завершена" || norm == "completed_return" || norm == "завершена (возврат)" || norm == "completed_appeal" || norm == "завершена (апелляция)"
+	t := statusMap[NormalizeStatus(status)]
+	return t == app.StatusFinal || t == app.StatusFinalReturn || t == app.StatusAppeal
 }
 
+// IsStatusInProgress — статус в работе, включая возврат
 func IsStatusInProgress(status interface{}, statusMap map[string]string) bool {
-	norm := NormalizeStatus(status)
-	if t, exists := statusMap[norm]; exists { return t == "in_progress" || t == "return" }
-	return norm == "in_progress" || norm == "выполняется" || norm == "in_progress_return" || norm == "выполняется (возврат)"
+	t := statusMap[NormalizeStatus(status)]
+	return t == app.StatusInProgress || t == app.StatusReturn
 }
 
+// IsStatusInProgressReturn — статус с типом return
 func IsStatusInProgressReturn(status interface{}, statusMap map[string]string) bool {
-	norm := NormalizeStatus(status)
-	if t, exists := statusMap[norm]; exists { return t == "return" }
-	return norm == "in_progress_return" || norm == "выполняется (возврат)"
+	return statusMap[NormalizeStatus(status)] == app.StatusReturn
+}
+
+// IsStatusReturnKind — статус, означающий возврат задачи: тип return или final_return
+func IsStatusReturnKind(status interface{}, statusMap map[string]string) bool {
+	t := statusMap[NormalizeStatus(status)]
+	return t == app.StatusReturn || t == app.StatusFinalReturn
+}
+
+// IsStatusAppeal — статус с типом appeal
+func IsStatusAppeal(status interface{}, statusMap map[string]string) bool {
+	return statusMap[NormalizeStatus(status)] == app.StatusAppeal
 }
@@ -96,3 +96,71 @@ func TestIsValidDateTime(t *testing.T) {
 		t.Error("Format DD.MM.YYYY should NOT be valid (Go style)")
 	}
 }
+
+func TestPeriodFromQuery(t *testing.T) {
+	start, end, ok := PeriodFromQuery("2025-12", "")
+	if !ok || start != "2025-12-01 00:00:00" || end != "2025-12-31 23:59:59" {
+		t.Errorf("Month period == %q..%q (%v)", start, end, ok)
+	}
+	start, end, ok = PeriodFromQuery("", "2025")
+	if !ok || start != "2025-01-01 00:00:00" || end != "2025-12-31 23:59:59" {
+		t.Errorf("Year period == %q..%q (%v)", start, end, ok)
+	}
+	if _, _, ok := PeriodFromQuery("2025-13", ""); ok {
+		t.Error("2025-13 should NOT produce a period")
+	}
+}
+
+func TestIsStatusReturnKind(t *testing.T) {
+	statusMap := map[string]string{"in_progress_return": "return", "fixed": "final_return", "completed_appeal": "appeal", "legacy_return": "final"}
+
+	if !IsStatusReturnKind("In_Progress_Return", statusMap) {
+		t.Error("in_progress_return should be a return status")
+	}
+	if !IsStatusReturnKind("fixed", statusMap) || !IsStatusCompleted("fixed", statusMap) {
+		t.Error("final_return status should be both a return and completed")
+	}
+	if IsStatusReturnKind("legacy_return", statusMap) {
+		t.Error("slug suffix must not make a final status a return")
+	}
+	if IsStatusReturnKind("completed_appeal", statusMap) {
+		t.Error("completed_appeal should NOT be a return status")
+	}
+	if !IsStatusAppeal("completed_appeal", statusMap) || !IsStatusCompleted("completed_appeal", statusMap) {
+		t.Error("appeal status should be an appeal and completed")
+	}
+}
+
+func TestPreviousPeriod(t *testing.T) {
+	cases := []struct {
+		month, year         string
+		wantMonth, wantYear string
+	}{
+		{"2025-03", "", "2025-02", ""},
+		{"2025-01", "", "2024-12", ""},
+		{"", "2025", "", "2024"},
+		{"bad", "", "", ""},
+	}
+
+	for _, c := range cases {
+		m, y := PreviousPeriod(c.month, c.year)
+		if m != c.wantMonth || y != c.wantYear {
+			t.Errorf("PreviousPeriod(%q, %q) = (%q, %q), want (%q, %q)", c.month, c.year, m, y, c.wantMonth, c.wantYear)
+		}
+	}
+}
+
+func TestIsStatusCompletedUsesOnlyStatusMap(t *testing.T) {
+	statusMap := map[string]string{"завершена (частично)": "final", "in_progress": "in_progress"}
+
+	if !IsStatusCompleted("Завершена (частично)", statusMap) {
+		t.Error("a status typed final in statuses should be completed")
+	}
+	// Без записи в statuses статус неизвестен, встроенных списков больше нет
+	if IsStatusCompleted("completed", statusMap) {
+		t.Error("unknown status should NOT be completed")
+	}
+	if !IsStatusInProgress("IN_PROGRESS", statusMap) || IsStatusInProgress("выполняется", statusMap) {
+		t.Error("IsStatusInProgress should follow statusMap only")
+	}
+}
@@ -29,6 +29,7 @@ export default function BitrixTasks({ lang }: BitrixTasksProps) {
         groupMap, 
         loading, 
         syncing, 
+        syncRun,
         error, 
         refresh, 
         isSpecialUser, 
@@ -136,6 +137,16 @@ export default function BitrixTasks({ lang }: BitrixTasksProps) {
                         }}>↻</span>
                         {syncing && <span style={{ fontSize: '11px', fontWeight: 600 }}>SYNC...</span>}
                     </button>
+                    {syncRun && (
+                        <span
+                            title={syncRun.error || `Начало: ${new Date(syncRun.started).toLocaleString()}`}
+                            style={{ fontSize: '11px', color: syncRun.status === 'failed' ? '#dc2626' : '#64748b', whiteSpace: 'nowrap' }}
+                        >
+                            {syncRun.status === 'running' && `Синхронизация: +${syncRun.created_count} / ~${syncRun.updated_count}`}
+                            {syncRun.status === 'success' && `Синхронизировано ${new Date(syncRun.finished).toLocaleTimeString()}`}
+                            {syncRun.status === 'failed' && 'Ошибка синхронизации'}
+                        </span>
+                    )}
                 </div>
             </div>
 
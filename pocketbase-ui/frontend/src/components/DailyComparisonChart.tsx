@@ -1,5 +1,5 @@
 import { useEffect, useState, useRef } from 'react';
-import pb from '../lib/pocketbase';
+import pb, { getComparisonStats, currentMonth, PeriodStats } from '../lib/pocketbase';
 import { translations, Language } from '../lib/translations';
 import { getColor } from '../lib/colors';
 import { Card } from './ui/Card';
@@ -40,47 +40,16 @@ export default function DailyComparisonChart({ lang, refreshTrigger }: DailyComp
 
         setLoading(true);
         try {
-            const now = new Date();
-            const y = now.getFullYear();
-            const m = now.getMonth();
-            
-            // Текущий месяц
-            const startCur = `${y}-${String(m + 1).padStart(2, '0')}-01 00:00:00`;
-            const endCur = `${y}-${String(m + 1).padStart(2, '0')}-${new Date(y, m + 1, 0).getDate()} 23:59:59`;
-
-            // Прошлый месяц
-            const prevM = new Date(y, m - 1, 1);
-            const startPrev = `${prevM.getFullYear()}-${String(prevM.getMonth() + 1).padStart(2, '0')}-01 00:00:00`;
-            const endPrev = `${prevM.getFullYear()}-${String(prevM.getMonth() + 1).padStart(2, '0')}-${new Date(prevM.getFullYear(), prevM.getMonth() + 1, 0).getDate()} 23:59:59`;
-
-            const recordsCur = await pb.collection('tasks').getFullList({
-                filter: `user = "${user.id}" && file_date >= "${startCur}" && file_date <= "${endCur}"`,
-                requestKey: null,
-                fields: 'data,file_date' 
-            });
-
-            const recordsPrev = await pb.collection('tasks').getFullList({
-                filter: `user = "${user.id}" && file_date >= "${startPrev}" && file_date <= "${endPrev}"`,
-                requestKey: null,
-                fields: 'data,file_date'
-            });
-
-            const aggregate = (records: any[]) => {
+            const stats = await getComparisonStats({ month: currentMonth() });
+
+            const aggregate = (period: PeriodStats) => {
                 const map = new Map<number, number>();
-                records.forEach(r => {
-                    const day = new Date(r.file_date).getDate();
-                    if (Array.isArray(r.data)) {
-                        r.data.forEach((task: any) => {
-                            const hours = Number(task.time_spent);
-                            if (!isNaN(hours)) map.set(day, (map.get(day) || 0) + hours);
-                        });
-                    }
-                });
+                period.days.forEach(d => map.set(Number(d.date.slice(8, 10)), d.hours));
                 return map;
             };
 
-            const curMap = aggregate(recordsCur);
-            const prevMap = aggregate(recordsPrev);
+            const curMap = aggregate(stats.current);
+            const prevMap = aggregate(stats.previous);
 
             const data = []; let max = 0;
             for (let i = 1; i <= 31; i++) {
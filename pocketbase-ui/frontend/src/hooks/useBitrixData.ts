@@ -1,6 +1,6 @@
 import { useState, useEffect, useRef } from 'react';
 import pb from '../lib/pocketbase';
-import { BitrixTask, BitrixUser, BitrixGroup } from '../types/bitrix';
+import { BitrixTask, BitrixUser, BitrixGroup, BitrixSyncRun, BitrixSyncStatus } from '../types/bitrix';
 
 export const useBitrixData = () => {
     const [tasks, setTasks] = useState<BitrixTask[]>([]);
@@ -10,6 +10,7 @@ export const useBitrixData = () => {
     const [loading, setLoading] = useState(false);
     const [syncing, setSyncing] = useState(false);
     const [error, setError] = useState('');
+    const [syncRun, setSyncRun] = useState<BitrixSyncRun | null>(null);
 
     const currentUser = pb.authStore.model;
     const isSpecialUser = currentUser?.superadmin || currentUser?.is_coordinator;
@@ -18,18 +19,32 @@ export const useBitrixData = () => {
     // We keep track of "allowed" users (IT Dept) to filter incoming realtime events efficiently
     const allowedUserIdsRef = useRef<Set<string>>(new Set());
 
+    const fetchSyncStatus = async () => {
+        try {
+            const status = await pb.send<BitrixSyncStatus>('/api/bitrix/sync/status', { requestKey: null });
+            setSyncRun(status.current || status.last_finished);
+        } catch (err) {
+            console.warn('Sync status unavailable:', err);
+        }
+    };
+
     const loadData = async (triggerSync = false) => {
         setLoading(true);
         setError('');
         try {
             if (triggerSync) {
                 setSyncing(true);
+                // Пока идет синхронизация, показываем счетчики текущего запуска
+                const poll = setInterval(fetchSyncStatus, 2000);
                 try {
                     await pb.send('/api/bitrix/sync-incremental', { method: 'POST' });
-                } catch (syncErr) {
+                } catch (syncErr: any) {
                     console.warn('Sync failed:', syncErr);
+                    setError(syncErr?.status === 409 ? 'Синхронизация уже выполняется' : `Ошибка синхронизации: ${syncErr?.message || syncErr}`);
                 } finally {
+                    clearInterval(poll);
                     setSyncing(false);
+                    await fetchSyncStatus();
                 }
             }
 
@@ -76,6 +91,7 @@ export const useBitrixData = () => {
 
     useEffect(() => {
         loadData();
+        fetchSyncStatus();
 
         pb.collection('bitrix_tasks_active').subscribe('*', async (e) => {
             if (e.action === 'delete') {
@@ -113,6 +129,7 @@ export const useBitrixData = () => {
         groupMap,
         loading,
         syncing,
+        syncRun,
         error,
         refresh: (sync: boolean) => loadData(sync),
         currentUser,
@@ -1,7 +1,6 @@
 import { useState, useEffect } from 'react';
-import pb from '../lib/pocketbase';
+import pb, { getDailyStats } from '../lib/pocketbase';
 import { getColor } from '../lib/colors';
-import { calculateTotalHours } from '../lib/logic';
 
 export interface StatusDefinition {
     title: string;
@@ -33,25 +32,19 @@ export const useDailyStats = (refreshTrigger: number) => {
             const d = String(now.getDate()).padStart(2, '0');
             const todayStr = `${y}-${m}-${d}`;
 
-            const records = await pb.collection('tasks').getFullList({
-                filter: `user = "${user.id}" && file_date >= "${todayStr} 00:00:00" && file_date <= "${todayStr} 23:59:59"`,
-                requestKey: null
-            });
+            const daily = await getDailyStats({ date: todayStr });
 
             const counts: Record<string, number> = {};
             definitions.forEach(s => counts[s.title] = 0);
             let hoursSum = 0;
 
-            records.forEach(record => {
-                if (Array.isArray(record.data)) {
-                    hoursSum += calculateTotalHours(record.data);
-                    record.data.forEach((task: any) => {
-                        const status = task.status?.trim();
-                        if (definitions.some(d => d.title === status)) {
-                            counts[status] = (counts[status] || 0) + 1;
-                        }
-                    });
-                }
+            daily.days.forEach(day => {
+                hoursSum += day.hours;
+                Object.entries(day.statuses).forEach(([status, count]) => {
+                    if (definitions.some(d => d.title === status)) {
+                        counts[status] = (counts[status] || 0) + count;
+                    }
+                });
             });
 
             setStats(counts);
@@ -1,6 +1,5 @@
 import { useState, useEffect } from 'react';
-import pb from '../lib/pocketbase';
-import { calculateTotalHours } from '../lib/logic';
+import pb, { getDailyStats, currentMonth } from '../lib/pocketbase';
 
 export const useMonthlyStats = (refreshTrigger: number) => {
     const [monthlyHours, setMonthlyHours] = useState(0);
@@ -11,22 +10,8 @@ export const useMonthlyStats = (refreshTrigger: number) => {
         if (!user) return;
         setLoading(true);
         try {
-            const now = new Date();
-            const start = new Date(now.getFullYear(), now.getMonth(), 1).toISOString().replace('T', ' ').split('.')[0];
-            const end = new Date(now.getFullYear(), now.getMonth() + 1, 0, 23, 59, 59).toISOString().replace('T', ' ').split('.')[0];
-
-            const records = await pb.collection('tasks').getFullList({
-                filter: `user = "${user.id}" && file_date >= "${start}" && file_date <= "${end}"`,
-                fields: 'data'
-            });
-
-            let sum = 0;
-            records.forEach(r => {
-                if (Array.isArray(r.data)) {
-                    sum += calculateTotalHours(r.data);
-                }
-            });
-            setMonthlyHours(sum);
+            const stats = await getDailyStats({ month: currentMonth() });
+            setMonthlyHours(stats.total_hours);
         } catch (e) { console.error(e); } finally { setLoading(false); }
     };
 
@@ -2,7 +2,6 @@ import { useState, useEffect } from 'react';
 import pb, { getUserFiles, clearRankingCache, handleApiError } from '../lib/pocketbase';
 import { translations, Language } from '../lib/translations';
 import { TaskField, User } from '../types/tasks';
-import * as XLSX from 'xlsx';
 
 export const useTaskUpload = (lang: Language) => {
     const t = translations[lang];
@@ -74,9 +73,21 @@ export const useTaskUpload = (lang: Language) => {
         return parts.length === 3 ? `${parts[2]}.${parts[1]}.${parts[0]}` : "";
     };
 
+    const formatDiagnostic = (d: any): string => {
+        const title = d.title ? `'${d.title}'` : '';
+        switch (d.code) {
+            case 'missing_column': return `${t.infoColsTitle} ${d.title}`;
+            case 'required': return `${t.row} ${d.row}: ${title} ${t.fieldIsEmpty}`;
+            case 'not_number': return `${t.row} ${d.row}: ${title} ${t.mustBeNumber}`;
+            case 'duplicate_task': return `${t.row} ${d.row}: ${d.value} — ${t.duplicateTask} ${d.ref}`;
+            case 'date_out_of_range': return `${t.row} ${d.row}: ${t.dateOutOfRange} ${d.value}`;
+            default: return `${t.row} ${d.row}: ${title} ${t.invalidValue}`;
+        }
+    };
+
     const processFile = async (file: File) => {
         setUploading(true); setMessage(t.validating); setError(''); setDetailedErrors([]);
-        
+
         const targetUserId = selectedUserId || currentUser?.id;
         const requiredPrefix = getFormattedDateForCheck(fileDate);
 
@@ -85,99 +96,36 @@ export const useTaskUpload = (lang: Language) => {
         }
 
         try {
-            const existingFiles = await pb.collection('tasks').getFullList({
-                filter: `user = "${targetUserId}" && file_date >= "${fileDate} 00:00:00" && file_date <= "${fileDate} 23:59:59"`,
+            // Разбор и валидация Excel выполняются на сервере
+            const formData = new FormData();
+            formData.append('excel_file', file);
+            formData.append('file_date', fileDate);
+            formData.append('user', targetUserId || '');
+
+            const res = await pb.send<{ id: string, tasks_count: number }>('/api/kpi/upload', {
+                method: 'POST',
+                body: formData,
                 requestKey: null
             });
 
-            if (existingFiles.some(f => f.file_name === file.name)) {
-                setError(t.fileAlreadyExists); setUploading(false); return;
-            }
-
-            if (existingFiles.length >= 2 && !isSuperAdmin) {
-                setError(t.limitReached); setUploading(false); return;
+            setMessage(`${t.successMsg} ${res.tasks_count} ${t.tasksCount}.`);
+            clearRankingCache();
+        } catch (err: any) {
+            const data = err?.response || {};
+            if (data.code === 'validation_failed') {
+                setError(t.validationFailed);
+                setDetailedErrors((data.errors || []).map(formatDiagnostic));
+            } else if (data.code === 'file_exists') {
+                setError(t.fileAlreadyExists);
+            } else if (data.code === 'limit_reached') {
+                setError(t.limitReached);
+            } else if (data.code === 'bad_prefix') {
+                setError(`${t.errorPrefix} "${requiredPrefix}"`);
+            } else {
+                setError(handleApiError(err, t));
             }
-
-            const reader = new FileReader();
-            reader.onload = async (evt) => {
-                try {
-                    const bstr = evt.target?.result;
-                    const wb = XLSX.read(bstr, { type: 'binary', cellDates: true });
-                    let ws = wb.Sheets["Лист1"] || wb.Sheets[wb.SheetNames[0]];
-                    const data = XLSX.utils.sheet_to_json(ws, { header: 1 }) as any[][];
-                    
-                    if (!data || data.length === 0) throw new Error("File is empty");
-
-                    const headers = (data[0] as any[]).map(h => h?.toString().trim().toLowerCase());
-                    const columnMapping: Record<string, number> = {};
-                    
-                    taskFields.forEach(f => {
-                        const idx = headers.findIndex(h => h === f.title.toLowerCase());
-                        if (idx !== -1) columnMapping[f.key] = idx;
-                    });
-
-                    const missing = taskFields.filter(f => f.required && columnMapping[f.key] === undefined);
-                    if (missing.length > 0) throw new Error(`${t.infoColsTitle} ${missing.map(f=>f.title).join(", ")}`);
-
-                    const rows = data.slice(1);
-                    const parsedTasks: any[] = [];
-                    const vErrors: string[] = [];
-
-                    rows.forEach((row, i) => {
-                        const r = row as any[]; if (!r || r.length === 0) return;
-                        const isEmpty = Object.values(columnMapping).every(idx => !r[idx]); if (isEmpty) return;
-
-                        const task: any = {}; let rowOk = true;
-                        taskFields.forEach(f => {
-                            if (!rowOk || f.key === 'original_time_spent' || f.key === 'is_edited') return;
-                            const colIdx = columnMapping[f.key];
-                            const val = r[colIdx];
-                            if (f.required && (val === undefined || val === null || val === "")) { 
-                                vErrors.push(`${t.row} ${i+2}: '${f.title}' ${t.fieldIsEmpty}`); rowOk = false; return; 
-                            }
-                            
-                            if (f.type === 'number') {
-                                const num = Number(val?.toString().replace(',', '.'));
-                                if (isNaN(num)) { vErrors.push(`${t.row} ${i+2}: '${f.title}' ${t.mustBeNumber}`); rowOk = false; }
-                                task[f.key] = num || 0;
-                            } else if (f.type === 'date' && val instanceof Date) {
-                                task[f.key] = val.toISOString().split('T')[0];
-                            } else if (f.key === 'status') {
-                                if (val && !validStatuses.includes(val.toString().trim())) {
-                                    vErrors.push(`${t.row} ${i+2}: '${f.title}' ${t.invalidValue}`); rowOk = false;
-                                }
-                                task[f.key] = val?.toString().trim() || "";
-                            } else {
-                                task[f.key] = val?.toString().trim() || "";
-                            }
-                        });
-                        if (rowOk) parsedTasks.push(task);
-                    });
-
-                    if (vErrors.length > 0) { setError(t.validationFailed); setDetailedErrors(vErrors); setUploading(false); return; }
-
-                    const formData = new FormData();
-                    formData.append('excel_file', file);
-                    formData.append('file_name', file.name);
-                    formData.append('file_date', fileDate + " 12:00:00");
-                    formData.append('data', JSON.stringify(parsedTasks));
-                    formData.append('user', targetUserId || '');
-                    if (isSuperAdmin && currentUser) formData.append('uploaded_by', currentUser.id);
-
-                    await pb.collection('tasks').create(formData);
-                    
-                    if (isSuperAdmin && currentUser) {
-                        await pb.collection('upload_logs').create({ file_name: file.name, uploaded_by: currentUser.id, target_user: targetUserId });
-                    }
-
-                    setMessage(`${t.successMsg} ${parsedTasks.length} ${t.tasksCount}.`);
-                    clearRankingCache();
-                } catch (err: any) { setError(handleApiError(err, t)); } finally { setUploading(false); }
-            };
-            reader.readAsBinaryString(file);
-        } catch (err: any) { 
-            setError(handleApiError(err, t)); 
-            setUploading(false); 
+        } finally {
+            setUploading(false);
         }
     };
 
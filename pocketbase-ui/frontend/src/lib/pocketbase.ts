@@ -1,4 +1,5 @@
 import PocketBase, { ClientResponseError, BaseAuthStore } from 'pocketbase';
+import type { BitrixTaskDetails } from '../types/bitrix';
 
 // Используем BaseAuthStore (в памяти) для изоляции сессий в разных окнах Wails
 const pb = new PocketBase('http://127.0.0.1:8090', new BaseAuthStore());
@@ -12,6 +13,7 @@ export interface RankingItem {
     user_email: string;
     total_hours: number;
     completed_tasks: number;
+    estimate_mape?: number; // только при ?estimate_accuracy=1
 }
 
 export const clearRankingCache = () => {
@@ -30,6 +32,52 @@ export const getYearlyRanking = async (year: string): Promise<RankingItem[]> =>
     });
 };
 
+export interface DayStats {
+    date: string; // YYYY-MM-DD
+    hours: number;
+    tasks: number;
+    completed_tasks: number;
+    statuses: Record<string, number>;
+}
+
+export interface PeriodStats {
+    start: string;
+    end: string;
+    total_hours: number;
+    completed_tasks: number;
+    days: DayStats[];
+}
+
+export interface ComparisonStats {
+    current: PeriodStats;
+    previous: PeriodStats;
+    hours_delta: number;
+    completed_delta: number;
+}
+
+// Период: { date: 'YYYY-MM-DD' } | { month: 'YYYY-MM' } | { year: 'YYYY' }; без user/department — текущий пользователь
+export const getDailyStats = async (params: Record<string, string>): Promise<PeriodStats> => {
+    return await pb.send<PeriodStats>('/api/kpi/daily-stats', { params, requestKey: null });
+};
+
+export const getComparisonStats = async (params: Record<string, string>): Promise<ComparisonStats> => {
+    return await pb.send<ComparisonStats>('/api/kpi/comparison', { params, requestKey: null });
+};
+
+export const getBitrixTaskDetails = async (bitrixId: number): Promise<BitrixTaskDetails> => {
+    return await pb.send<BitrixTaskDetails>(`/api/bitrix/tasks/${bitrixId}`, { requestKey: null });
+};
+
+// Замена вебхука Bitrix24 (только superadmin). Сервер проверяет вебхук и хранит его зашифрованным, обратно он не отдается
+export const rotateBitrixWebhook = async (webhook: string): Promise<void> => {
+    await pb.send('/api/bitrix/webhook', { method: 'POST', body: { webhook } });
+};
+
+export const currentMonth = (): string => {
+    const now = new Date();
+    return `${now.getFullYear()}-${String(now.getMonth() + 1).padStart(2, '0')}`;
+};
+
 export const handleApiError = (error: any, t: any): string => {
     if (error instanceof ClientResponseError) {
         if (error.status === 401) return t.unauthorizedError || "Unauthorized";
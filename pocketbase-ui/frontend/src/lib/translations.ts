@@ -48,6 +48,8 @@ export interface TranslationKeys {
     fieldIsEmpty: string;
     invalidValue: string;
     mustBeNumber: string;
+    duplicateTask: string;
+    dateOutOfRange: string;
     row: string;
     fileAlreadyExists: string;
     limitReached: string;
@@ -176,6 +178,8 @@ export const translations: Record<Language, TranslationKeys> = {
         fieldIsEmpty: "поле не может быть пустым.", 
         invalidValue: "Неверное значение", 
         mustBeNumber: "Должно быть числом", 
+        duplicateTask: "задача уже есть в отчете, строка", 
+        dateOutOfRange: "дата не совпадает с днем отчета:", 
         row: "Строка", 
         fileAlreadyExists: "Файл с таким именем уже загружен", 
         limitReached: "Лимит загрузок на эту дату исчерпан (макс. 2 файла)",
@@ -309,6 +313,8 @@ export const translations: Record<Language, TranslationKeys> = {
         fieldIsEmpty: "sahəsi boş ola bilməz.",
         invalidValue: "Yanlış dəyər",
         mustBeNumber: "Rəqəm olmalıdır",
+        duplicateTask: "tapşırıq hesabatda artıq var, sətir",
+        dateOutOfRange: "tarix hesabat günü ilə uyğun gəlmir:",
         row: "Sətir", 
         fileAlreadyExists: "Bu adda fayl artıq yüklənib", 
         limitReached: "Bu tarix üçün yükləmə limiti dolub (maks. 2 fayl)",
@@ -442,6 +448,8 @@ export const translations: Record<Language, TranslationKeys> = {
         fieldIsEmpty: "field cannot be empty.",
         invalidValue: "Invalid value",
         mustBeNumber: "Must be a number",
+        duplicateTask: "task is already in the report, row",
+        dateOutOfRange: "date does not match the report day:",
         row: "Row", 
         fileAlreadyExists: "File with this name already uploaded", 
         limitReached: "Upload limit reached for this date (max 2 files)",
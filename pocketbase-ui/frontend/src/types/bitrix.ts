@@ -3,6 +3,13 @@ import { RecordModel } from 'pocketbase';
 export interface BitrixUser extends RecordModel {
     bitrix_id: number;
     full_name: string;
+    first_name?: string;
+    last_name?: string;
+    second_name?: string;
+    email?: string;
+    active?: boolean;
+    work_position?: string;
+    photo?: string;
 }
 
 export interface BitrixGroup extends RecordModel {
@@ -26,3 +33,71 @@ export interface BitrixTask extends RecordModel {
         group?: BitrixGroup;
     };
 }
+
+export interface BitrixSyncRun extends RecordModel {
+    kind: 'full' | 'incremental' | 'reconcile';
+    status: 'running' | 'success' | 'failed';
+    started: string;
+    finished: string;
+    created_count: number;
+    updated_count: number;
+    deleted_count: number;
+    error: string;
+    high_water_mark: string;
+}
+
+export interface BitrixSyncStatus {
+    running: boolean;
+    current: BitrixSyncRun | null;
+    last_finished: BitrixSyncRun | null;
+    last_success: BitrixSyncRun | null;
+    history: BitrixSyncRun[];
+}
+
+export interface BitrixTaskComment extends RecordModel {
+    bitrix_id: number;
+    task_bitrix_id: number;
+    author: string;
+    author_name: string;
+    text: string;
+    posted: string;
+}
+
+export interface BitrixChecklistItem extends RecordModel {
+    bitrix_id: number;
+    task_bitrix_id: number;
+    parent_bitrix_id: number;
+    title: string;
+    sort_index: number;
+    is_complete: boolean;
+}
+
+export interface BitrixTaskDetails {
+    task: BitrixTask;
+    comments: BitrixTaskComment[];
+    checklist: {
+        total: number;
+        completed: number;
+        items: BitrixChecklistItem[];
+    };
+}
+
+export interface OrgPerson {
+    bitrix_user_id: string;
+    bitrix_id: number;
+    name: string;
+    work_position: string;
+    photo: string;
+    active: boolean;
+    user_id?: string;
+    user_name?: string;
+}
+
+export interface OrgDepartment {
+    department_id: number;
+    parent_id: number;
+    name: string;
+    head: OrgPerson | null;
+    members: OrgPerson[];
+    children: OrgDepartment[];
+}
@@ -12,6 +12,7 @@ export interface Status {
     title: string;
     slug: string;
     color: string;
+    type?: 'final' | 'final_return' | 'appeal' | 'in_progress' | 'return' | '';
 }
 
 export interface User {